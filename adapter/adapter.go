@@ -20,14 +20,17 @@ package adapter
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,11 +45,14 @@ import (
 	"istio.io/istio/mixer/pkg/adapter"
 	"istio.io/istio/mixer/pkg/status"
 	authT "istio.io/istio/mixer/template/authorization"
+	logentryT "istio.io/istio/mixer/template/logentry"
+	"istio.io/istio/pkg/cache"
 )
 
 const (
 	jsonClaimsKey             = "json_claims"
 	apiKeyAttribute           = "api_key"
+	apiKeyClaimPrefix         = "claim:"
 	gatewaySource             = "istio"
 	tempDirMode               = os.FileMode(0700)
 	certPollInterval          = 0 // jwt validation not currently needed
@@ -55,6 +61,32 @@ const (
 	// if APIGEE_ORG or APIGEE_ENV are set in os env, only those values can be valid in the configuration
 	orgEnvKey = "APIGEE_ORG"
 	envEnvKey = "APIGEE_ENV"
+
+	// default guards against oversized untrusted inputs in request
+	// attributes; each is configurable via the limits options
+	maxJSONClaimsLength   = 64 * 1024
+	maxAPIKeyLength       = 8 * 1024
+	maxClaimsCount        = 100
+	maxAnalyticsInstances = 1000
+
+	// authorization modes
+	authModeEnforce = "enforce"
+	authModeShadow  = "shadow"
+
+	// quota exceeded status semantics
+	quotaExceeded429 = "429"
+	quotaExceeded403 = "403"
+
+	// multiple product match behavior
+	multiProductAll   = "all"
+	multiProductFirst = "first"
+
+	// authorization results without quota counting are cached briefly,
+	// keyed on auth inputs + service + path; the TTL default is overridable
+	// via authorization_cache_ttl
+	authResultCacheTTL        = 10 * time.Second
+	authResultCacheEviction   = time.Minute
+	authResultCacheMaxEntries = 10000
 )
 
 type (
@@ -71,11 +103,25 @@ type (
 		key            string
 		secret         string
 		apiKeyClaimKey string
+		scopeRules     []scopeRule
+
+		developerPrincipalClaim  string
+		quotaIdentifierAttribute string
+		authorizationMode        string
+		clusterID                string
+		quotaExceededStatus      string
+		multipleProductsMode     string
+		apiKeyAttributes         []string
+		enabledNamespaces        []string
+		excludedNamespaces       []string
+		limits                   requestLimits
 
 		productMan   *product.Manager
 		authMan      *auth.Manager
 		analyticsMan analytics.Manager
 		quotaMan     *quota.Manager
+		lifecycle    lifecycle
+		resultCache  cache.ExpiringCache
 	}
 )
 
@@ -127,6 +173,7 @@ func GetInfo() adapter.Info {
 		SupportedTemplates: []string{
 			analyticsT.TemplateName,
 			authT.TemplateName,
+			logentryT.TemplateName,
 		},
 		DefaultConfig: &config.Params{
 			ClientTimeout: pbtypes.DurationProto(30 * time.Second),
@@ -155,6 +202,44 @@ func timeToUnix(t time.Time) int64 {
 	return t.UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
 }
 
+// authResultCacheKey hashes the authorization dimensions: the API key (from
+// whichever configured location it arrives in), the raw claims, the subject
+// user and the target service and path
+func (h *handler) authResultCacheKey(inst *authT.Instance) string {
+	apiKey := h.extractAPIKey(inst.Subject.Properties, h.resolveClaimsInterface(inst.Subject.Properties))
+	jsonClaims, _ := inst.Subject.Properties[jsonClaimsKey].(string)
+	sum := sha256.New()
+	for _, part := range []string{apiKey, jsonClaims, inst.Subject.User, inst.Action.Service, inst.Action.Path} {
+		sum.Write([]byte(part))
+		sum.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", sum.Sum(nil))
+}
+
+// identifiers longer than this are hashed to bound bucket key size
+const maxQuotaIdentifierLength = 128
+
+// scopedQuotaIdentifier scopes a client-supplied quota identifier to the
+// mesh namespace and service it arrived on, so a spoofed header value can't
+// drain quota buckets belonging to another namespace or service. Oversized
+// identifiers are hashed rather than trusted as-is.
+func scopedQuotaIdentifier(namespace, service, id string) string {
+	if len(id) > maxQuotaIdentifierLength {
+		sum := sha256.Sum256([]byte(id))
+		id = fmt.Sprintf("%x", sum[:16])
+	}
+	return fmt.Sprintf("%s/%s/%s", namespace, service, id)
+}
+
+// orDefaultCreds returns the override credentials, or the defaults if the
+// override pair is not fully specified
+func orDefaultCreds(key, secret, defaultKey, defaultSecret string) (string, string) {
+	if key != "" && secret != "" {
+		return key, secret
+	}
+	return defaultKey, defaultSecret
+}
+
 // toDuration converts protobuf Duration to time.Duration.
 func toDuration(durationProto *pbtypes.Duration) time.Duration {
 	duration, err := pbtypes.DurationFromProto(durationProto)
@@ -190,17 +275,27 @@ func (b *builder) Build(context context.Context, env adapter.Env) (adapter.Handl
 		return nil, fmt.Errorf("invalid tenant env: %s", b.handlerConfig.EnvName)
 	}
 
+	customerBase, err := url.Parse(b.handlerConfig.CustomerBase)
+	if err != nil {
+		return nil, err
+	}
+
 	// apigeeBase not required for hybrid, ignore if the hybrid config is set
 	var apigeeBase *url.URL
 	hybridConfigFile := b.handlerConfig.HybridConfig
-	if hybridConfigFile == "" {
-		var err error
+	switch {
+	case hybridConfigFile != "": // hybrid uploads via UDCA, apigeeBase unused
+	case b.handlerConfig.ApigeeX:
+		// Apigee X has no edgemicro internal proxy; analytics are uploaded
+		// through the org runtime
+		apigeeBase = customerBase
+	default:
 		if apigeeBase, err = url.Parse(b.handlerConfig.ApigeeBase); err != nil {
 			return nil, err
 		}
 	}
 
-	customerBase, err := url.Parse(b.handlerConfig.CustomerBase)
+	scopeRules, err := parseScopeRules(b.handlerConfig.Auth.ScopeRules)
 	if err != nil {
 		return nil, err
 	}
@@ -234,35 +329,102 @@ func (b *builder) Build(context context.Context, env adapter.Env) (adapter.Handl
 		Transport: tr,
 	}
 
+	// managers are registered with the lifecycle in dependency order; on a
+	// failed Build or on Close they are shut down in reverse
+	var lc lifecycle
+
+	// product refresh and auth checks may use dedicated credentials
+	productKey, productSecret := orDefaultCreds(
+		b.handlerConfig.Products.Key, b.handlerConfig.Products.Secret,
+		b.handlerConfig.Key, b.handlerConfig.Secret)
+	authKey, authSecret := orDefaultCreds(
+		b.handlerConfig.Auth.Key, b.handlerConfig.Auth.Secret,
+		b.handlerConfig.Key, b.handlerConfig.Secret)
+
+	// authorization result cache: configurable TTL, 0 disables, flushed
+	// whenever products refresh so stale allows don't outlive a change
+	var resultCache cache.ExpiringCache
+	resultCacheTTL := authResultCacheTTL
+	if b.handlerConfig.AuthorizationCacheTtl != nil {
+		resultCacheTTL = toDuration(b.handlerConfig.AuthorizationCacheTtl)
+	}
+	if resultCacheTTL > 0 {
+		resultCache = cache.NewLRU(resultCacheTTL, authResultCacheEviction, authResultCacheMaxEntries)
+	}
+
 	productMan, err := product.NewManager(env, product.Options{
 		Client:      httpClient,
 		BaseURL:     customerBase,
 		RefreshRate: toDuration(b.handlerConfig.Products.RefreshRate),
-		Key:         b.handlerConfig.Key,
-		Secret:      b.handlerConfig.Secret,
+		Key:         productKey,
+		Secret:      productSecret,
+		OnUpdate: func() {
+			if resultCache != nil {
+				resultCache.RemoveAll()
+			}
+		},
 	})
 	if err != nil {
+		lc.close(env.Logger())
 		return nil, err
 	}
+	lc.add("product manager", productMan.Close)
+
+	var jwtSkew time.Duration
+	if b.handlerConfig.Auth.JwtClockSkew != nil {
+		jwtSkew = toDuration(b.handlerConfig.Auth.JwtClockSkew)
+	}
 
-	authMan, err := auth.NewManager(env, auth.Options{
+	authOptions := auth.Options{
 		PollInterval:        certPollInterval,
 		Client:              httpClient,
 		APIKeyCacheDuration: toDuration(b.handlerConfig.Auth.ApiKeyCacheDuration),
-	})
+		Key:                 authKey,
+		Secret:              authSecret,
+		JWTSkewTolerance:    jwtSkew,
+		TokenExchangeURL:    b.handlerConfig.Auth.TokenExchangeUrl,
+		RevocationCheckURL:  b.handlerConfig.Auth.RevocationCheckUrl,
+		JWKSFile:            b.handlerConfig.Auth.JwksFile,
+	}
+	if b.handlerConfig.Auth.JwtSharedSecret != "" {
+		authOptions.HS256Secret = []byte(b.handlerConfig.Auth.JwtSharedSecret)
+	}
+	authMan, err := auth.NewManager(env, authOptions)
 	if err != nil {
+		lc.close(env.Logger())
 		return nil, err
 	}
+	lc.add("auth manager", authMan.Close)
 
 	quotaMan, err := quota.NewManager(env, quota.Options{
-		BaseURL: customerBase,
-		Client:  httpClient,
-		Key:     b.handlerConfig.Key,
-		Secret:  b.handlerConfig.Secret,
+		BaseURL:         customerBase,
+		Client:          httpClient,
+		Key:             b.handlerConfig.Key,
+		Secret:          b.handlerConfig.Secret,
+		CalendarAligned: b.handlerConfig.QuotaCalendarAligned,
 	})
 	if err != nil {
+		lc.close(env.Logger())
 		return nil, err
 	}
+	lc.add("quota manager", quotaMan.Close)
+
+	var maxRecordAge, maxRecordSkew time.Duration
+	if b.handlerConfig.Analytics.MaxRecordAge != nil {
+		maxRecordAge = toDuration(b.handlerConfig.Analytics.MaxRecordAge)
+	}
+	if b.handlerConfig.Analytics.MaxRecordSkew != nil {
+		maxRecordSkew = toDuration(b.handlerConfig.Analytics.MaxRecordSkew)
+	}
+
+	// encryption-at-rest key, typically mounted from a Kubernetes Secret
+	var encryptionKey []byte
+	if b.handlerConfig.Analytics.EncryptionKeyFile != "" {
+		if encryptionKey, err = ioutil.ReadFile(b.handlerConfig.Analytics.EncryptionKeyFile); err != nil {
+			lc.close(env.Logger())
+			return nil, fmt.Errorf("encryption key file: %v", err)
+		}
+	}
 
 	analyticsMan, err := analytics.NewManager(env, analytics.Options{
 		LegacyEndpoint:     b.handlerConfig.Analytics.LegacyEndpoint,
@@ -275,11 +437,22 @@ func (b *builder) Build(context context.Context, env adapter.Env) (adapter.Handl
 		SendChannelSize:    int(b.handlerConfig.Analytics.SendChannelSize),
 		HybridConfigFile:   hybridConfigFile,
 		CollectionInterval: toDuration(b.handlerConfig.Analytics.CollectionInterval),
+		UploadMode:         b.handlerConfig.Analytics.UploadMode,
+		SamplingRate:       b.handlerConfig.Analytics.SamplingRate,
+		MaxRecordAge:       maxRecordAge,
+		MaxRecordSkew:      maxRecordSkew,
+		DebugMirrorDir:     b.handlerConfig.Analytics.DebugMirrorDir,
+		UploadTimeZone:     b.handlerConfig.Analytics.UploadTimezone,
+		EncryptionKey:      encryptionKey,
+		ScrubFields:        b.handlerConfig.Analytics.ScrubFields,
+		MirrorURLs:         b.handlerConfig.Analytics.MirrorUrls,
 	})
 	env.Logger().Infof("new manager: %#v", analyticsMan)
 	if err != nil {
+		lc.close(env.Logger())
 		return nil, err
 	}
+	lc.add("analytics manager", analyticsMan.Close)
 
 	h := &handler{
 		env:            env,
@@ -293,7 +466,21 @@ func (b *builder) Build(context context.Context, env adapter.Env) (adapter.Handl
 		authMan:        authMan,
 		analyticsMan:   analyticsMan,
 		quotaMan:       quotaMan,
+		lifecycle:      lc,
 		apiKeyClaimKey: b.handlerConfig.Auth.ApiKeyClaim,
+		scopeRules:     scopeRules,
+
+		developerPrincipalClaim:  b.handlerConfig.Auth.DeveloperPrincipalClaim,
+		quotaIdentifierAttribute: b.handlerConfig.QuotaIdentifierAttribute,
+		authorizationMode:        b.handlerConfig.AuthorizationMode,
+		quotaExceededStatus:      b.handlerConfig.QuotaExceededStatus,
+		multipleProductsMode:     b.handlerConfig.MultipleProductsMode,
+		apiKeyAttributes:         b.handlerConfig.Auth.ApiKeyAttributes,
+		enabledNamespaces:        b.handlerConfig.EnabledNamespaces,
+		excludedNamespaces:       b.handlerConfig.ExcludedNamespaces,
+		limits:                   newRequestLimits(b.handlerConfig),
+		clusterID:                b.handlerConfig.ClusterId,
+		resultCache:              resultCache,
 	}
 
 	return h, nil
@@ -311,7 +498,9 @@ func (b *builder) Validate() (errs *adapter.ConfigErrors) {
 	}
 
 	if b.handlerConfig.HybridConfig == "" {
-		if b.handlerConfig.ApigeeBase == "" {
+		if b.handlerConfig.ApigeeX {
+			// X uploads through the org runtime, apigee_base not used
+		} else if b.handlerConfig.ApigeeBase == "" {
 			errs = errs.Append("apigee_base or hybrid_config", fmt.Errorf("required"))
 		} else if _, err := url.ParseRequestURI(b.handlerConfig.ApigeeBase); err != nil {
 			errs = errs.Append("apigee_base", fmt.Errorf("must be a valid url: %v", err))
@@ -353,6 +542,30 @@ func (b *builder) Validate() (errs *adapter.ConfigErrors) {
 		errs = errs.Append("secret", fmt.Errorf("required"))
 	}
 
+	if b.handlerConfig.Auth != nil {
+		if _, err := parseScopeRules(b.handlerConfig.Auth.ScopeRules); err != nil {
+			errs = errs.Append("auth/scope_rules", err)
+		}
+	}
+
+	switch b.handlerConfig.AuthorizationMode {
+	case "", authModeEnforce, authModeShadow:
+	default:
+		errs = errs.Append("authorization_mode", fmt.Errorf("must be %s or %s", authModeEnforce, authModeShadow))
+	}
+
+	switch b.handlerConfig.QuotaExceededStatus {
+	case "", quotaExceeded429, quotaExceeded403:
+	default:
+		errs = errs.Append("quota_exceeded_status", fmt.Errorf("must be %s or %s", quotaExceeded429, quotaExceeded403))
+	}
+
+	switch b.handlerConfig.MultipleProductsMode {
+	case "", multiProductAll, multiProductFirst:
+	default:
+		errs = errs.Append("multiple_products_mode", fmt.Errorf("must be %s or %s", multiProductAll, multiProductFirst))
+	}
+
 	return errs
 }
 
@@ -363,65 +576,152 @@ func (*builder) SetAuthorizationTypes(map[string]*authT.Type)  {}
 
 // Implements adapter.Handler
 func (h *handler) Close() error {
-	h.productMan.Close()
-	h.quotaMan.Close()
-	h.authMan.Close()
-	h.analyticsMan.Close()
+	if len(h.lifecycle.components) == 0 {
+		// handler was constructed directly (eg. tests), close managers individually
+		h.productMan.Close()
+		h.quotaMan.Close()
+		h.authMan.Close()
+		h.analyticsMan.Close()
+		return nil
+	}
+	h.lifecycle.close(h.Log())
 	return nil
 }
 
+// recordFromInstance maps a template instance onto an analytics record.
+// Shared by HandleAnalytics and the template-author validation endpoint so
+// the mapping they report is identical.
+func (h *handler) recordFromInstance(inst *analyticsT.Instance) analytics.Record {
+	record := analytics.Record{
+		ClientReceivedStartTimestamp: timeToUnix(inst.ClientReceivedStartTimestamp),
+		ClientReceivedEndTimestamp:   timeToUnix(inst.ClientReceivedStartTimestamp),
+		ClientSentStartTimestamp:     timeToUnix(inst.ClientSentStartTimestamp),
+		ClientSentEndTimestamp:       timeToUnix(inst.ClientSentEndTimestamp),
+		TargetReceivedStartTimestamp: timeToUnix(inst.TargetReceivedStartTimestamp),
+		TargetReceivedEndTimestamp:   timeToUnix(inst.TargetReceivedEndTimestamp),
+		TargetSentStartTimestamp:     timeToUnix(inst.TargetSentStartTimestamp),
+		TargetSentEndTimestamp:       timeToUnix(inst.TargetSentEndTimestamp),
+		APIProxy:                     inst.ApiProxy,
+		RequestURI:                   inst.RequestUri,
+		RequestVerb:                  inst.RequestVerb,
+		ClientIP:                     inst.ClientIp.String(),
+		UserAgent:                    inst.Useragent,
+		ResponseStatusCode:           int(inst.ResponseStatusCode),
+		GatewaySource:                gatewaySource,
+		SourceWorkload:               inst.SourceWorkload,
+		SourceWorkloadNamespace:      inst.SourceWorkloadNamespace,
+		DestinationWorkload:          inst.DestinationWorkload,
+		DestinationWorkloadNamespace: inst.DestinationWorkloadNamespace,
+		MeshID:                       inst.MeshId,
+	}
+
+	// the request id header is shared by every gateway the request
+	// traverses: flow ids derived from it (plus this cluster's suffix)
+	// are stable, and the bare id rides along as a dedupe hint so the
+	// same logical request reported by two clusters can be deduplicated
+	if inst.RequestId != "" {
+		record.DedupeKey = inst.RequestId
+		record.GatewayFlowID = inst.RequestId
+		if h.clusterID != "" {
+			record.GatewayFlowID = inst.RequestId + "-" + h.clusterID
+		}
+	}
+
+	// Apigee expects RequestURI to include query parameters. Istio's request.path matches this.
+	// However, Apigee expects RequestPath exclude query parameters and there is no corresponding
+	// Istio attribute. Thus, we need to drop the query params from request.path for RequestPath.
+	splits := strings.Split(inst.RequestPath, "?")
+	if len(splits) > 0 {
+		record.RequestPath = splits[0]
+	} else {
+		record.RequestPath = "/"
+	}
+
+	return record
+}
+
 // Handle processing and delivery of Analytics to Apigee
 func (h *handler) HandleAnalytics(ctx context.Context, instances []*analyticsT.Instance) error {
 	if len(instances) == 0 {
 		return nil
 	}
+	if len(instances) > h.limits.instances() {
+		limitHitsInstances.Add(1)
+		return h.Log().Errorf("HandleAnalytics: %d instances exceeds limit of %d, rejecting",
+			len(instances), h.limits.instances())
+	}
+	if instances = h.enforcedInstances(instances); len(instances) == 0 {
+		return nil
+	}
 	h.Log().Debugf("HandleAnalytics: %d instances", len(instances))
 
 	var authContext *auth.Context
 	var records = make([]analytics.Record, 0, len(instances))
 
 	for _, inst := range instances {
-		record := analytics.Record{
-			ClientReceivedStartTimestamp: timeToUnix(inst.ClientReceivedStartTimestamp),
-			ClientReceivedEndTimestamp:   timeToUnix(inst.ClientReceivedStartTimestamp),
-			ClientSentStartTimestamp:     timeToUnix(inst.ClientSentStartTimestamp),
-			ClientSentEndTimestamp:       timeToUnix(inst.ClientSentEndTimestamp),
-			TargetReceivedStartTimestamp: timeToUnix(inst.TargetReceivedStartTimestamp),
-			TargetReceivedEndTimestamp:   timeToUnix(inst.TargetReceivedEndTimestamp),
-			TargetSentStartTimestamp:     timeToUnix(inst.TargetSentStartTimestamp),
-			TargetSentEndTimestamp:       timeToUnix(inst.TargetSentEndTimestamp),
-			APIProxy:                     inst.ApiProxy,
-			RequestURI:                   inst.RequestUri,
-			RequestVerb:                  inst.RequestVerb,
-			ClientIP:                     inst.ClientIp.String(),
-			UserAgent:                    inst.Useragent,
-			ResponseStatusCode:           int(inst.ResponseStatusCode),
-			GatewaySource:                gatewaySource,
-		}
-
-		// Apigee expects RequestURI to include query parameters. Istio's request.path matches this.
-		// However, Apigee expects RequestPath exclude query parameters and there is no corresponding
-		// Istio attribute. Thus, we need to drop the query params from request.path for RequestPath.
-		splits := strings.Split(inst.RequestPath, "?")
-		if len(splits) > 0 {
-			record.RequestPath = splits[0]
-		} else {
-			record.RequestPath = "/"
-		}
+		record := h.recordFromInstance(inst)
 
 		// important: This assumes that the Auth is the same for all records!
 		if authContext == nil {
 			ac, _ := h.authMan.Authenticate(h, inst.ApiKey, h.resolveClaims(inst.ApiClaims), h.apiKeyClaimKey)
 			// ignore error, take whatever we have
 			authContext = ac
+
+			// map the Istio end-user principal to the Apigee developer if not set
+			if ac != nil && ac.DeveloperEmail == "" && h.developerPrincipalClaim != "" {
+				ac.DeveloperEmail = inst.ApiClaims[h.developerPrincipalClaim]
+			}
 		}
 
 		records = append(records, record)
 	}
 
+	// annotate each record with proxy revision, basepath and quota state
+	// from the product it will report (per record, since a credential with
+	// multiple products may select different products per record)
+	if authContext != nil {
+		var products product.ProductsMap
+		if h.productMan != nil {
+			products = h.productMan.Products()
+		}
+		for i := range records {
+			name := h.recordProduct(authContext, records[i])
+			if name == "" {
+				continue
+			}
+
+			if p := products[name]; p != nil {
+				if rev, ok := p.GetAttribute(product.ProxyRevisionAttr); ok {
+					if revision, err := strconv.Atoi(rev); err == nil {
+						records[i].APIProxyRevision = revision
+					}
+				}
+				records[i].Basepath, _ = p.GetAttribute(product.BasepathAttr)
+			}
+
+			if result := h.quotaMan.LatestResult(authContext, name); result != nil {
+				records[i].QuotaAllowed = result.Allowed
+				records[i].QuotaUsed = result.Used + result.Exceeded
+				records[i].QuotaExceeded = result.Exceeded
+			}
+		}
+	}
+
 	return h.analyticsMan.SendRecords(authContext, records)
 }
 
+// recordProduct is the product the record will report: its own APIProduct
+// if already selected, otherwise the one ensureFields will choose
+func (h *handler) recordProduct(authContext *auth.Context, record analytics.Record) string {
+	if record.APIProduct != "" {
+		return record.APIProduct
+	}
+	if len(authContext.APIProducts) > 0 {
+		return authContext.APIProducts[0]
+	}
+	return ""
+}
+
 // static values for HandleAuthorization...
 var quotaArgs = adapter.QuotaArgs{QuotaAmount: 1}
 var checkResultNil = adapter.CheckResult{}
@@ -431,14 +731,60 @@ var checkResultOkWithQuotas = adapter.CheckResult{
 	ValidUseCount: 1, // call adapter each time to ensure quotas are applied
 }
 var checkResultQuotaExceeded = adapter.CheckResult{
-	Status:        status.WithResourceExhausted("quota exceeded"),
-	ValidUseCount: 1, // call adapter each time to ensure quotas are applied
+	Status:        status.WithResourceExhausted("quota exceeded"), // HTTP 429
+	ValidUseCount: 1,                                              // call adapter each time to ensure quotas are applied
+}
+var checkResultQuotaExceededDenied = adapter.CheckResult{
+	Status:        status.WithPermissionDenied("quota exceeded"), // HTTP 403
+	ValidUseCount: 1,                                             // call adapter each time to ensure quotas are applied
 }
 var checkResultNotAuthorized = adapter.CheckResult{Status: status.WithPermissionDenied("permission denied")}
 var checkResultMissingAuth = adapter.CheckResult{Status: status.WithUnauthenticated("missing authentication")}
 
 // Handle Authentication, Authorization, and Quotas
 func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance) (adapter.CheckResult, error) {
+	var result adapter.CheckResult
+	var err error
+
+	// requests for non-enforced namespaces pass through
+	if !h.namespaceEnforced(inst.Action.Namespace) {
+		h.Log().Debugf("namespace %s not enforced", inst.Action.Namespace)
+		return checkResultOk, nil
+	}
+
+	cacheKey := h.authResultCacheKey(inst)
+	if h.resultCache != nil {
+		if cached, ok := h.resultCache.Get(cacheKey); ok {
+			result = cached.(adapter.CheckResult)
+			h.Log().Debugf("cached authorization result: %v", result.Status)
+			if h.authorizationMode != authModeShadow {
+				return result, nil
+			}
+		}
+	}
+
+	result, err = h.authorize(ctx, inst)
+
+	// results that don't involve quota counting (ValidUseCount unset) are
+	// safe to cache briefly; quota-counted results must hit the adapter
+	if h.resultCache != nil && err == nil && result.ValidUseCount == 0 {
+		h.resultCache.Set(cacheKey, result)
+	}
+
+	// in shadow mode the decision is logged but the request is always allowed,
+	// so authorization can be canaried without breaking traffic
+	if h.authorizationMode == authModeShadow {
+		if err != nil || result.Status.Code != checkResultOk.Status.Code {
+			h.Log().Infof("shadow authorization would have denied %s %s: %v %v",
+				inst.Action.Service, inst.Action.Path, result.Status, err)
+		}
+		return checkResultOkWithQuotas, nil
+	}
+
+	return result, err
+}
+
+func (h *handler) authorize(ctx context.Context, inst *authT.Instance) (adapter.CheckResult, error) {
 	if h.Log().DebugEnabled() {
 		redacts := []interface{}{
 			inst.Subject.Properties[apiKeyAttribute],
@@ -449,8 +795,14 @@ func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance)
 	}
 
 	claims := h.resolveClaimsInterface(inst.Subject.Properties)
-	apiKey, _ := inst.Subject.Properties[apiKeyAttribute].(string)
-	authContext, err := h.authMan.Authenticate(h, apiKey, claims, h.apiKeyClaimKey)
+	apiKey := h.extractAPIKey(inst.Subject.Properties, claims)
+	if len(apiKey) > h.limits.apiKeyBytes() {
+		limitHitsAPIKey.Add(1)
+		h.Log().Debugf("api_key exceeds %d bytes, rejecting", h.limits.apiKeyBytes())
+		return checkResultNotAuthorized, nil
+	}
+	// propagate the Mixer deadline to synchronous Apigee calls
+	authContext, err := h.authMan.Authenticate(auth.WithRequestContext(h, ctx), apiKey, claims, h.apiKeyClaimKey)
 	if err != nil {
 		h.Log().Debugf("authenticate err: %v", err)
 		switch err {
@@ -465,14 +817,38 @@ func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance)
 		}
 	}
 
-	products := h.productMan.Resolve(authContext, inst.Action.Service, inst.Action.Path)
+	// map the Istio end-user principal to the Apigee developer if not set
+	if authContext.DeveloperEmail == "" && h.developerPrincipalClaim != "" {
+		if principal, ok := claims[h.developerPrincipalClaim].(string); ok && principal != "" {
+			authContext.DeveloperEmail = principal
+		} else if inst.Subject.User != "" {
+			authContext.DeveloperEmail = inst.Subject.User
+		}
+	}
+
+	if !allowedByScopeRules(h.scopeRules, inst.Action.Path, authContext.Scopes) {
+		h.Log().Debugf("scope rules not satisfied for path: %s", inst.Action.Path)
+		return checkResultNotAuthorized, nil
+	}
+
+	products := h.productMan.Resolve(authContext, inst.Action.Service, inst.Action.Path, inst.Action.Method)
 	if len(products) == 0 {
 		return checkResultNotAuthorized, nil
 	}
 
+	// expose custom attributes of the matched products to downstream consumers
+	authContext.ProductAttributes = product.MergedAttributes(products)
+
+	// bucket anonymous traffic by the configured identifier attribute
+	if h.quotaIdentifierAttribute != "" && authContext.Application == "" {
+		if id, ok := inst.Subject.Properties[h.quotaIdentifierAttribute].(string); ok && id != "" {
+			authContext.QuotaIdentifier = scopedQuotaIdentifier(inst.Action.Namespace, inst.Action.Service, id)
+		}
+	}
+
 	var anyQuotas, exceeded bool
 	var anyError error
-	// apply to all matching products
+	// apply to matching products according to the configured mode
 	for _, p := range products {
 		if p.QuotaLimitInt > 0 {
 			anyQuotas = true
@@ -482,6 +858,9 @@ func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance)
 			} else if result.Exceeded > 0 {
 				exceeded = true
 			}
+			if h.multipleProductsMode == multiProductFirst {
+				break // only the first matching product with a quota counts
+			}
 		}
 	}
 	if anyError != nil {
@@ -490,6 +869,9 @@ func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance)
 	}
 	if exceeded {
 		h.Log().Debugf("quota exceeded: %v", err)
+		if h.quotaExceededStatus == quotaExceeded403 {
+			return checkResultQuotaExceededDenied, nil
+		}
 		return checkResultQuotaExceeded, nil
 	}
 
@@ -504,15 +886,82 @@ func (h *handler) HandleAuthorization(ctx context.Context, inst *authT.Instance)
 	return okResult, nil
 }
 
+// namespaceEnforced is false if the namespace is excluded, or if an
+// enablement list is configured and doesn't include it. A missing namespace
+// attribute fails closed: the request is enforced (and reported).
+func (h *handler) namespaceEnforced(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	for _, ns := range h.excludedNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(h.enabledNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range h.enabledNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// enforcedInstances filters out analytics instances for namespaces that are
+// not enforced: excluded namespaces get OK on authorization and no analytics
+func (h *handler) enforcedInstances(instances []*analyticsT.Instance) []*analyticsT.Instance {
+	if len(h.enabledNamespaces) == 0 && len(h.excludedNamespaces) == 0 {
+		return instances
+	}
+	kept := make([]*analyticsT.Instance, 0, len(instances))
+	for _, inst := range instances {
+		if h.namespaceEnforced(inst.DestinationWorkloadNamespace) {
+			kept = append(kept, inst)
+		}
+	}
+	if dropped := len(instances) - len(kept); dropped > 0 {
+		h.Log().Debugf("HandleAnalytics: %d instances for non-enforced namespaces not reported", dropped)
+	}
+	return kept
+}
+
+// extractAPIKey returns the API key from the first configured location
+// carrying one, falling back to the default api_key property. A location is
+// a subject property name, or "claim:<name>" to read a (possibly nested)
+// claim - the same dimensions the authorization cache is keyed on.
+func (h *handler) extractAPIKey(properties map[string]interface{}, claims map[string]interface{}) string {
+	for _, attribute := range h.apiKeyAttributes {
+		if name := strings.TrimPrefix(attribute, apiKeyClaimPrefix); name != attribute {
+			if key, ok := auth.ClaimValue(claims, name).(string); ok && key != "" {
+				return key
+			}
+			continue
+		}
+		if key, ok := properties[attribute].(string); ok && key != "" {
+			return key
+		}
+	}
+	key, _ := properties[apiKeyAttribute].(string)
+	return key
+}
+
 // resolveClaims ensures that jwt auth claims are properly populated from an
 // incoming map of potential claims values--including extraneous filtering.
 func (h *handler) resolveClaims(claimsIn map[string]string) map[string]interface{} {
 	var claims = map[string]interface{}{}
 
 	if encoded, ok := claimsIn[jsonClaimsKey]; ok && encoded != "" {
-		err := json.Unmarshal([]byte(encoded), &claims)
-		if err != nil {
+		if len(encoded) > h.limits.jsonClaimsBytes() {
+			limitHitsJSONClaims.Add(1)
+			h.Log().Errorf("%s exceeds %d bytes, ignoring", jsonClaimsKey, h.limits.jsonClaimsBytes())
+		} else if err := json.Unmarshal([]byte(encoded), &claims); err != nil {
 			h.Log().Errorf("error resolving %s claims: %v, data: %v", jsonClaimsKey, err, encoded)
+		} else if len(claims) > h.limits.claimsCount() {
+			limitHitsClaims.Add(1)
+			h.Log().Errorf("%s has %d claims, exceeds limit of %d, ignoring", jsonClaimsKey, len(claims), h.limits.claimsCount())
+			claims = map[string]interface{}{}
 		}
 	}
 
@@ -526,6 +975,10 @@ func (h *handler) resolveClaims(claimsIn map[string]string) map[string]interface
 		claims[h.apiKeyClaimKey] = claimsIn[h.apiKeyClaimKey]
 	}
 
+	if h.developerPrincipalClaim != "" && claimsIn[h.developerPrincipalClaim] != "" {
+		claims[h.developerPrincipalClaim] = claimsIn[h.developerPrincipalClaim]
+	}
+
 	return claims
 }
 
@@ -391,6 +391,120 @@ func TestResolveClaims(t *testing.T) {
 	}
 }
 
+func TestExtractAPIKey(t *testing.T) {
+	h := handler{
+		apiKeyAttributes: []string{"x-custom-key", "claim:identity.api_key"},
+	}
+
+	properties := map[string]interface{}{
+		apiKeyAttribute: "defaultKey",
+	}
+	claims := map[string]interface{}{
+		"identity": map[string]interface{}{
+			"api_key": "claimKey",
+		},
+	}
+
+	// configured locations are checked in order
+	properties["x-custom-key"] = "customKey"
+	if got := h.extractAPIKey(properties, claims); got != "customKey" {
+		t.Errorf("want customKey, got %s", got)
+	}
+
+	// then claim locations
+	delete(properties, "x-custom-key")
+	if got := h.extractAPIKey(properties, claims); got != "claimKey" {
+		t.Errorf("want claimKey, got %s", got)
+	}
+
+	// then the default property
+	if got := h.extractAPIKey(properties, nil); got != "defaultKey" {
+		t.Errorf("want defaultKey, got %s", got)
+	}
+
+	// the cache key must reflect the configured key location
+	inst := func(props map[string]interface{}) *authorization.Instance {
+		return &authorization.Instance{
+			Subject: &authorization.Subject{Properties: props},
+			Action:  &authorization.Action{Service: "svc", Path: "/path"},
+		}
+	}
+	key1 := h.authResultCacheKey(inst(map[string]interface{}{"x-custom-key": "client1"}))
+	key2 := h.authResultCacheKey(inst(map[string]interface{}{"x-custom-key": "client2"}))
+	if key1 == key2 {
+		t.Errorf("different clients must not share a cache key")
+	}
+}
+
+func TestNamespaceEnforced(t *testing.T) {
+	h := handler{}
+	if !h.namespaceEnforced("any") {
+		t.Errorf("no lists should enforce everywhere")
+	}
+
+	h.excludedNamespaces = []string{"istio-system"}
+	if h.namespaceEnforced("istio-system") {
+		t.Errorf("excluded namespace should not be enforced")
+	}
+	if !h.namespaceEnforced("default") {
+		t.Errorf("other namespaces should be enforced")
+	}
+
+	h.enabledNamespaces = []string{"prod", "istio-system"}
+	if !h.namespaceEnforced("prod") {
+		t.Errorf("enabled namespace should be enforced")
+	}
+	if h.namespaceEnforced("default") {
+		t.Errorf("namespace outside the enablement list should not be enforced")
+	}
+	if h.namespaceEnforced("istio-system") {
+		t.Errorf("exclusion should win over enablement")
+	}
+
+	// a missing namespace attribute fails closed
+	if !h.namespaceEnforced("") {
+		t.Errorf("missing namespace must be enforced")
+	}
+}
+
+func TestScopedQuotaIdentifier(t *testing.T) {
+	id := scopedQuotaIdentifier("ns", "svc.ns.svc.cluster.local", "client-1")
+	if id != "ns/svc.ns.svc.cluster.local/client-1" {
+		t.Errorf("unexpected identifier: %s", id)
+	}
+
+	// same client id in different namespaces gets different buckets
+	other := scopedQuotaIdentifier("other", "svc.ns.svc.cluster.local", "client-1")
+	if id == other {
+		t.Errorf("identifiers should be namespace-scoped")
+	}
+
+	// oversized identifiers are hashed, not trusted as-is
+	huge := strings.Repeat("x", maxQuotaIdentifierLength+1)
+	hashed := scopedQuotaIdentifier("ns", "svc", huge)
+	if strings.Contains(hashed, huge) {
+		t.Errorf("oversized identifier should be hashed")
+	}
+	if hashed != scopedQuotaIdentifier("ns", "svc", huge) {
+		t.Errorf("hashed identifiers should be stable")
+	}
+}
+
+func TestOversizedJSONClaims(t *testing.T) {
+	h := handler{
+		env: test.NewEnv(t),
+	}
+
+	// oversized json_claims should be ignored, not parsed
+	huge := fmt.Sprintf(`{"x":"%s"}`, strings.Repeat("y", maxJSONClaimsLength))
+	claimsOut := h.resolveClaims(map[string]string{
+		jsonClaimsKey: huge,
+	})
+	if len(claimsOut) != 0 {
+		t.Errorf("oversized %s should be ignored, got: %v", jsonClaimsKey, claimsOut)
+	}
+}
+
 func TestToDuration(t *testing.T) {
 	expected := time.Second + time.Nanosecond
 	protoDuration := pbtypes.DurationProto(expected)
@@ -0,0 +1,106 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+)
+
+// RegisterAnalyticsAlerts registers f to receive operational alerts from the
+// tenant's analytics pipeline (upload backlog, failure streaks, dropped
+// records). f must not block.
+func RegisterAnalyticsAlerts(tenant *ApigeeHandler, f func(analytics.Alert)) error {
+	h, ok := tenant.handler.(*handler)
+	if !ok || h.analyticsMan == nil {
+		return fmt.Errorf("tenant has no analytics manager")
+	}
+	h.analyticsMan.RegisterAlertFunc(f)
+	return nil
+}
+
+// AdminHandler returns an HTTP handler exposing operational controls for a
+// standalone tenant:
+//
+//	POST /quota/sync         - force an immediate sync of all quota buckets
+//	GET  /quota/stats        - quota manager counters as JSON
+//	GET  /metrics            - quota sync histograms in Prometheus text format
+//	POST /analytics/interval - override the analytics collection interval
+//	                           (form value "interval", eg. 30s; 0 restores)
+//	GET  /debug/vars         - expvar counters (including request limit hits)
+//
+// Serve it on a loopback or otherwise protected address; it is not
+// authenticated.
+func AdminHandler(tenant *ApigeeHandler) (http.Handler, error) {
+	h, ok := tenant.handler.(*handler)
+	if !ok || h.quotaMan == nil {
+		return nil, fmt.Errorf("tenant has no quota manager")
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/quota/sync", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := h.quotaMan.ForceSyncAll(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/quota/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.quotaMan.Stats())
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		h.quotaMan.WriteMetrics(w)
+	})
+
+	mux.HandleFunc("/analytics/interval", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		d, err := time.ParseDuration(r.FormValue("interval"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad interval: %v", err), http.StatusBadRequest)
+			return
+		}
+		h.analyticsMan.SetCollectionInterval(d)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return mux, nil
+}
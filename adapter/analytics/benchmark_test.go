@@ -0,0 +1,110 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func benchmarkRecords(n int, ts int64) []Record {
+	records := make([]Record, 0, n)
+	for i := 0; i < n; i++ {
+		records = append(records, Record{
+			Organization:                 "hi",
+			Environment:                  "test",
+			APIProxy:                     "proxy",
+			RequestURI:                   "/path?with=query",
+			RequestPath:                  "/path",
+			RequestVerb:                  "GET",
+			UserAgent:                    "benchmark",
+			ResponseStatusCode:           200,
+			GatewaySource:                "istio",
+			ClientReceivedStartTimestamp: ts * 1000,
+			ClientReceivedEndTimestamp:   ts * 1000,
+		})
+	}
+	return records
+}
+
+// BenchmarkSendRecords measures the write path: validation, bucket routing
+// and gzip encoding of record batches.
+func BenchmarkSendRecords(b *testing.B) {
+	env := NewStandaloneEnv()
+
+	workDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		b.Fatalf("ioutil.TempDir(): %s", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	ts := time.Now().Unix()
+	now := func() time.Time { return time.Unix(ts, 0) }
+
+	uploader := &saasUploader{
+		log:     env.Logger(),
+		client:  http.DefaultClient,
+		baseURL: &url.URL{},
+		key:     "key",
+		secret:  "secret",
+		now:     now,
+	}
+
+	m, err := newManager(uploader, Options{
+		BufferPath:         workDir,
+		StagingFileLimit:   1024,
+		now:                now,
+		CollectionInterval: time.Minute,
+		SendChannelSize:    100,
+	})
+	if err != nil {
+		b.Fatalf("newManager: %s", err)
+	}
+	m.Start(env)
+	defer m.Close()
+
+	tenant := Tenant{Org: "hi", Env: "test", Key: "key", Secret: "secret"}
+
+	records := benchmarkRecords(10, ts)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.SendTenantRecords(tenant, records); err != nil {
+			b.Fatalf("SendRecords: %s", err)
+		}
+	}
+}
+
+// BenchmarkRecordValidate measures per-record validation overhead.
+func BenchmarkRecordValidate(b *testing.B) {
+	ts := time.Now().Unix()
+	record := benchmarkRecords(1, ts)[0]
+	record.GatewayFlowID = "x"
+	now := time.Unix(ts, 0)
+	rules := defaultValidationRules()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := record.validate(now, rules); err != nil {
+			b.Fatalf("validate: %s", err)
+		}
+	}
+}
@@ -23,6 +23,14 @@ import (
 	"sync"
 )
 
+// gzip writers are pooled across buckets: their buffers are large and every
+// collection interval creates a fresh bucket per tenant
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
 func newBucket(m *manager, up uploader, tenant, dir string) (*bucket, error) {
 	b := &bucket{
 		manager:  m,
@@ -44,12 +52,28 @@ func newBucket(m *manager, up uploader, tenant, dir string) (*bucket, error) {
 		m.log.Errorf("AX Records lost. Can't create bucket file: %s", err)
 		return nil, err
 	}
+
+	// data flows: records -> (gzip) -> (encryption) -> file
+	var sink io.Writer = f
+	var sinkCloser io.Closer
+	if m.crypter != nil {
+		cw, err := m.crypter.encryptWriter(f)
+		if err != nil {
+			m.log.Errorf("AX Records lost. Can't encrypt bucket file: %s", err)
+			f.Close()
+			return nil, err
+		}
+		sink, sinkCloser = cw, cw
+	}
 	b.w = &fileWriter{
 		file:   f,
-		writer: f,
+		writer: sink,
+		sink:   sinkCloser,
 	}
 	if up.isGzipped() {
-		b.w.writer = gzip.NewWriter(f)
+		gzw := gzipWriterPool.Get().(*gzip.Writer)
+		gzw.Reset(sink)
+		b.w.writer = gzw
 	}
 
 	m.env.ScheduleDaemon(b.runLoop)
@@ -65,6 +89,7 @@ type bucket struct {
 	w        *fileWriter
 	incoming chan []Record
 	wait     *sync.WaitGroup
+	sequence int64 // per-bucket record sequence, stamped in runLoop
 }
 
 // write records to bucket
@@ -88,6 +113,11 @@ func (b *bucket) runLoop() {
 	log := b.manager.log
 
 	for records := range b.incoming {
+		// stamp sequence IDs so truncated or replayed files can be detected downstream
+		for i := range records {
+			b.sequence++
+			records[i].GatewaySequence = b.sequence
+		}
 		b.uploader.write(records, b.w.writer)
 	}
 
@@ -106,6 +136,7 @@ func (b *bucket) runLoop() {
 type fileWriter struct {
 	file   *os.File
 	writer io.Writer
+	sink   io.Closer // encryption sink, flushed before the file closes
 }
 
 func (w *fileWriter) close() error {
@@ -113,6 +144,13 @@ func (w *fileWriter) close() error {
 		if err := gzw.Close(); err != nil {
 			return fmt.Errorf("gz.Close: %s", err)
 		}
+		gzipWriterPool.Put(gzw)
+	}
+
+	if w.sink != nil {
+		if err := w.sink.Close(); err != nil {
+			return fmt.Errorf("sink.Close: %s", err)
+		}
 	}
 
 	if err := w.file.Close(); err != nil {
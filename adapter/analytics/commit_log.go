@@ -0,0 +1,115 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	commitLogName = "commits.log"
+
+	// the log is compacted to this many entries on startup
+	maxCommitLogEntries = 5000
+)
+
+// A commitLog durably records the names of uploaded files. Uploads are
+// acknowledged by appending to the log before the file is deleted, so if a
+// crash leaves an uploaded file behind, recovery can drop it instead of
+// re-uploading - at-least-once upload without duplicates on the common
+// crash paths.
+type commitLog struct {
+	lock      sync.Mutex
+	path      string
+	file      *os.File
+	committed map[string]bool
+}
+
+// newCommitLog loads (and compacts) any existing log in dir and opens it for
+// appending.
+func newCommitLog(dir string) (*commitLog, error) {
+	c := &commitLog{
+		path:      filepath.Join(dir, commitLogName),
+		committed: map[string]bool{},
+	}
+
+	// load existing entries
+	if f, err := os.Open(c.path); err == nil {
+		var entries []string
+		scan := bufio.NewScanner(f)
+		for scan.Scan() {
+			if name := scan.Text(); name != "" {
+				entries = append(entries, name)
+			}
+		}
+		f.Close()
+		if err := scan.Err(); err != nil {
+			return nil, fmt.Errorf("read %s: %s", c.path, err)
+		}
+
+		// compact: bound the log and drop duplicates
+		if len(entries) > maxCommitLogEntries {
+			entries = entries[len(entries)-maxCommitLogEntries:]
+		}
+		for _, name := range entries {
+			c.committed[name] = true
+		}
+	}
+
+	f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", c.path, err)
+	}
+	for name := range c.committed {
+		fmt.Fprintln(f, name)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("sync %s: %s", c.path, err)
+	}
+	c.file = f
+	return c, nil
+}
+
+// commit durably records the (base) file name as uploaded
+func (c *commitLog) commit(fileName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.committed[fileName] {
+		return nil
+	}
+	c.committed[fileName] = true
+	if _, err := fmt.Fprintln(c.file, fileName); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// isCommitted is true if the (base) file name was recorded as uploaded
+func (c *commitLog) isCommitted(fileName string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.committed[fileName]
+}
+
+func (c *commitLog) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.file.Close()
+}
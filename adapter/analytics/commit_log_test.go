@@ -0,0 +1,61 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCommitLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "commitlog")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := newCommitLog(dir)
+	if err != nil {
+		t.Fatalf("newCommitLog: %s", err)
+	}
+
+	if c.isCommitted("file1.gz") {
+		t.Errorf("nothing should be committed yet")
+	}
+	if err := c.commit("file1.gz"); err != nil {
+		t.Fatalf("commit: %s", err)
+	}
+	if err := c.commit("file2.gz"); err != nil {
+		t.Fatalf("commit: %s", err)
+	}
+	if !c.isCommitted("file1.gz") || !c.isCommitted("file2.gz") {
+		t.Errorf("commits should be recorded")
+	}
+	c.close()
+
+	// reopen: committed entries survive a restart
+	c, err = newCommitLog(dir)
+	if err != nil {
+		t.Fatalf("newCommitLog: %s", err)
+	}
+	defer c.close()
+	if !c.isCommitted("file1.gz") || !c.isCommitted("file2.gz") {
+		t.Errorf("commits should survive a restart")
+	}
+	if c.isCommitted("file3.gz") {
+		t.Errorf("file3.gz should not be committed")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// each file starts with a random base nonce; content follows as
+	// length-prefixed AES-GCM sealed chunks so tampering is detected
+	gcmNonceSize = 12
+	gcmChunkSize = 64 * 1024
+)
+
+// A fileCrypter encrypts analytics buffer files at rest with AES-GCM.
+// Files carry a random base nonce followed by length-prefixed sealed chunks
+// (per-chunk nonces derived by counter), giving authenticated encryption for
+// streamed writes. Files are decrypted transparently when uploaded or
+// recovered; any bit flip fails authentication.
+type fileCrypter struct {
+	aead cipher.AEAD
+}
+
+// newFileCrypter creates a crypter from a 16, 24 or 32 byte AES key
+func newFileCrypter(key []byte) (*fileCrypter, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %s", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %s", err)
+	}
+	return &fileCrypter{aead: aead}, nil
+}
+
+// chunkNonce derives the nonce for chunk n from the file's base nonce
+func chunkNonce(base []byte, n uint32) []byte {
+	nonce := make([]byte, gcmNonceSize)
+	copy(nonce, base)
+	binary.BigEndian.PutUint32(nonce[gcmNonceSize-4:],
+		binary.BigEndian.Uint32(nonce[gcmNonceSize-4:])^n)
+	return nonce
+}
+
+// encryptWriter writes a fresh base nonce to w and returns a writer sealing
+// everything written to it in chunks. Close must be called to flush the
+// final chunk.
+func (fc *fileCrypter) encryptWriter(w io.Writer) (io.WriteCloser, error) {
+	base := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(base); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(base); err != nil {
+		return nil, err
+	}
+	return &chunkWriter{
+		aead: fc.aead,
+		w:    w,
+		base: base,
+		buf:  make([]byte, 0, gcmChunkSize),
+	}, nil
+}
+
+type chunkWriter struct {
+	aead    cipher.AEAD
+	w       io.Writer
+	base    []byte
+	counter uint32
+	buf     []byte
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		space := gcmChunkSize - len(cw.buf)
+		if space > len(p) {
+			space = len(p)
+		}
+		cw.buf = append(cw.buf, p[:space]...)
+		p = p[space:]
+		if len(cw.buf) == gcmChunkSize {
+			if err := cw.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close flushes the final partial chunk
+func (cw *chunkWriter) Close() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	return cw.flush()
+}
+
+func (cw *chunkWriter) flush() error {
+	sealed := cw.aead.Seal(nil, chunkNonce(cw.base, cw.counter), cw.buf, nil)
+	cw.counter++
+	cw.buf = cw.buf[:0]
+
+	var frameLen [4]byte
+	binary.BigEndian.PutUint32(frameLen[:], uint32(len(sealed)))
+	if _, err := cw.w.Write(frameLen[:]); err != nil {
+		return err
+	}
+	_, err := cw.w.Write(sealed)
+	return err
+}
+
+// decryptReader reads the base nonce from r and returns a reader yielding
+// authenticated plaintext, erroring on any tampered chunk
+func (fc *fileCrypter) decryptReader(r io.Reader) (io.Reader, error) {
+	base := make([]byte, gcmNonceSize)
+	if _, err := io.ReadFull(r, base); err != nil {
+		return nil, fmt.Errorf("read nonce: %s", err)
+	}
+	return &chunkReader{
+		aead: fc.aead,
+		r:    r,
+		base: base,
+	}, nil
+}
+
+type chunkReader struct {
+	aead    cipher.AEAD
+	r       io.Reader
+	base    []byte
+	counter uint32
+	buf     []byte
+	err     error
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for len(cr.buf) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		cr.err = cr.readChunk()
+	}
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	return n, nil
+}
+
+func (cr *chunkReader) readChunk() error {
+	var frameLen [4]byte
+	if _, err := io.ReadFull(cr.r, frameLen[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("truncated encrypted frame")
+		}
+		return err // io.EOF at a frame boundary is a clean end
+	}
+	sealed := make([]byte, binary.BigEndian.Uint32(frameLen[:]))
+	if _, err := io.ReadFull(cr.r, sealed); err != nil {
+		return fmt.Errorf("truncated encrypted frame")
+	}
+	plain, err := cr.aead.Open(nil, chunkNonce(cr.base, cr.counter), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("decrypt: %s", err)
+	}
+	cr.counter++
+	cr.buf = plain
+	return nil
+}
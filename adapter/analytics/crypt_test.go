@@ -0,0 +1,95 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFileCrypterRoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef") // 32 bytes
+	fc, err := newFileCrypter(key)
+	if err != nil {
+		t.Fatalf("newFileCrypter: %s", err)
+	}
+
+	// larger than one chunk so the frame boundary is exercised
+	plaintext := bytes.Repeat([]byte(`{"organization":"hi","environment":"test"}`+"\n"), 2000)
+
+	var buf bytes.Buffer
+	w, err := fc.encryptWriter(&buf)
+	if err != nil {
+		t.Fatalf("encryptWriter: %s", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	if bytes.Contains(buf.Bytes(), plaintext[:64]) {
+		t.Errorf("plaintext should not appear in encrypted output")
+	}
+
+	r, err := fc.decryptReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decryptReader: %s", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %s", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("round trip mismatch: %d vs %d bytes", len(got), len(plaintext))
+	}
+}
+
+func TestFileCrypterTamperDetection(t *testing.T) {
+	key := []byte("0123456789abcdef") // 16 bytes
+	fc, err := newFileCrypter(key)
+	if err != nil {
+		t.Fatalf("newFileCrypter: %s", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := fc.encryptWriter(&buf)
+	if err != nil {
+		t.Fatalf("encryptWriter: %s", err)
+	}
+	w.Write([]byte("authenticated analytics data"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	// flip one ciphertext bit: GCM must refuse to decrypt
+	tampered := buf.Bytes()
+	tampered[len(tampered)-1] ^= 0x01
+	r, err := fc.decryptReader(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("decryptReader: %s", err)
+	}
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Errorf("tampered content must fail authentication")
+	}
+}
+
+func TestFileCrypterBadKey(t *testing.T) {
+	if _, err := newFileCrypter([]byte("short")); err == nil {
+		t.Errorf("bad key length should be rejected")
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/util"
@@ -48,7 +50,18 @@ func newHybridUploader(opts Options, env adapter.Env) (*hybridUploader, error) {
 	if err != nil {
 		return nil, err
 	}
-	addr := props[udcaEndpointKey]
+
+	// the endpoint property may list several regional endpoints (comma
+	// delimited) for failover
+	var addrs []string
+	for _, addr := range strings.Split(props[udcaEndpointKey], ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no UDCA endpoint in %s", opts.HybridConfigFile)
+	}
 
 	tlsConfig, err := loadTLSConfig(props)
 	if err != nil {
@@ -57,7 +70,7 @@ func newHybridUploader(opts Options, env adapter.Env) (*hybridUploader, error) {
 
 	return &hybridUploader{
 		network:    "tcp",
-		addr:       addr,
+		addrs:      addrs,
 		tlsConfig:  tlsConfig,
 		env:        env,
 		now:        opts.now,
@@ -68,12 +81,35 @@ func newHybridUploader(opts Options, env adapter.Env) (*hybridUploader, error) {
 
 type hybridUploader struct {
 	network    string
-	addr       string
+	addrs      []string // UAP endpoints, in failover order
+	preferred  int32    // index of the last endpoint that worked
 	tlsConfig  *tls.Config
 	env        adapter.Env
 	now        func() time.Time
 	log        adapter.Logger
 	clientUUID string
+	crypter    *fileCrypter // optional encryption-at-rest of buffer files
+}
+
+// dial connects to the preferred endpoint, failing over across the
+// configured regional endpoints and remembering whichever one worked
+func (h *hybridUploader) dial() (*tls.Conn, error) {
+	start := int(atomic.LoadInt32(&h.preferred))
+	var lastErr error
+	for i := 0; i < len(h.addrs); i++ {
+		idx := (start + i) % len(h.addrs)
+		client, err := tls.Dial(h.network, h.addrs[idx], h.tlsConfig)
+		if err == nil {
+			if idx != start {
+				h.log.Warningf("UAP endpoint failover to %s", h.addrs[idx])
+				atomic.StoreInt32(&h.preferred, int32(idx))
+			}
+			return client, nil
+		}
+		h.log.Errorf("dial %s: %s", h.addrs[idx], err)
+		lastErr = err
+	}
+	return nil, lastErr
 }
 
 func (h *hybridUploader) isGzipped() bool {
@@ -120,9 +156,8 @@ func (h *hybridUploader) write(incoming []Record, writer io.Writer) error {
 // upload sends a file to UDCA
 func (h *hybridUploader) upload(fileName string) error {
 
-	client, err := tls.Dial(h.network, h.addr, h.tlsConfig)
+	client, err := h.dial()
 	if err != nil {
-		h.log.Errorf("dial: %s", err)
 		return err
 	}
 	defer client.Close()
@@ -134,7 +169,15 @@ func (h *hybridUploader) upload(fileName string) error {
 	}
 	defer file.Close()
 
-	_, err = io.Copy(client, file)
+	var content io.Reader = file
+	if h.crypter != nil {
+		if content, err = h.crypter.decryptReader(file); err != nil {
+			h.log.Errorf("decrypt: %s: %v", fileName, err)
+			return err
+		}
+	}
+
+	_, err = io.Copy(client, content)
 	return err
 }
 
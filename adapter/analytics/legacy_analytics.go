@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/auth"
 	"istio.io/istio/mixer/pkg/adapter"
@@ -36,6 +37,18 @@ type legacyAnalytics struct {
 func (oa *legacyAnalytics) Start(env adapter.Env) error { return nil }
 func (oa *legacyAnalytics) Close()                      {}
 
+// RegisterAlertFunc is a no-op: the legacy protocol submits directly and
+// surfaces failures as SendRecords errors.
+func (oa *legacyAnalytics) RegisterAlertFunc(f func(Alert)) {}
+
+// SetCollectionInterval is a no-op: the legacy protocol does not buffer.
+func (oa *legacyAnalytics) SetCollectionInterval(d time.Duration) {}
+
+// SendTenantRecords sends records for a Tenant, for use outside of Mixer
+func (oa *legacyAnalytics) SendTenantRecords(tenant Tenant, records []Record) error {
+	return oa.SendRecords(tenant.Context(), records)
+}
+
 func (oa *legacyAnalytics) SendRecords(auth *auth.Context, records []Record) error {
 	axURL := *auth.ApigeeBase()
 	axURL.Path = path.Join(axURL.Path, fmt.Sprintf(axPath, auth.Organization(), auth.Environment()))
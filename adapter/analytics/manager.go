@@ -16,17 +16,25 @@ package analytics
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/auth"
 	"github.com/apigee/istio-mixer-adapter/adapter/util"
+	"github.com/hashicorp/go-multierror"
 	"istio.io/istio/mixer/pkg/adapter"
 )
 
@@ -35,6 +43,38 @@ type Manager interface {
 	Start(env adapter.Env) error
 	Close()
 	SendRecords(ctx *auth.Context, records []Record) error
+	// SendTenantRecords sends records for a Tenant, for use outside of Mixer
+	SendTenantRecords(tenant Tenant, records []Record) error
+	// RegisterAlertFunc registers f to receive operational Alerts from the
+	// pipeline. f must not block; at most one registration is kept.
+	RegisterAlertFunc(f func(Alert))
+	// SetCollectionInterval overrides the staging interval at runtime; a
+	// non-positive value removes the override
+	SetCollectionInterval(d time.Duration)
+}
+
+// Alert kinds passed to a registered alert func.
+const (
+	// AlertBacklog fires when the staged upload backlog crosses its
+	// threshold, and again when it drains
+	AlertBacklog = "backlog"
+	// AlertUploadFailures fires when consecutive upload failures reach a
+	// streak threshold
+	AlertUploadFailures = "upload_failures"
+	// AlertDroppedRecords fires when records are dropped as invalid
+	AlertDroppedRecords = "dropped_records"
+)
+
+// uploadFailureAlertStreak is the consecutive-failure count that triggers
+// an AlertUploadFailures
+const uploadFailureAlertStreak = 5
+
+// An Alert reports an operational condition in the analytics pipeline that
+// likely needs operator attention.
+type Alert struct {
+	Kind    string
+	Message string
+	Value   int64
 }
 
 // NewManager constructs and starts a new manager. Call Close when you are done.
@@ -44,13 +84,42 @@ func NewManager(env adapter.Env, opts Options) (Manager, error) {
 	}
 
 	if opts.now == nil {
-		opts.now = time.Now
+		if opts.Clock != nil {
+			opts.now = opts.Clock
+		} else {
+			opts.now = time.Now
+		}
 	}
 
 	if err := opts.validate(); err != nil {
 		return nil, err
 	}
 
+	var crypter *fileCrypter
+	if len(opts.EncryptionKey) > 0 {
+		var err error
+		if crypter, err = newFileCrypter(opts.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	location := time.UTC
+	if opts.UploadTimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(opts.UploadTimeZone); err != nil {
+			return nil, fmt.Errorf("upload timezone: %s", err)
+		}
+	}
+
+	var mirrors []*url.URL
+	for _, mirror := range opts.MirrorURLs {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return nil, fmt.Errorf("mirror url %s: %s", mirror, err)
+		}
+		mirrors = append(mirrors, u)
+	}
+
 	var uploader uploader
 	if opts.HybridConfigFile != "" { // Hybrid
 		var err error
@@ -58,14 +127,20 @@ func NewManager(env adapter.Env, opts Options) (Manager, error) {
 		if err != nil {
 			return nil, err
 		}
+		uploader.(*hybridUploader).crypter = crypter
 	} else { // SaaS
 		uploader = &saasUploader{
-			log:     env.Logger(),
-			client:  opts.Client,
-			baseURL: opts.BaseURL,
-			key:     opts.Key,
-			secret:  opts.Secret,
-			now:     opts.now,
+			log:        env.Logger(),
+			client:     opts.Client,
+			baseURL:    opts.BaseURL,
+			key:        opts.Key,
+			secret:     opts.Secret,
+			now:        opts.now,
+			uploadMode: opts.UploadMode,
+			crypter:    crypter,
+			location:   location,
+			window:     opts.CollectionInterval,
+			mirrors:    mirrors,
 		}
 	}
 
@@ -92,9 +167,45 @@ func newManager(uploader uploader, opts Options) (*manager, error) {
 		return nil, fmt.Errorf("mkdir %s: %s", sd, err)
 	}
 
+	commits, err := newCommitLog(opts.BufferPath)
+	if err != nil {
+		return nil, fmt.Errorf("commit log: %s", err)
+	}
+
+	var crypter *fileCrypter
+	if len(opts.EncryptionKey) > 0 {
+		if crypter, err = newFileCrypter(opts.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	backlogThreshold := opts.BacklogAlertThreshold
+	if backlogThreshold <= 0 {
+		backlogThreshold = opts.StagingFileLimit * 3 / 4
+	}
+
+	scrub, err := newScrubber(opts.ScrubFields)
+	if err != nil {
+		return nil, err
+	}
+
+	validation := defaultValidationRules()
+	if opts.MaxRecordAge > 0 {
+		validation.maxAge = opts.MaxRecordAge
+	}
+	if opts.MaxRecordSkew > 0 {
+		validation.maxSkew = opts.MaxRecordSkew
+	}
+
 	return &manager{
 		closeStaging:       make(chan bool),
 		now:                opts.now,
+		samplingRate:       opts.SamplingRate,
+		validation:         validation,
+		scrub:              scrub,
+		mirrorDir:          opts.DebugMirrorDir,
+		backlogAlert:       opts.BacklogAlert,
+		backlogThreshold:   backlogThreshold,
 		collectionInterval: opts.CollectionInterval,
 		tempDir:            td,
 		stagingDir:         sd,
@@ -102,6 +213,11 @@ func newManager(uploader uploader, opts Options) (*manager, error) {
 		buckets:            map[string]*bucket{},
 		sendChannelSize:    opts.SendChannelSize,
 		uploader:           uploader,
+		commitLog:          commits,
+		retryBudget:        newRetryBudget(retryBudgetLimit, retryBudgetWindow, opts.now),
+		crypter:            crypter,
+		tenantIntervals:    opts.TenantCollectionIntervals,
+		lastStaged:         map[string]time.Time{},
 	}, nil
 }
 
@@ -110,6 +226,13 @@ type manager struct {
 	env                adapter.Env
 	closeStaging       chan bool
 	now                func() time.Time
+	samplingRate       float64
+	validation         validationRules
+	scrub              func(*Record)
+	mirrorDir          string
+	backlogAlert       func(int)
+	backlogThreshold   int
+	backlogAlerted     bool
 	log                adapter.Logger
 	collectionInterval time.Duration
 	tempDir            string // open files being written to
@@ -123,6 +246,16 @@ type manager struct {
 	uploadChan         chan<- interface{}
 	uploadersWait      sync.WaitGroup
 	uploader           uploader
+	commitLog          *commitLog
+	retryBudget        *retryBudget
+	crypter            *fileCrypter
+	tenantIntervals    map[string]time.Duration // per-tenant staging overrides
+	lastStaged         map[string]time.Time     // guarded by bucketsLock
+	alertLock          sync.Mutex
+	alertFunc          func(Alert)
+	uploadFailStreak   int64 // consecutive upload failures, atomic
+	recordsSinceSweep  int64 // records buffered since the last sweep, atomic
+	intervalOverride   int64 // runtime interval override in ns, atomic (0: none)
 }
 
 // Options allows us to specify options for how this analytics manager will run.
@@ -148,6 +281,40 @@ type Options struct {
 	HybridConfigFile string
 	// collection interval
 	CollectionInterval time.Duration
+	// UploadMode is "auto", "signed", or "direct" ("" is treated as "auto")
+	UploadMode string
+	// SamplingRate is the fraction of records reported, (0,1] (0 is treated as 1)
+	SamplingRate float64
+	// MaxRecordAge is how old a record may be and still be reported (0 uses the 90 day default)
+	MaxRecordAge time.Duration
+	// MaxRecordSkew is how far in the future a record timestamp may be (default: not at all)
+	MaxRecordSkew time.Duration
+	// Clock optionally overrides the time source (eg. a SimulatedClock for load testing)
+	Clock func() time.Time
+	// DebugMirrorDir, if set, appends every accepted record to a local
+	// NDJSON file per tenant for debugging and integration testing
+	DebugMirrorDir string
+	// BacklogAlert, if set, is called when the staged upload backlog crosses
+	// BacklogAlertThreshold, and again (with the current count) once it
+	// drains. Hook for operator alerting; must not block.
+	BacklogAlert func(stagedFiles int)
+	// BacklogAlertThreshold is the staged file count that triggers
+	// BacklogAlert (default: 3/4 of StagingFileLimit)
+	BacklogAlertThreshold int
+	// TenantCollectionIntervals overrides CollectionInterval per tenant
+	// ("org~env"); only overrides longer than the base interval have effect
+	TenantCollectionIntervals map[string]time.Duration
+	// EncryptionKey, if set, is an AES key (16, 24 or 32 bytes) used to
+	// encrypt buffer files at rest
+	EncryptionKey []byte
+	// UploadTimeZone is the IANA timezone for upload window paths (default "UTC")
+	UploadTimeZone string
+	// MirrorURLs are additional base URLs that receive best-effort copies of
+	// every upload (fan-out to multiple destinations)
+	MirrorURLs []string
+	// ScrubFields names record fields (by json name) whose PII is cleared or
+	// masked before records are buffered (eg. client_ip, useragent)
+	ScrubFields []string
 	// now is for testing
 	now func() time.Time
 }
@@ -161,6 +328,19 @@ func (o *Options) validate() error {
 		o.now == nil {
 		return fmt.Errorf("all analytics options are required")
 	}
+	switch o.UploadMode {
+	case "":
+		o.UploadMode = UploadModeAuto
+	case UploadModeAuto, UploadModeSigned, UploadModeDirect:
+	default:
+		return fmt.Errorf("invalid upload mode: %s", o.UploadMode)
+	}
+	if o.SamplingRate == 0 {
+		o.SamplingRate = 1
+	}
+	if o.SamplingRate < 0 || o.SamplingRate > 1 {
+		return fmt.Errorf("sampling rate must be between 0 and 1: %v", o.SamplingRate)
+	}
 	return nil
 }
 
@@ -182,6 +362,17 @@ const (
 	// limited to 2 for now to limit upload stress
 	numUploaders = 2
 
+	// staging backs off up to this multiple of the configured collection
+	// interval while the upload backlog is large
+	maxIntervalMultiplier = 8
+
+	// staging speeds up down to this divisor of the configured collection
+	// interval while buffered record volume is high
+	minIntervalDivisor = 4
+
+	// records buffered since the last sweep that count as high volume
+	highVolumeRecordsPerSweep = 10000
+
 	// number of files that can be queued for upload
 	uploaderBufferLimit = 2
 )
@@ -261,8 +452,103 @@ func (m *manager) startUploader(env adapter.Env, errHandler util.ErrorFunc) {
 	})
 }
 
+// mirrorSuffix marks per-destination file copies: <file>.mirror<N>
+const mirrorSuffix = ".mirror"
+
+// a mirroringUploader fans uploads out to secondary destinations
+type mirroringUploader interface {
+	mirrorCount() int
+	mirrorWorkFunc(i int, tenant, fileName string) util.WorkFunc
+}
+
 func (m *manager) upload(tenant, file string) {
-	m.uploadChan <- m.uploader.workFunc(tenant, file)
+	m.enqueueUpload(tenant, file, m.uploader.workFunc(tenant, file))
+
+	// each mirror destination gets its own copy of the file and its own
+	// queued work, so retry state, commit and removal are independent per
+	// destination and primary retries never re-post to mirrors
+	if mu, ok := m.uploader.(mirroringUploader); ok {
+		for i := 0; i < mu.mirrorCount(); i++ {
+			copyName := fmt.Sprintf("%s%s%d", file, mirrorSuffix, i)
+			if _, err := os.Stat(copyName); err == nil {
+				continue // crash recovery already queued this copy
+			}
+			if err := copyFile(file, copyName); err != nil {
+				m.log.Warningf("mirror copy %s: %s", copyName, err)
+				continue
+			}
+			m.enqueueUpload(tenant, copyName, mu.mirrorWorkFunc(i, tenant, copyName))
+		}
+	}
+}
+
+// uploadMirror requeues a recovered per-destination copy, or removes it if
+// the destination is no longer configured
+func (m *manager) uploadMirror(tenant, file string, i int) {
+	mu, ok := m.uploader.(mirroringUploader)
+	if !ok || i >= mu.mirrorCount() {
+		m.log.Warningf("dropping copy for removed mirror destination: %s", file)
+		os.Remove(file)
+		return
+	}
+	m.enqueueUpload(tenant, file, mu.mirrorWorkFunc(i, tenant, file))
+}
+
+// mirrorIndex parses the destination index from a mirror copy name
+func mirrorIndex(name string) (int, bool) {
+	idx := strings.LastIndex(name, mirrorSuffix)
+	if idx < 0 {
+		return 0, false
+	}
+	i, err := strconv.Atoi(name[idx+len(mirrorSuffix):])
+	if err != nil {
+		return 0, false
+	}
+	return i, true
+}
+
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, data, os.FileMode(0600))
+}
+
+// enqueueUpload queues work for one file to one destination: the upload is
+// committed and the file removed only after the work succeeds
+func (m *manager) enqueueUpload(tenant, file string, work util.WorkFunc) {
+	var acked util.WorkFunc = func(ctx context.Context) error {
+		err := work(ctx)
+		if err != nil {
+			if atomic.AddInt64(&m.uploadFailStreak, 1) == uploadFailureAlertStreak {
+				m.alert(AlertUploadFailures,
+					fmt.Sprintf("%d consecutive upload failures, latest: %s", uploadFailureAlertStreak, err),
+					uploadFailureAlertStreak)
+			}
+		} else {
+			atomic.StoreInt64(&m.uploadFailStreak, 0)
+		}
+		if err == nil && ctx.Err() == nil {
+			// upload -> commit -> remove: a crash between the upload and the
+			// removal leaves a committed file behind, which recovery drops
+			// instead of re-uploading a duplicate
+			if err := m.commitLog.commit(filepath.Base(file)); err != nil {
+				m.log.Warningf("commit log: %s", err)
+			}
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				m.log.Warningf("rm %s: %s", file, err)
+			}
+		}
+		if err != nil && !m.retryBudget.allow(tenant) {
+			// abandon the retry loop; the file stays staged and will be
+			// retried after the window or on restart
+			m.log.Warningf("upload retry budget for %s exhausted, abandoning %s for now", tenant, file)
+			return nil
+		}
+		return err
+	}
+	m.uploadChan <- acked
 }
 
 // Close shuts down the manager
@@ -282,49 +568,217 @@ func (m *manager) Close() {
 	m.stageAllBucketsWait()
 	close(m.uploadChan)
 	m.uploadersWait.Wait()
+	m.commitLog.close()
 
 	m.log.Infof("closed analytics manager")
 }
 
-// stagingLoop periodically closes and sweeps open buckets to staging
+// stagingLoop periodically closes and sweeps open buckets to staging.
+// The interval dynamically backs off while the upload backlog is large.
 func (m *manager) stagingLoop() {
-	t := time.NewTicker(m.collectionInterval)
+	interval := m.collectionInterval
+	t := time.NewTimer(interval)
 	for {
 		select {
 		case <-t.C:
 			m.stageAllBucketsWait()
+			// merge lingering small files before the next sweep; fresh files
+			// are still queued for upload and are left alone
+			m.compactAllStaging(m.collectionInterval)
+			interval = m.adjustCollectionInterval(interval)
+			t.Reset(interval)
 
 		case <-m.closeStaging:
+			t.Stop()
 			m.log.Debugf("analytics staging loop closed: %s", m.tempDir)
 			return
 		}
 	}
 }
 
+// SetCollectionInterval overrides the staging interval at runtime (eg. from
+// the admin endpoint). A non-positive d removes the override. The new base
+// takes effect at the next sweep; dynamic backoff and speedup still apply.
+func (m *manager) SetCollectionInterval(d time.Duration) {
+	atomic.StoreInt64(&m.intervalOverride, int64(d))
+	m.log.Infof("analytics collection interval override: %s", d)
+}
+
+// baseInterval is the configured collection interval, unless overridden at
+// runtime via SetCollectionInterval
+func (m *manager) baseInterval() time.Duration {
+	if override := time.Duration(atomic.LoadInt64(&m.intervalOverride)); override > 0 {
+		return override
+	}
+	return m.collectionInterval
+}
+
+// adjustCollectionInterval adapts the staging interval to load: it doubles
+// (bounded) while the staged backlog exceeds half the staging file limit,
+// halves (bounded) while buffered record volume is high, and restores the
+// base interval otherwise.
+func (m *manager) adjustCollectionInterval(current time.Duration) time.Duration {
+	staged, err := m.getFilesInStaging()
+	if err != nil {
+		m.log.Debugf("unable to size staging backlog: %s", err)
+	}
+
+	m.checkBacklogAlert(len(staged))
+
+	base := m.baseInterval()
+	buffered := atomic.SwapInt64(&m.recordsSinceSweep, 0)
+
+	if len(staged) > m.stagingFileLimit/2 {
+		next := current * 2
+		if max := base * maxIntervalMultiplier; next > max {
+			next = max
+		}
+		if next != current {
+			m.log.Warningf("analytics backlog at %d files, collection interval now %s", len(staged), next)
+		}
+		return next
+	}
+
+	// high inbound volume with a healthy backlog: sweep more often so open
+	// buckets don't grow unbounded between stagings
+	if buffered > highVolumeRecordsPerSweep {
+		next := current / 2
+		if min := base / minIntervalDivisor; next < min {
+			next = min
+		}
+		if next != current {
+			m.log.Infof("%d records buffered since last sweep, collection interval now %s", buffered, next)
+		}
+		return next
+	}
+
+	if current != base {
+		m.log.Infof("analytics load normalized, collection interval restored to %s", base)
+	}
+	return base
+}
+
+// RegisterAlertFunc registers f to receive pipeline Alerts
+func (m *manager) RegisterAlertFunc(f func(Alert)) {
+	m.alertLock.Lock()
+	m.alertFunc = f
+	m.alertLock.Unlock()
+}
+
+// alert delivers an Alert to the registered func, if any
+func (m *manager) alert(kind, message string, value int64) {
+	m.alertLock.Lock()
+	f := m.alertFunc
+	m.alertLock.Unlock()
+	if f != nil {
+		f(Alert{Kind: kind, Message: message, Value: value})
+	}
+}
+
+// checkBacklogAlert fires the backlog alert hook on threshold crossings:
+// once when the staged backlog exceeds the threshold, and once when it
+// drains back below it.
+func (m *manager) checkBacklogAlert(staged int) {
+	if staged > m.backlogThreshold && !m.backlogAlerted {
+		m.backlogAlerted = true
+		if m.backlogAlert != nil {
+			m.backlogAlert(staged)
+		}
+		m.alert(AlertBacklog, fmt.Sprintf("staged upload backlog at %d files", staged), int64(staged))
+	} else if staged <= m.backlogThreshold && m.backlogAlerted {
+		m.backlogAlerted = false
+		if m.backlogAlert != nil {
+			m.backlogAlert(staged)
+		}
+		m.alert(AlertBacklog, fmt.Sprintf("staged upload backlog drained to %d files", staged), int64(staged))
+	}
+}
+
+// SendTenantRecords spools records for a Tenant, for use outside of Mixer
+func (m *manager) SendTenantRecords(tenant Tenant, records []Record) error {
+	return m.SendRecords(tenant.Context(), records)
+}
+
 // SendRecords is called by Mixer, spools records for sending
 func (m *manager) SendRecords(ctx *auth.Context, incoming []Record) error {
 	if m == nil || len(incoming) == 0 {
 		return nil
 	}
 
-	// Validate the records
+	// Validate (and sample, if configured) the records
 	now := m.now()
+	var dropped int64
 	records := make([]Record, 0, len(incoming))
 	for _, record := range incoming {
+		if m.samplingRate > 0 && m.samplingRate < 1 && rand.Float64() >= m.samplingRate {
+			continue
+		}
 		record := record.ensureFields(ctx)
-		if err := record.validate(now); err != nil {
+		if m.scrub != nil {
+			m.scrub(&record)
+		}
+		if err := record.validate(now, m.validation); err != nil {
 			m.log.Errorf("invalid record %v: %s", record, err)
+			dropped++
 			continue
 		}
 		records = append(records, record)
 	}
+	if dropped > 0 {
+		m.alert(AlertDroppedRecords, fmt.Sprintf("dropped %d invalid record(s)", dropped), dropped)
+	}
+
+	if m.mirrorDir != "" {
+		if err := m.mirrorRecords(ctx, records); err != nil {
+			m.log.Warningf("ndjson mirror: %s", err)
+		}
+	}
 
 	return m.writeToBucket(ctx, records)
 }
 
+// mirrorRecords appends records as NDJSON to a per-tenant debug file
+func (m *manager) mirrorRecords(ctx *auth.Context, records []Record) error {
+	if err := os.MkdirAll(m.mirrorDir, os.FileMode(0700)); err != nil {
+		return err
+	}
+	tenant := getTenantName(ctx.Organization(), ctx.Environment())
+	fileName := filepath.Join(m.mirrorDir, tenantDirName(tenant)+".ndjson")
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *manager) writeToBucket(ctx *auth.Context, records []Record) error {
-	tenant := fmt.Sprintf("%s~%s", ctx.Organization(), ctx.Environment())
+	atomic.AddInt64(&m.recordsSinceSweep, int64(len(records)))
+
+	// records may carry their own org/env (multi-tenant gateways); group by
+	// tenant so each batch lands in the right bucket
+	byTenant := map[string][]Record{}
+	for _, record := range records {
+		tenant := getTenantName(record.Organization, record.Environment)
+		byTenant[tenant] = append(byTenant[tenant], record)
+	}
 
+	var errs error
+	for tenant, tenantRecords := range byTenant {
+		if err := m.writeTenant(tenant, tenantRecords); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (m *manager) writeTenant(tenant string, records []Record) error {
 	m.bucketsLock.RLock()
 	if bucket, ok := m.buckets[tenant]; ok {
 		bucket.write(records)
@@ -362,23 +816,73 @@ func (m *manager) prepTenant(tenant string) error {
 	if err := os.MkdirAll(dir, bufferMode); err != nil {
 		return fmt.Errorf("mkdir %s: %s", dir, err)
 	}
+	if err := m.writeTenantMarker(tenant); err != nil {
+		return fmt.Errorf("tenant marker: %s", err)
+	}
 
 	dir = m.getStagingDir(tenant)
 	if err := os.MkdirAll(dir, bufferMode); err != nil {
 		return fmt.Errorf("mkdir %s: %s", dir, err)
 	}
 
+	// proactively validate upload credentials for this tenant
+	if v, ok := m.uploader.(credentialValidator); ok && m.env != nil {
+		m.env.ScheduleWork(func() {
+			if err := v.validateCredentials(tenant); err != nil {
+				m.log.Errorf("analytics upload credential check failed for %s: %s", tenant, err)
+			}
+		})
+	}
+
 	return nil
 }
 
 func (m *manager) getTempDir(tenant string) string {
-	return filepath.Join(m.tempDir, tenant)
+	return filepath.Join(m.tempDir, tenantDirName(tenant))
 }
 
 func (m *manager) getStagingDir(tenant string) string {
-	return filepath.Join(m.stagingDir, tenant)
+	return filepath.Join(m.stagingDir, tenantDirName(tenant))
 }
 
 func getTenantName(org, env string) string {
 	return fmt.Sprintf("%s~%s", org, env)
 }
+
+// tenantMarkerName records the real tenant name inside hashed tenant dirs
+const tenantMarkerName = ".tenant"
+
+// tenantDirName returns a filesystem-safe directory name for the tenant.
+// Tenants composed entirely of safe characters use their own name; others
+// (org/env with special characters) are hashed, with the real name stored
+// in a marker file for crash recovery.
+func tenantDirName(tenant string) string {
+	for _, r := range tenant {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.' || r == '_' || r == '-' || r == '~':
+		default:
+			sum := sha256.Sum256([]byte(tenant))
+			return fmt.Sprintf("hashed-%x", sum[:8])
+		}
+	}
+	return tenant
+}
+
+// writeTenantMarker persists the real tenant name in a hashed tenant temp dir
+func (m *manager) writeTenantMarker(tenant string) error {
+	if tenantDirName(tenant) == tenant {
+		return nil
+	}
+	marker := filepath.Join(m.getTempDir(tenant), tenantMarkerName)
+	return ioutil.WriteFile(marker, []byte(tenant), os.FileMode(0600))
+}
+
+// readTenantMarker maps a temp dir name back to its tenant name
+func (m *manager) readTenantMarker(dirName string) string {
+	marker := filepath.Join(m.tempDir, dirName, tenantMarkerName)
+	if data, err := ioutil.ReadFile(marker); err == nil && len(data) > 0 {
+		return string(data)
+	}
+	return dirName
+}
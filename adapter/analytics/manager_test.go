@@ -17,6 +17,7 @@ package analytics
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -99,3 +100,79 @@ func TestStandardBadOptions(t *testing.T) {
 		m.Close()
 	}
 }
+
+func TestBacklogAlert(t *testing.T) {
+	var alerts []int
+	m := &manager{
+		backlogThreshold: 5,
+		backlogAlert:     func(staged int) { alerts = append(alerts, staged) },
+	}
+
+	m.checkBacklogAlert(3) // below threshold, no alert
+	m.checkBacklogAlert(6) // crossed, alert
+	m.checkBacklogAlert(7) // still over, no repeat
+	m.checkBacklogAlert(2) // drained, alert
+	m.checkBacklogAlert(1) // still drained, no repeat
+
+	want := []int{6, 2}
+	if len(alerts) != len(want) || alerts[0] != want[0] || alerts[1] != want[1] {
+		t.Errorf("want alerts %v, got %v", want, alerts)
+	}
+
+	// no hook, no panic
+	m.backlogAlert = nil
+	m.checkBacklogAlert(100)
+}
+
+func TestTenantDirName(t *testing.T) {
+	cases := []struct {
+		tenant string
+		hashed bool
+	}{
+		{"org~env", false},
+		{"my-org.prod~test_1", false},
+		{"org/env~test", true},
+		{"org env~test", true},
+		{"org:env~tëst", true},
+	}
+	for _, c := range cases {
+		got := tenantDirName(c.tenant)
+		if c.hashed {
+			if got == c.tenant || strings.ContainsAny(got, "/ :") {
+				t.Errorf("tenant %q should be hashed, got %q", c.tenant, got)
+			}
+		} else if got != c.tenant {
+			t.Errorf("tenant %q should not be hashed, got %q", c.tenant, got)
+		}
+	}
+
+	// stable
+	if tenantDirName("org/env~test") != tenantDirName("org/env~test") {
+		t.Errorf("hashed names should be stable")
+	}
+}
+
+func TestBadSamplingRate(t *testing.T) {
+
+	env := adaptertest.NewEnv(t)
+
+	for _, rate := range []float64{-0.5, 1.5} {
+		opts := Options{
+			BufferPath:         "/tmp/apigee-ax/buffer/",
+			StagingFileLimit:   10,
+			BaseURL:            &url.URL{},
+			Key:                "key",
+			Secret:             "secret",
+			Client:             http.DefaultClient,
+			now:                time.Now,
+			CollectionInterval: time.Minute,
+			SamplingRate:       rate,
+		}
+
+		m, err := NewManager(env, opts)
+		if err == nil {
+			t.Errorf("sampling rate %v should be rejected", rate)
+			m.Close()
+		}
+	}
+}
@@ -16,6 +16,7 @@ package analytics
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/auth"
@@ -51,6 +52,17 @@ type Record struct {
 	Environment                  string `json:"environment"`
 	GatewaySource                string `json:"gateway_source"`
 	GatewayFlowID                string `json:"gateway_flow_id"`
+	GatewaySequence              int64  `json:"gateway_sequence,omitempty"`
+	DedupeKey                    string `json:"dedupe_key,omitempty"`
+	QuotaAllowed                 int64  `json:"quota_allowed,omitempty"`
+	QuotaUsed                    int64  `json:"quota_used,omitempty"`
+	QuotaExceeded                int64  `json:"quota_exceeded,omitempty"`
+	Basepath                     string `json:"basepath,omitempty"`
+	SourceWorkload               string `json:"source_workload,omitempty"`
+	SourceWorkloadNamespace      string `json:"source_workload_namespace,omitempty"`
+	DestinationWorkload          string `json:"destination_workload,omitempty"`
+	DestinationWorkloadNamespace string `json:"destination_workload_namespace,omitempty"`
+	MeshID                       string `json:"mesh_id,omitempty"`
 }
 
 func (r Record) ensureFields(ctx *auth.Context) Record {
@@ -61,10 +73,20 @@ func (r Record) ensureFields(ctx *auth.Context) Record {
 	r.DeveloperApp = ctx.Application
 	r.AccessToken = ctx.AccessToken
 	r.ClientID = ctx.ClientID
-	r.Organization = ctx.Organization()
-	r.Environment = ctx.Environment()
 
-	r.GatewayFlowID = uuid.New().String()
+	// record-level org/env takes precedence (multi-tenant gateways)
+	if r.Organization == "" {
+		r.Organization = ctx.Organization()
+	}
+	if r.Environment == "" {
+		r.Environment = ctx.Environment()
+	}
+
+	// keep an existing flow id (eg. replayed or backfilled records) so
+	// downstream dedup can recognize it
+	if r.GatewayFlowID == "" {
+		r.GatewayFlowID = uuid.New().String()
+	}
 
 	// selects best APIProduct based on path, otherwise arbitrary
 	if len(ctx.APIProducts) > 0 {
@@ -73,8 +95,32 @@ func (r Record) ensureFields(ctx *auth.Context) Record {
 	return r
 }
 
+// validationRules controls deployment-specific record validation.
+type validationRules struct {
+	maxAge  time.Duration // how old ClientReceivedStartTimestamp may be
+	maxSkew time.Duration // how far in the future it may be
+}
+
+func defaultValidationRules() validationRules {
+	return validationRules{
+		maxAge: 90 * 24 * time.Hour,
+	}
+}
+
+// EnsureFields applies the same field population the pipeline applies
+// before validation. Exposed for validation tooling.
+func EnsureFields(record Record, ctx *auth.Context) Record {
+	return record.ensureFields(ctx)
+}
+
+// ValidateRecord checks a record as the pipeline would, using the default
+// validation rules. Exposed for validation tooling.
+func ValidateRecord(record Record, now time.Time) error {
+	return record.validate(now, defaultValidationRules())
+}
+
 // validate confirms that a record has correct values in it.
-func (r Record) validate(now time.Time) error {
+func (r Record) validate(now time.Time, rules validationRules) error {
 	var err error
 
 	// Validate that certain fields are set.
@@ -99,11 +145,11 @@ func (r Record) validate(now time.Time) error {
 
 	// Validate that timestamps make sense.
 	ts := time.Unix(r.ClientReceivedStartTimestamp/1000, 0)
-	if ts.After(now) {
+	if ts.After(now.Add(rules.maxSkew)) {
 		err = multierror.Append(err, errors.New("ClientReceivedStartTimestamp cannot be in the future"))
 	}
-	if ts.Before(now.Add(-90 * 24 * time.Hour)) {
-		err = multierror.Append(err, errors.New("ClientReceivedStartTimestamp cannot be more than 90 days old"))
+	if rules.maxAge > 0 && ts.Before(now.Add(-rules.maxAge)) {
+		err = multierror.Append(err, fmt.Errorf("ClientReceivedStartTimestamp cannot be more than %s old", rules.maxAge))
 	}
 	return err
 }
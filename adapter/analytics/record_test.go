@@ -18,6 +18,10 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/auth"
+	"github.com/apigee/istio-mixer-adapter/adapter/authtest"
+	adaptertest "istio.io/istio/mixer/pkg/adapter/test"
 )
 
 func TestValidationFailure(t *testing.T) {
@@ -78,7 +82,7 @@ func TestValidationFailure(t *testing.T) {
 			ClientReceivedStartTimestamp: (ts - 91*24*3600) * 1000,
 			ClientReceivedEndTimestamp:   (ts - 91*24*3600) * 1000,
 			GatewayFlowID:                "x",
-		}, "more than 90 days old"},
+		}, "cannot be more than"},
 		{"missing GatewayFlowID", Record{
 			Organization:                 "hi",
 			Environment:                  "test",
@@ -88,7 +92,7 @@ func TestValidationFailure(t *testing.T) {
 	} {
 		t.Log(test.desc)
 
-		gotErr := test.record.validate(time.Unix(ts, 0))
+		gotErr := test.record.validate(time.Unix(ts, 0), defaultValidationRules())
 		if test.wantError == "" {
 			if gotErr != nil {
 				t.Errorf("got error %s, want none", gotErr)
@@ -105,3 +109,55 @@ func TestValidationFailure(t *testing.T) {
 		}
 	}
 }
+
+func TestRecordOrgEnvOverride(t *testing.T) {
+	env := adaptertest.NewEnv(t)
+	tc := authtest.NewContext("", env)
+	tc.SetOrganization("ctx-org")
+	tc.SetEnvironment("ctx-env")
+	ctx := &auth.Context{Context: tc}
+
+	// records default to the context org/env
+	r := Record{}.ensureFields(ctx)
+	if r.Organization != "ctx-org" || r.Environment != "ctx-env" {
+		t.Errorf("want context org/env, got %s/%s", r.Organization, r.Environment)
+	}
+
+	// record-level values take precedence for multi-tenant batches
+	r = Record{Organization: "other-org", Environment: "other-env"}.ensureFields(ctx)
+	if r.Organization != "other-org" || r.Environment != "other-env" {
+		t.Errorf("want record org/env, got %s/%s", r.Organization, r.Environment)
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	ts := int64(1521221450) // This timestamp is roughly 11:30 MST on Mar. 16, 2018.
+	now := time.Unix(ts, 0)
+
+	record := Record{
+		Organization:                 "hi",
+		Environment:                  "test",
+		ClientReceivedStartTimestamp: (ts - 2*3600) * 1000,
+		ClientReceivedEndTimestamp:   (ts - 2*3600) * 1000,
+		GatewayFlowID:                "x",
+	}
+
+	// tighter max age rejects a record the default rules allow
+	if err := record.validate(now, defaultValidationRules()); err != nil {
+		t.Errorf("default rules should allow record: %v", err)
+	}
+	if err := record.validate(now, validationRules{maxAge: time.Hour}); err == nil {
+		t.Errorf("tight max age should reject record")
+	}
+
+	// allowed skew accepts a slightly future record
+	future := record
+	future.ClientReceivedStartTimestamp = (ts + 30) * 1000
+	future.ClientReceivedEndTimestamp = (ts + 30) * 1000
+	if err := future.validate(now, defaultValidationRules()); err == nil {
+		t.Errorf("future record should be rejected by default")
+	}
+	if err := future.validate(now, validationRules{maxAge: time.Hour, maxSkew: time.Minute}); err != nil {
+		t.Errorf("skew should allow future record: %v", err)
+	}
+}
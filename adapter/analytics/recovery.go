@@ -35,7 +35,7 @@ func (m *manager) crashRecovery() error {
 	}
 	var errs error
 	for _, d := range dirs {
-		tenant := d.Name()
+		tenant := m.readTenantMarker(d.Name())
 		tempDir := m.getTempDir(tenant)
 		tempFiles, err := ioutil.ReadDir(tempDir)
 		if err != nil {
@@ -46,14 +46,51 @@ func (m *manager) crashRecovery() error {
 		m.prepTenant(tenant)
 		stageDir := m.getStagingDir(tenant)
 
-		// put staged files in upload queue
-		stagedFiles, err := m.getFilesInStaging()
-		for _, fi := range stagedFiles {
-			m.upload(tenant, fi)
+		// drop already uploaded (committed) files FIRST: compacting one into
+		// a fresh file would launder it past the commit log and re-upload a
+		// duplicate - the exact window the commit log exists for
+		if staged, lsErr := ioutil.ReadDir(stageDir); lsErr == nil {
+			for _, fi := range staged {
+				if m.commitLog.isCommitted(fi.Name()) {
+					stagedFile := filepath.Join(stageDir, fi.Name())
+					m.log.Warningf("dropping already uploaded file: %s", stagedFile)
+					if err := os.Remove(stagedFile); err != nil {
+						errs = multierror.Append(errs, fmt.Errorf("remove %s: %s", stagedFile, err))
+					}
+				}
+			}
+		}
+
+		// merge many small staged files before queueing them
+		if err := m.compactStaging(tenant, 0); err != nil {
+			m.log.Warningf("staging compaction: %s", err)
+		}
+
+		// put remaining staged files in upload queue; per-destination mirror
+		// copies go back to their own destination
+		if staged, lsErr := ioutil.ReadDir(stageDir); lsErr == nil {
+			for _, fi := range staged {
+				stagedFile := filepath.Join(stageDir, fi.Name())
+				if i, ok := mirrorIndex(fi.Name()); ok {
+					m.uploadMirror(tenant, stagedFile, i)
+					continue
+				}
+				m.upload(tenant, stagedFile)
+			}
 		}
 
 		// recover temp to staging and upload
 		for _, fi := range tempFiles {
+			if fi.Name() == tenantMarkerName {
+				continue
+			}
+			if m.commitLog.isCommitted(fi.Name()) {
+				m.log.Warningf("dropping already uploaded file: %s", fi.Name())
+				if err := os.Remove(filepath.Join(tempDir, fi.Name())); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("remove %s: %s", fi.Name(), err))
+				}
+				continue
+			}
 			tempFile := filepath.Join(tempDir, fi.Name())
 			stageFile := filepath.Join(stageDir, fi.Name())
 
@@ -81,41 +118,72 @@ func (m *manager) crashRecovery() error {
 }
 
 // recoverFile recovers gzipped data in a file and puts it into a new file.
+// Data is truncated to the last complete (newline-terminated) record so that
+// a partially written tail can't produce a corrupt duplicate record set
+// downstream. Records carry per-bucket sequence IDs, so the recovered set
+// remains ordered and replay-detectable.
 func (m *manager) recoverFile(oldName string, newFile *os.File) error {
 	m.log.Warningf("recover file: %s", oldName)
 	in, err := os.Open(oldName)
 	if err != nil {
 		return fmt.Errorf("open %s: %s", oldName, err)
 	}
-	br := bufio.NewReader(in)
-	gzr, err := gzip.NewReader(br)
+	defer in.Close()
+
+	var source io.Reader = bufio.NewReader(in)
+	var sink io.Writer = newFile
+	var sinkCloser io.Closer
+	if m.crypter != nil {
+		if source, err = m.crypter.decryptReader(source); err != nil {
+			return fmt.Errorf("decrypt %s: %s", oldName, err)
+		}
+		cw, err := m.crypter.encryptWriter(newFile)
+		if err != nil {
+			return fmt.Errorf("encrypt %s: %s", newFile.Name(), err)
+		}
+		sink, sinkCloser = cw, cw
+	}
+
+	gzr, err := gzip.NewReader(source)
 	if err != nil {
 		return fmt.Errorf("gzip.NewReader(%s): %s", oldName, err)
 	}
 	defer gzr.Close()
 
-	// buffer size is arbitrary and doesn't really matter
-	b := make([]byte, 1000)
-	gzw := gzip.NewWriter(newFile)
+	var recovered, dropped int
+	r := bufio.NewReader(gzr)
+	gzw := gzip.NewWriter(sink)
 	for {
-		var nRead int
-		if nRead, err = gzr.Read(b); err != nil {
-			if err != io.EOF && err.Error() != "unexpected EOF" && err.Error() != "gzip: invalid header" {
-				return fmt.Errorf("scan gzip %s: %s", oldName, err)
-			}
+		var line string
+		if line, err = r.ReadString('\n'); err == nil {
+			gzw.Write([]byte(line))
+			recovered++
+			continue
 		}
-		gzw.Write(b[:nRead])
-		if err != nil {
-			break
+		// incomplete or unreadable tail, truncate it
+		if line != "" {
+			dropped++
+		}
+		if err != io.EOF && err.Error() != "unexpected EOF" && err.Error() != "gzip: invalid header" {
+			return fmt.Errorf("scan gzip %s: %s", oldName, err)
 		}
+		break
 	}
 	if err := gzw.Close(); err != nil {
 		return fmt.Errorf("close gzw %s: %s", oldName, err)
 	}
+	if sinkCloser != nil {
+		if err := sinkCloser.Close(); err != nil {
+			return fmt.Errorf("close sink %s: %s", oldName, err)
+		}
+	}
 	if err := newFile.Close(); err != nil {
 		return fmt.Errorf("close gzw file %s: %s", oldName, err)
 	}
 
-	m.log.Infof("%s recovered to: %s", oldName, newFile.Name())
+	if dropped > 0 {
+		m.log.Warningf("truncated %d partial record(s) recovering %s", dropped, oldName)
+	}
+	m.log.Infof("%s recovered to: %s (%d records)", oldName, newFile.Name(), recovered)
 	return nil
 }
@@ -86,6 +86,58 @@ func TestRecoverFile(t *testing.T) {
 	}
 }
 
+func TestRecoverFileTruncatesPartialTail(t *testing.T) {
+	t.Parallel()
+
+	brokeFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile(): %v", err)
+	}
+
+	rec := Record{
+		Organization: "hi",
+		Environment:  "test",
+	}
+
+	// a complete record followed by a partially written one
+	gzWriter := gzip.NewWriter(brokeFile)
+	if err := json.NewEncoder(gzWriter).Encode(&rec); err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	if _, err := gzWriter.Write([]byte(`{"organization":"hi","envi`)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		t.Fatalf("gz.Close(): %v", err)
+	}
+	if err := brokeFile.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+
+	env := adaptertest.NewEnv(t)
+	m := manager{
+		log: env.Logger(),
+	}
+	fixedFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempFile(): %v", err)
+	}
+	if err := m.recoverFile(brokeFile.Name(), fixedFile); err != nil {
+		t.Fatalf("recoverFile: %v", err)
+	}
+
+	recs, err := readRecordsFromGZipFile(fixedFile.Name())
+	if err != nil {
+		t.Fatalf("ReadRecords %s: %s", fixedFile.Name(), err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("want 1, got %d: %v", len(recs), recs)
+	}
+	if recs[0] != rec {
+		t.Errorf("got %v, want %v", recs[0], rec)
+	}
+}
+
 func readRecordsFromGZipFile(fileName string) ([]Record, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -0,0 +1,68 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// per-tenant upload retry budget: at most retryBudgetLimit failed
+	// attempts per retryBudgetWindow before retries are abandoned (files
+	// stay staged and are retried after the window or on restart)
+	retryBudgetLimit  = 10
+	retryBudgetWindow = 5 * time.Minute
+)
+
+// A retryBudget bounds upload retries per tenant so one failing tenant can't
+// monopolize the uploaders. Attempts are counted in fixed windows.
+type retryBudget struct {
+	lock    sync.Mutex
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+	tenants map[string]*retryWindow
+}
+
+type retryWindow struct {
+	start    time.Time
+	attempts int
+}
+
+func newRetryBudget(limit int, window time.Duration, now func() time.Time) *retryBudget {
+	return &retryBudget{
+		limit:   limit,
+		window:  window,
+		now:     now,
+		tenants: map[string]*retryWindow{},
+	}
+}
+
+// allow counts a failed attempt for the tenant, false if the budget for the
+// current window is exhausted
+func (r *retryBudget) allow(tenant string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	w, ok := r.tenants[tenant]
+	if !ok || now.After(w.start.Add(r.window)) {
+		w = &retryWindow{start: now}
+		r.tenants[tenant] = w
+	}
+	w.attempts++
+	return w.attempts <= r.limit
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Unix(1521221450, 0)
+	budget := newRetryBudget(3, time.Minute, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if !budget.allow("hi~test") {
+			t.Errorf("attempt %d should be allowed", i)
+		}
+	}
+	if budget.allow("hi~test") {
+		t.Errorf("budget should be exhausted")
+	}
+
+	// budgets are per tenant
+	if !budget.allow("other~env") {
+		t.Errorf("other tenant should have its own budget")
+	}
+
+	// a new window resets the budget
+	now = now.Add(61 * time.Second)
+	if !budget.allow("hi~test") {
+		t.Errorf("budget should reset after the window")
+	}
+}
@@ -15,22 +15,48 @@
 package analytics
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/util"
 	"istio.io/istio/mixer/pkg/adapter"
 )
 
+const (
+	// UploadModeAuto uses the signed URL flow until it consistently fails, then direct POST.
+	UploadModeAuto = "auto"
+	// UploadModeSigned always PUTs files to a signed storage URL obtained from Apigee.
+	UploadModeSigned = "signed"
+	// UploadModeDirect always POSTs gzip content through the Apigee proxy endpoint.
+	UploadModeDirect = "direct"
+
+	// number of consecutive signed URL failures before auto mode falls back to direct POST
+	signedFailureThreshold = 3
+
+	// how long auto mode stays on direct POST before the signed URL flow is retried
+	directModeRetryInterval = 10 * time.Minute
+
+	// how long a successful credential validation is cached per tenant
+	credentialCacheTTL = 10 * time.Minute
+)
+
+// a credentialValidator can proactively verify its upload credentials
+type credentialValidator interface {
+	validateCredentials(tenant string) error
+}
+
 type uploader interface {
 	workFunc(tenant, fileName string) util.WorkFunc
 	write(records []Record, writer io.Writer) error
@@ -38,12 +64,149 @@ type uploader interface {
 }
 
 type saasUploader struct {
-	log     adapter.Logger
-	client  *http.Client
-	baseURL *url.URL
-	key     string
-	secret  string
-	now     func() time.Time
+	log        adapter.Logger
+	client     *http.Client
+	baseURL    *url.URL
+	key        string
+	secret     string
+	now        func() time.Time
+	uploadMode string
+	location   *time.Location // timezone for upload window paths (default UTC)
+	window     time.Duration  // upload path window alignment (collection interval)
+
+	// fallback state for UploadModeAuto
+	modeLock       sync.Mutex
+	signedFailures int
+	directSince    time.Time
+
+	// per-tenant credential validation cache
+	credsLock  sync.Mutex
+	credsValid map[string]time.Time
+
+	// optional encryption-at-rest of buffer files
+	crypter *fileCrypter
+
+	// additional destinations that receive best-effort copies of each upload
+	mirrors []*url.URL
+}
+
+// mirrorCount is the number of secondary destinations
+func (s *saasUploader) mirrorCount() int {
+	return len(s.mirrors)
+}
+
+// mirrorWorkFunc uploads a per-destination file copy to one mirror. Each
+// destination gets its own work (and so its own retry state); the manager
+// removes the copy once the upload is committed.
+func (s *saasUploader) mirrorWorkFunc(i int, tenant, fileName string) util.WorkFunc {
+	return func(ctx context.Context) error {
+		if ctx.Err() == nil {
+			return s.postTo(s.mirrors[i], tenant, fileName)
+		}
+
+		s.log.Warningf("canceled mirror upload of %s: %v", fileName, ctx.Err())
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			s.log.Warningf("unable to remove file %s: %v", fileName, err)
+		}
+		return nil
+	}
+}
+
+// postTo POSTs the gzip content of a buffer file to the analytics path of
+// the given base URL
+func (s *saasUploader) postTo(base *url.URL, tenant, fileName string) error {
+	org, env := s.orgEnvFromSubdir(tenant)
+	if org == "" || env == "" {
+		return fmt.Errorf("invalid subdir %s", tenant)
+	}
+
+	body, size, err := s.openForUpload(fileName)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	ur := *base
+	ur.Path = path.Join(ur.Path, fmt.Sprintf(analyticsPath, org, env))
+	req, err := http.NewRequest("POST", ur.String(), body)
+	if err != nil {
+		return err
+	}
+
+	relPath := filepath.Join(s.uploadDir(), filepath.Base(fileName))
+	q := req.URL.Query()
+	q.Add("tenant", tenant)
+	q.Add("relative_file_path", relPath)
+	q.Add("file_content_type", "application/x-gzip")
+	q.Add("encrypt", "true")
+	req.URL.RawQuery = q.Encode()
+
+	req.SetBasicAuth(s.key, s.secret)
+	req.Header.Set("Content-Type", "application/x-gzip")
+	req.ContentLength = size
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("upload %s returned %s", fileName, resp.Status)
+	}
+	return nil
+}
+
+// openForUpload returns the upload body for a buffer file, decrypting it if
+// buffer encryption is enabled
+func (s *saasUploader) openForUpload(fileName string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.crypter == nil {
+		fi, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, fi.Size(), nil
+	}
+
+	r, err := s.crypter.decryptReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	data, err := ioutil.ReadAll(r)
+	file.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// validateCredentials verifies the configured key and secret against the
+// signed URL endpoint for the tenant so bad credentials surface immediately
+// instead of on the first upload. Successful results are cached briefly.
+func (s *saasUploader) validateCredentials(tenant string) error {
+	s.credsLock.Lock()
+	if until, ok := s.credsValid[tenant]; ok && s.now().Before(until) {
+		s.credsLock.Unlock()
+		return nil
+	}
+	s.credsLock.Unlock()
+
+	if _, err := s.signedURL(tenant, "credential-probe"); err != nil {
+		return err
+	}
+
+	s.credsLock.Lock()
+	if s.credsValid == nil {
+		s.credsValid = map[string]time.Time{}
+	}
+	s.credsValid[tenant] = s.now().Add(credentialCacheTTL)
+	s.credsLock.Unlock()
+	return nil
 }
 
 func (s *saasUploader) isGzipped() bool {
@@ -76,25 +239,99 @@ func (s *saasUploader) workFunc(tenant, fileName string) util.WorkFunc {
 	}
 }
 
-// upload sends a file to SaaS UAP
+// upload sends a file to SaaS UAP using the current upload mode
 func (s *saasUploader) upload(tenant, fileName string) error {
+	if s.useDirect() {
+		return s.uploadDirect(tenant, fileName)
+	}
 
-	file, err := os.Open(fileName)
-	if err != nil {
-		return err
+	err := s.uploadSigned(tenant, fileName)
+	if err == nil {
+		s.signedSucceeded()
+		return nil
+	}
+
+	if s.signedFailed() {
+		s.log.Warningf("signed URL upload failed %d consecutive times, "+
+			"falling back to direct POST through the Apigee proxy: %v", signedFailureThreshold, err)
+		return s.uploadDirect(tenant, fileName)
+	}
+
+	return err
+}
+
+// useDirect is true if uploads should POST through the Apigee proxy endpoint.
+// In auto mode, the signed URL flow is retried periodically.
+func (s *saasUploader) useDirect() bool {
+	if s.uploadMode == UploadModeDirect {
+		return true
 	}
+	if s.uploadMode != UploadModeAuto {
+		return false
+	}
+	s.modeLock.Lock()
+	defer s.modeLock.Unlock()
+	if s.directSince.IsZero() {
+		return false
+	}
+	if s.now().After(s.directSince.Add(directModeRetryInterval)) {
+		s.signedFailures = 0
+		s.directSince = time.Time{}
+		return false
+	}
+	return true
+}
 
-	fi, err := file.Stat()
+// signedFailed records a signed URL failure, true if auto mode should fall back
+func (s *saasUploader) signedFailed() bool {
+	if s.uploadMode != UploadModeAuto {
+		return false
+	}
+	s.modeLock.Lock()
+	defer s.modeLock.Unlock()
+	s.signedFailures++
+	if s.signedFailures >= signedFailureThreshold {
+		if s.directSince.IsZero() {
+			s.directSince = s.now()
+		}
+		return true
+	}
+	return false
+}
+
+func (s *saasUploader) signedSucceeded() {
+	if s.uploadMode != UploadModeAuto {
+		return
+	}
+	s.modeLock.Lock()
+	defer s.modeLock.Unlock()
+	s.signedFailures = 0
+	s.directSince = time.Time{}
+}
+
+// uploadDirect POSTs gzip content through the Apigee proxy endpoint.
+// The file is left in place; the manager removes it once the upload has
+// been acknowledged in the commit log.
+func (s *saasUploader) uploadDirect(tenant, fileName string) error {
+	s.log.Debugf("uploading %s directly to %s", fileName, s.baseURL)
+	return s.postTo(s.baseURL, tenant, fileName)
+}
+
+// uploadSigned sends a file to SaaS UAP via a signed URL
+func (s *saasUploader) uploadSigned(tenant, fileName string) error {
+
+	body, size, err := s.openForUpload(fileName)
 	if err != nil {
 		return err
 	}
+	defer body.Close()
 
 	s.log.Debugf("getting signed URL for %s", fileName)
 	signedURL, err := s.signedURL(tenant, fileName)
 	if err != nil {
 		return fmt.Errorf("signedURL: %s", err)
 	}
-	req, err := http.NewRequest("PUT", signedURL, file)
+	req, err := http.NewRequest("PUT", signedURL, body)
 	if err != nil {
 		return fmt.Errorf("http.NewRequest: %s", err)
 	}
@@ -102,7 +339,7 @@ func (s *saasUploader) upload(tenant, fileName string) error {
 	req.Header.Set("Expect", "100-continue")
 	req.Header.Set("Content-Type", "application/x-gzip")
 	req.Header.Set("x-amz-server-side-encryption", "AES256")
-	req.ContentLength = fi.Size()
+	req.ContentLength = size
 
 	s.log.Debugf("uploading %s to %s", fileName, signedURL)
 	resp, err := s.client.Do(req)
@@ -114,10 +351,8 @@ func (s *saasUploader) upload(tenant, fileName string) error {
 		return fmt.Errorf("upload %s returned %s", fileName, resp.Status)
 	}
 
-	if err := os.Remove(fileName); err != nil {
-		return fmt.Errorf("rm %s: %s", fileName, err)
-	}
-
+	// the file is left in place; the manager removes it once the upload has
+	// been acknowledged in the commit log
 	return nil
 }
 
@@ -129,9 +364,18 @@ func (s *saasUploader) orgEnvFromSubdir(subdir string) (string, string) {
 	return "", ""
 }
 
-// uploadDir gets a directory for where we should upload the file.
+// uploadDir gets a directory for where we should upload the file. The path
+// is aligned to the collection window (as UAP expects) in the configured
+// timezone, defaulting to UTC.
 func (s *saasUploader) uploadDir() string {
-	now := s.now()
+	location := s.location
+	if location == nil {
+		location = time.UTC
+	}
+	now := s.now().In(location)
+	if s.window > 0 {
+		now = now.Truncate(s.window)
+	}
 	d := now.Format("2006-01-02")
 	t := now.Format("15-04-00")
 	return fmt.Sprintf(pathFmt, d, t)
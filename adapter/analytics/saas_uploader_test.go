@@ -0,0 +1,198 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	adaptertest "istio.io/istio/mixer/pkg/adapter/test"
+)
+
+func makeStagedFile(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "saas_uploader")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir: %s", err)
+	}
+	fileName := filepath.Join(dir, "fake.gz")
+	if err := ioutil.WriteFile(fileName, []byte("fake gzip data"), 0600); err != nil {
+		t.Fatalf("ioutil.WriteFile: %s", err)
+	}
+	return fileName
+}
+
+func TestUploadDirectFallback(t *testing.T) {
+	var signedGets, directPosts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet: // signed URL request
+			signedGets++
+			w.WriteHeader(http.StatusInternalServerError)
+		case http.MethodPost: // direct upload
+			directPosts++
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	baseURL, _ := url.Parse(ts.URL)
+	env := adaptertest.NewEnv(t)
+	uploader := &saasUploader{
+		log:        env.Logger(),
+		client:     http.DefaultClient,
+		baseURL:    baseURL,
+		key:        "key",
+		secret:     "secret",
+		now:        time.Now,
+		uploadMode: UploadModeAuto,
+	}
+
+	// signed URL flow fails, but direct POST should not kick in until the threshold
+	for i := 1; i < signedFailureThreshold; i++ {
+		fileName := makeStagedFile(t)
+		defer os.RemoveAll(filepath.Dir(fileName))
+		if err := uploader.upload("hi~test", fileName); err == nil {
+			t.Errorf("upload %d should have failed", i)
+		}
+	}
+	if directPosts != 0 {
+		t.Errorf("premature fallback, direct posts: %d", directPosts)
+	}
+
+	// threshold reached, fallback to direct POST should succeed
+	fileName := makeStagedFile(t)
+	defer os.RemoveAll(filepath.Dir(fileName))
+	if err := uploader.upload("hi~test", fileName); err != nil {
+		t.Errorf("upload should have fallen back: %s", err)
+	}
+	if directPosts != 1 {
+		t.Errorf("want 1 direct post, got: %d", directPosts)
+	}
+	// removal is the manager's job, after the commit log acknowledgement
+	if _, err := os.Stat(fileName); err != nil {
+		t.Errorf("uploader should leave the file for the manager to remove")
+	}
+
+	// subsequent uploads should stay on direct POST without touching the signed URL flow
+	priorGets := signedGets
+	fileName = makeStagedFile(t)
+	defer os.RemoveAll(filepath.Dir(fileName))
+	if err := uploader.upload("hi~test", fileName); err != nil {
+		t.Errorf("direct upload failed: %s", err)
+	}
+	if signedGets != priorGets {
+		t.Errorf("should not retry signed URL flow yet")
+	}
+}
+
+func TestUploadDirWindowAlignment(t *testing.T) {
+	// 2018-03-16 17:30:50 UTC
+	now := func() time.Time { return time.Unix(1521221450, 0).UTC() }
+
+	uploader := &saasUploader{
+		now:    now,
+		window: 10 * time.Minute,
+	}
+	if got := uploader.uploadDir(); got != "date=2018-03-16/time=17-30-00/" {
+		t.Errorf("aligned upload dir: got %s", got)
+	}
+
+	// without a window, the current minute is used (in UTC)
+	uploader.window = 0
+	if got := uploader.uploadDir(); got != "date=2018-03-16/time=17-30-00/" {
+		t.Errorf("unaligned upload dir: got %s", got)
+	}
+
+	// timezone control shifts the window path
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone db unavailable: %v", err)
+	}
+	uploader.location = loc
+	if got := uploader.uploadDir(); got != "date=2018-03-16/time=10-30-00/" {
+		t.Errorf("timezone upload dir: got %s", got)
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	var gets int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"url":"http://fake"}`))
+	}))
+	defer ts.Close()
+
+	baseURL, _ := url.Parse(ts.URL)
+	env := adaptertest.NewEnv(t)
+	uploader := &saasUploader{
+		log:     env.Logger(),
+		client:  http.DefaultClient,
+		baseURL: baseURL,
+		key:     "key",
+		secret:  "secret",
+		now:     time.Now,
+	}
+
+	if err := uploader.validateCredentials("hi~test"); err != nil {
+		t.Fatalf("validateCredentials: %v", err)
+	}
+	// cached: no second request within the TTL
+	if err := uploader.validateCredentials("hi~test"); err != nil {
+		t.Fatalf("validateCredentials: %v", err)
+	}
+	if gets != 1 {
+		t.Errorf("want 1 request, got %d", gets)
+	}
+
+	// bad tenant is rejected without a request
+	if err := uploader.validateCredentials("bad"); err == nil {
+		t.Errorf("bad tenant should fail validation")
+	}
+}
+
+func TestUploadSignedModeNoFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	baseURL, _ := url.Parse(ts.URL)
+	env := adaptertest.NewEnv(t)
+	uploader := &saasUploader{
+		log:        env.Logger(),
+		client:     http.DefaultClient,
+		baseURL:    baseURL,
+		key:        "key",
+		secret:     "secret",
+		now:        time.Now,
+		uploadMode: UploadModeSigned,
+	}
+
+	for i := 0; i <= signedFailureThreshold; i++ {
+		fileName := makeStagedFile(t)
+		defer os.RemoveAll(filepath.Dir(fileName))
+		if err := uploader.upload("hi~test", fileName); err == nil {
+			t.Errorf("upload %d should have failed", i)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// scrubbers clear potentially personal fields on a record, keyed by the
+// record's json field name. Query strings are stripped rather than the
+// whole uri since the path itself is needed for reporting.
+var scrubbers = map[string]func(*Record){
+	"client_ip":       func(r *Record) { r.ClientIP = "" },
+	"useragent":       func(r *Record) { r.UserAgent = "" },
+	"developer_email": func(r *Record) { r.DeveloperEmail = "" },
+	"access_token":    func(r *Record) { r.AccessToken = "" },
+	"request_uri": func(r *Record) {
+		r.RequestURI = strings.Split(r.RequestURI, "?")[0]
+	},
+}
+
+// hashers replace field values with a stable hash, for deployments that
+// need correlation (same caller, same value) without retaining the PII
+var hashers = map[string]func(*Record){
+	"client_ip":       func(r *Record) { r.ClientIP = hashValue(r.ClientIP) },
+	"useragent":       func(r *Record) { r.UserAgent = hashValue(r.UserAgent) },
+	"developer_email": func(r *Record) { r.DeveloperEmail = hashValue(r.DeveloperEmail) },
+	"access_token":    func(r *Record) { r.AccessToken = hashValue(r.AccessToken) },
+	"request_uri": func(r *Record) {
+		if q := strings.Index(r.RequestURI, "?"); q >= 0 {
+			r.RequestURI = r.RequestURI[:q] + "?" + hashValue(r.RequestURI[q+1:])
+		}
+	},
+}
+
+// hashValue returns a short stable hash of the value, empty for empty input
+func hashValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newScrubber returns a function applying the configured scrubbing for the
+// named fields, or an error naming any unsupported field. A field may be
+// prefixed "hash:" to replace the value with a stable hash instead of
+// dropping it.
+func newScrubber(fields []string) (func(*Record), error) {
+	var active []func(*Record)
+	for _, field := range fields {
+		table := scrubbers
+		if name := strings.TrimPrefix(field, "hash:"); name != field {
+			table = hashers
+			field = name
+		}
+		scrub, ok := table[field]
+		if !ok {
+			return nil, fmt.Errorf("unsupported scrub field: %s", field)
+		}
+		active = append(active, scrub)
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+	return func(r *Record) {
+		for _, scrub := range active {
+			scrub(r)
+		}
+	}, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	scrub, err := newScrubber([]string{"client_ip", "useragent", "request_uri"})
+	if err != nil {
+		t.Fatalf("newScrubber: %v", err)
+	}
+
+	record := Record{
+		ClientIP:       "10.0.0.1",
+		UserAgent:      "curl/7.54",
+		RequestURI:     "/pets?owner=jane@example.com",
+		DeveloperEmail: "dev@example.com",
+	}
+	scrub(&record)
+
+	if record.ClientIP != "" || record.UserAgent != "" {
+		t.Errorf("client_ip and useragent should be cleared: %#v", record)
+	}
+	if record.RequestURI != "/pets" {
+		t.Errorf("query string should be stripped, got %s", record.RequestURI)
+	}
+	if record.DeveloperEmail != "dev@example.com" {
+		t.Errorf("unconfigured fields should be untouched")
+	}
+
+	// hash mode replaces values with a stable hash instead of dropping them
+	scrub, err = newScrubber([]string{"hash:client_ip", "hash:request_uri"})
+	if err != nil {
+		t.Fatalf("newScrubber: %v", err)
+	}
+	hashed := Record{
+		ClientIP:   "10.0.0.1",
+		RequestURI: "/pets?owner=jane@example.com",
+	}
+	scrub(&hashed)
+	if hashed.ClientIP == "" || hashed.ClientIP == "10.0.0.1" {
+		t.Errorf("hashed client_ip should be a hash, got %q", hashed.ClientIP)
+	}
+	if hashed.ClientIP != hashValue("10.0.0.1") {
+		t.Errorf("hashes should be stable")
+	}
+	if !strings.HasPrefix(hashed.RequestURI, "/pets?") || strings.Contains(hashed.RequestURI, "jane") {
+		t.Errorf("query string should be hashed, got %q", hashed.RequestURI)
+	}
+
+	// unsupported fields are rejected
+	if _, err := newScrubber([]string{"organization"}); err == nil {
+		t.Errorf("organization should not be scrubbable")
+	}
+	if _, err := newScrubber([]string{"hash:organization"}); err == nil {
+		t.Errorf("hash:organization should not be scrubbable")
+	}
+
+	// no fields, no scrubber
+	if scrub, err := newScrubber(nil); err != nil || scrub != nil {
+		t.Errorf("empty fields should give nil scrubber")
+	}
+}
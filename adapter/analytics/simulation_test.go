@@ -0,0 +1,104 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/auth"
+	"github.com/apigee/istio-mixer-adapter/adapter/authtest"
+	adaptertest "istio.io/istio/mixer/pkg/adapter/test"
+)
+
+func TestSimulatedClock(t *testing.T) {
+	start := time.Unix(1521221450, 0)
+	clock := NewSimulatedClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("want %s, got %s", start, clock.Now())
+	}
+	clock.Advance(time.Minute)
+	if !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("want %s, got %s", start.Add(time.Minute), clock.Now())
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	env := adaptertest.NewEnv(t)
+
+	workDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %s", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	clock := NewSimulatedClock(time.Unix(1521221450, 0))
+
+	uploader := &saasUploader{
+		log:     env.Logger(),
+		client:  http.DefaultClient,
+		baseURL: &url.URL{},
+		key:     "key",
+		secret:  "secret",
+		now:     clock.Now,
+	}
+
+	m, err := newManager(uploader, Options{
+		BufferPath:         workDir,
+		StagingFileLimit:   10,
+		now:                clock.Now,
+		CollectionInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newManager: %s", err)
+	}
+	m.Start(env)
+	defer m.Close()
+
+	tc := authtest.NewContext("", env)
+	tc.SetOrganization("hi")
+	tc.SetEnvironment("test")
+	ctx := &auth.Context{Context: tc}
+
+	sim := &Simulator{
+		Manager:         m,
+		Context:         ctx,
+		Clock:           clock,
+		Batches:         3,
+		RecordsPerBatch: 5,
+		Interval:        time.Second,
+	}
+
+	sent, err := sim.Run()
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+	if sent != 15 {
+		t.Errorf("want 15 records sent, got %d", sent)
+	}
+	if !clock.Now().Equal(time.Unix(1521221453, 0)) {
+		t.Errorf("clock should have advanced 3s, got %s", clock.Now())
+	}
+
+	// incomplete simulator is rejected
+	if _, err := (&Simulator{}).Run(); err == nil {
+		t.Errorf("empty simulator should be rejected")
+	}
+}
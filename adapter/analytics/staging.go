@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -37,6 +38,109 @@ func (m *manager) stageFile(tenant, tempFile string) {
 	m.log.Debugf("staged file: %s", stagedFile)
 }
 
+const (
+	// staged files smaller than this are candidates for compaction
+	compactionSizeThreshold = 64 * 1024
+	// minimum number of small files before compaction kicks in
+	compactionMinFiles = 5
+	// a merged file is bounded to this size; further candidates wait for
+	// the next pass
+	compactionMaxOutput = 512 * 1024
+)
+
+// compactAllStaging runs a compaction pass over every tenant staging dir.
+// Files younger than minAge are left alone - during normal operation they
+// are already queued for upload, and compacting them could race the
+// uploaders (the pipeline is at-least-once either way).
+func (m *manager) compactAllStaging(minAge time.Duration) {
+	tenantDirs, err := ioutil.ReadDir(m.stagingDir)
+	if err != nil {
+		m.log.Debugf("ls %s: %s", m.stagingDir, err)
+		return
+	}
+	for _, d := range tenantDirs {
+		tenant := m.readTenantMarker(d.Name())
+		if err := m.compactStaging(tenant, minAge); err != nil {
+			m.log.Warningf("staging compaction: %s", err)
+		}
+	}
+}
+
+// compactStaging merges a tenant's many small staged gzip files into a
+// single bounded file to reduce upload overhead. Concatenated gzip members
+// form a valid stream, so files are simply appended. Compaction is skipped
+// for non-gzip uploaders and when buffer encryption is enabled (each
+// encrypted file has its own IV).
+func (m *manager) compactStaging(tenant string, minAge time.Duration) error {
+	if m.crypter != nil || !m.uploader.isGzipped() {
+		return nil
+	}
+
+	dir := m.getStagingDir(tenant)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("ls %s: %s", dir, err)
+	}
+
+	oldEnough := m.now().Add(-minAge)
+	var small []string
+	for _, fi := range files {
+		// never merge a committed (already uploaded) file into a fresh one,
+		// and leave per-destination mirror copies to their own uploads
+		if m.commitLog.isCommitted(fi.Name()) {
+			continue
+		}
+		if _, ok := mirrorIndex(fi.Name()); ok {
+			continue
+		}
+		if !fi.IsDir() && fi.Size() < compactionSizeThreshold && !fi.ModTime().After(oldEnough) {
+			small = append(small, filepath.Join(dir, fi.Name()))
+		}
+	}
+	if len(small) < compactionMinFiles {
+		return nil
+	}
+
+	out, err := ioutil.TempFile(dir, fmt.Sprintf("%d-compacted-*.gz", m.now().Unix()))
+	if err != nil {
+		return fmt.Errorf("create compacted file: %s", err)
+	}
+
+	var compacted []string
+	var written int64
+	for _, fileName := range small {
+		if written >= compactionMaxOutput {
+			break // remaining candidates wait for the next pass
+		}
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			m.log.Warningf("compaction skipping %s: %s", fileName, err)
+			continue
+		}
+		if _, err := out.Write(data); err != nil {
+			out.Close()
+			os.Remove(out.Name())
+			return fmt.Errorf("write compacted file: %s", err)
+		}
+		written += int64(len(data))
+		compacted = append(compacted, fileName)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return fmt.Errorf("close compacted file: %s", err)
+	}
+
+	// sources are only removed once the compacted file is durable
+	for _, fileName := range compacted {
+		if err := os.Remove(fileName); err != nil {
+			m.log.Warningf("unable to remove compacted source %s: %s", fileName, err)
+		}
+	}
+
+	m.log.Infof("compacted %d staged files into %s", len(compacted), out.Name())
+	return nil
+}
+
 func (m *manager) getFilesInStaging() ([]string, error) {
 	tenantDirs, err := ioutil.ReadDir(m.stagingDir)
 	if err != nil {
@@ -68,11 +172,25 @@ func (m *manager) stageAllBucketsWait() {
 }
 
 func (m *manager) stageAllBuckets(wait *sync.WaitGroup) {
+	now := m.now()
+	toStage := map[string]*bucket{}
+
 	m.bucketsLock.Lock()
-	buckets := m.buckets
-	m.buckets = map[string]*bucket{}
+	for tenant, bucket := range m.buckets {
+		// tenants with an interval override stage on their own (longer)
+		// schedule - except on close, when everything must flush
+		if interval, ok := m.tenantIntervals[tenant]; ok && !m.closed {
+			if last, ok := m.lastStaged[tenant]; ok && now.Sub(last) < interval {
+				continue
+			}
+		}
+		toStage[tenant] = bucket
+		delete(m.buckets, tenant)
+		m.lastStaged[tenant] = now
+	}
 	m.bucketsLock.Unlock()
-	for tenant, bucket := range buckets {
+
+	for tenant, bucket := range toStage {
 		m.stageBucket(tenant, bucket, wait)
 	}
 }
@@ -15,6 +15,9 @@
 package analytics
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -110,6 +113,154 @@ func TestStagingSizeCap(t *testing.T) {
 	m.Close()
 }
 
+func TestCompactStaging(t *testing.T) {
+	t.Parallel()
+	env := adaptertest.NewEnv(t)
+
+	workDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %s", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	uploader := &saasUploader{
+		log:    env.Logger(),
+		client: http.DefaultClient,
+		now:    time.Now,
+	}
+
+	m, err := newManager(uploader, Options{
+		BufferPath:         workDir,
+		StagingFileLimit:   10,
+		now:                time.Now,
+		CollectionInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newManager: %s", err)
+	}
+	m.log = env.Logger()
+
+	tenant := "hi~test"
+	if err := m.prepTenant(tenant); err != nil {
+		t.Fatalf("prepTenant: %v", err)
+	}
+
+	rec := Record{Organization: "hi", Environment: "test"}
+	writeStagedGz := func(name string) {
+		f, err := os.Create(filepath.Join(m.getStagingDir(tenant), name))
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		gz := gzip.NewWriter(f)
+		json.NewEncoder(gz).Encode(&rec)
+		gz.Close()
+		f.Close()
+	}
+
+	// below the file count threshold: no compaction
+	for i := 0; i < 3; i++ {
+		writeStagedGz(fmt.Sprintf("small%d.gz", i))
+	}
+	if err := m.compactStaging(tenant, 0); err != nil {
+		t.Fatalf("compactStaging: %v", err)
+	}
+	if files := filesIn(m.getStagingDir(tenant)); len(files) != 3 {
+		t.Errorf("want 3 files, got %d", len(files))
+	}
+
+	// files younger than the minimum age are left alone
+	for i := 3; i < 6; i++ {
+		writeStagedGz(fmt.Sprintf("small%d.gz", i))
+	}
+	if err := m.compactStaging(tenant, time.Hour); err != nil {
+		t.Fatalf("compactStaging: %v", err)
+	}
+	if files := filesIn(m.getStagingDir(tenant)); len(files) != 6 {
+		t.Errorf("want 6 files, got %d", len(files))
+	}
+
+	// above the threshold: merged into a single valid gzip stream
+	if err := m.compactStaging(tenant, 0); err != nil {
+		t.Fatalf("compactStaging: %v", err)
+	}
+	files := filesIn(m.getStagingDir(tenant))
+	if len(files) != 1 {
+		t.Fatalf("want 1 compacted file, got %d: %v", len(files), files)
+	}
+	recs, err := readRecordsFromGZipFile(files[0])
+	if err != nil {
+		t.Fatalf("readRecords: %v", err)
+	}
+	if len(recs) != 6 {
+		t.Errorf("want 6 records in compacted file, got %d", len(recs))
+	}
+}
+
+func TestAdjustCollectionInterval(t *testing.T) {
+	t.Parallel()
+	env := adaptertest.NewEnv(t)
+
+	workDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %s", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	uploader := &saasUploader{
+		log:    env.Logger(),
+		client: http.DefaultClient,
+		now:    time.Now,
+	}
+
+	m, err := newManager(uploader, Options{
+		BufferPath:         workDir,
+		StagingFileLimit:   4,
+		now:                time.Now,
+		CollectionInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newManager: %s", err)
+	}
+	m.log = env.Logger()
+
+	// no backlog: stays at configured interval
+	if got := m.adjustCollectionInterval(m.collectionInterval); got != m.collectionInterval {
+		t.Errorf("want %s, got %s", m.collectionInterval, got)
+	}
+
+	// build a backlog beyond half the staging file limit
+	tenant := "hi~test"
+	if err := m.prepTenant(tenant); err != nil {
+		t.Fatalf("prepTenant: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		f := filepath.Join(m.getStagingDir(tenant), fmt.Sprintf("file%d.gz", i))
+		if err := ioutil.WriteFile(f, []byte("x"), 0600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	interval := m.adjustCollectionInterval(m.collectionInterval)
+	if interval != 2*m.collectionInterval {
+		t.Errorf("want %s, got %s", 2*m.collectionInterval, interval)
+	}
+
+	// bounded at the max multiplier
+	max := m.collectionInterval * maxIntervalMultiplier
+	interval = m.adjustCollectionInterval(max)
+	if interval != max {
+		t.Errorf("want %s, got %s", max, interval)
+	}
+
+	// drained: restores the configured interval
+	for _, f := range filesIn(m.getStagingDir(tenant)) {
+		os.Remove(f)
+	}
+	if got := m.adjustCollectionInterval(interval); got != m.collectionInterval {
+		t.Errorf("want %s, got %s", m.collectionInterval, got)
+	}
+}
+
 func filesIn(path string) []string {
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -0,0 +1,114 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/auth"
+	"istio.io/istio/mixer/pkg/adapter"
+)
+
+// A Tenant identifies the org/env (and upload credentials) records belong
+// to, so tools using the pipeline outside of Mixer don't need to fabricate
+// adapter contexts.
+type Tenant struct {
+	Org    string
+	Env    string
+	Key    string
+	Secret string
+	// BaseURL is the Apigee base for uploads (optional, defaults to the
+	// manager's configured base)
+	BaseURL *url.URL
+}
+
+// Context returns an auth context for the tenant, usable with SendRecords.
+func (t Tenant) Context() *auth.Context {
+	base := t.BaseURL
+	if base == nil {
+		base = &url.URL{}
+	}
+	return &auth.Context{Context: &tenantContext{tenant: t, base: base, log: standaloneLogger{}}}
+}
+
+type tenantContext struct {
+	tenant Tenant
+	base   *url.URL
+	log    adapter.Logger
+}
+
+func (c *tenantContext) Log() adapter.Logger    { return c.log }
+func (c *tenantContext) Organization() string   { return c.tenant.Org }
+func (c *tenantContext) Environment() string    { return c.tenant.Env }
+func (c *tenantContext) Key() string            { return c.tenant.Key }
+func (c *tenantContext) Secret() string         { return c.tenant.Secret }
+func (c *tenantContext) ApigeeBase() *url.URL   { return c.base }
+func (c *tenantContext) CustomerBase() *url.URL { return c.base }
+
+// NewStandaloneManager constructs and starts an analytics Manager that runs
+// outside of Mixer, so other Apigee Go tools can reuse the buffering and
+// upload pipeline. Daemons run as plain goroutines and logs go to the
+// standard logger. Call Close when done.
+func NewStandaloneManager(opts Options) (Manager, error) {
+	return NewManager(NewStandaloneEnv(), opts)
+}
+
+// NewStandaloneEnv returns a minimal adapter.Env for running managers
+// outside of Mixer.
+func NewStandaloneEnv() adapter.Env {
+	return &standaloneEnv{}
+}
+
+type standaloneEnv struct{}
+
+func (e *standaloneEnv) Logger() adapter.Logger {
+	return standaloneLogger{}
+}
+
+func (e *standaloneEnv) ScheduleWork(fn adapter.WorkFunc) {
+	go fn()
+}
+
+func (e *standaloneEnv) ScheduleDaemon(fn adapter.DaemonFunc) {
+	go fn()
+}
+
+// standaloneLogger writes to the standard logger
+type standaloneLogger struct{}
+
+func (l standaloneLogger) Infof(format string, args ...interface{}) {
+	log.Printf("I "+format, args...)
+}
+
+func (l standaloneLogger) Warningf(format string, args ...interface{}) {
+	log.Printf("W "+format, args...)
+}
+
+func (l standaloneLogger) Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	log.Print("E " + err.Error())
+	return err
+}
+
+func (l standaloneLogger) Debugf(format string, args ...interface{}) {
+	log.Printf("D "+format, args...)
+}
+
+func (l standaloneLogger) InfoEnabled() bool  { return true }
+func (l standaloneLogger) WarnEnabled() bool  { return true }
+func (l standaloneLogger) ErrorEnabled() bool { return true }
+func (l standaloneLogger) DebugEnabled() bool { return false }
@@ -0,0 +1,50 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStandaloneManager(t *testing.T) {
+	workDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(): %s", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	m, err := NewStandaloneManager(Options{
+		BufferPath:         workDir,
+		StagingFileLimit:   10,
+		BaseURL:            &url.URL{},
+		Key:                "key",
+		Secret:             "secret",
+		Client:             http.DefaultClient,
+		CollectionInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewStandaloneManager: %s", err)
+	}
+	m.Close()
+
+	if _, ok := m.(*manager); !ok {
+		t.Errorf("want a *manager, got: %#v", m)
+	}
+}
@@ -18,8 +18,10 @@ package auth
 // Authenticate function.
 
 import (
+	gocontext "context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/context"
@@ -45,24 +47,61 @@ func NewManager(env adapter.Env, options Options) (*Manager, error) {
 		return nil, err
 	}
 	jwtMan := newJWTManager(options.PollInterval)
+	jwtMan.client = options.Client
+	jwtMan.skew = options.JWTSkewTolerance
+	jwtMan.jwksFile = options.JWKSFile
+	jwtMan.hs256Secret = options.HS256Secret
 	v := newVerifier(env, jwtMan, keyVerifierOpts{
 		Client:   options.Client,
 		CacheTTL: options.APIKeyCacheDuration,
+		Key:      options.Key,
+		Secret:   options.Secret,
 	})
 	am := &Manager{
 		env:      env,
 		jwtMan:   jwtMan,
 		verifier: v,
 	}
+	if options.TokenExchangeURL != "" {
+		am.exchanger = newTokenExchanger(options.TokenExchangeURL, options.Client, jwtMan)
+	}
+	if options.RevocationCheckURL != "" {
+		am.revocation = newRevocationChecker(options.RevocationCheckURL, options.Client)
+	}
 	am.start()
 	return am, nil
 }
 
 // An Manager handles all things related to authentication.
 type Manager struct {
-	env      adapter.Env
-	jwtMan   *jwtManager
-	verifier keyVerifier
+	env        adapter.Env
+	jwtMan     *jwtManager
+	verifier   keyVerifier
+	exchanger  *tokenExchanger
+	revocation *revocationChecker
+}
+
+// VerifyAccessToken verifies a raw Apigee OAuth 2.0 JWT access token:
+// signature and standard claims against the org's JWKS, plus a revocation
+// check on the token's jti when a revocation endpoint is configured.
+func (m *Manager) VerifyAccessToken(ctx context.Context, token string) (map[string]interface{}, error) {
+	claims, err := m.jwtMan.parseJWT(ctx, token, true)
+	if err != nil {
+		return nil, ErrBadAuth
+	}
+	if m.revocation != nil {
+		if jti, ok := claims["jti"].(string); ok && jti != "" {
+			revoked, err := m.revocation.isRevoked(ctx, jti)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				ctx.Log().Debugf("access token %s revoked", util.Truncate(jti, 10))
+				return nil, ErrBadAuth
+			}
+		}
+	}
+	return claims, nil
 }
 
 // Close shuts down the Manager.
@@ -101,9 +140,9 @@ func (m *Manager) Authenticate(ctx context.Context, apiKey string,
 	var authenticationError, claimsError error
 	var verifiedClaims map[string]interface{}
 
-	if claims[apiKeyClaimKey] != nil {
+	if apiKeyClaim := ClaimValue(claims, apiKeyClaimKey); apiKeyClaim != nil {
 		authAttempted = true
-		if apiKey, ok := claims[apiKeyClaimKey].(string); ok {
+		if apiKey, ok := apiKeyClaim.(string); ok {
 			verifiedClaims, authenticationError = m.verifier.Verify(ctx, apiKey)
 			if authenticationError == nil {
 				log.Debugf("using api key from jwt claim %s", apiKeyClaimKey)
@@ -134,6 +173,37 @@ func (m *Manager) Authenticate(ctx context.Context, apiKey string,
 		authAttempted = true
 	}
 
+	// if still unauthenticated and an external IdP token is present, exchange
+	// it for an Apigee JWT
+	if !authContext.isAuthenticated() && m.exchanger != nil {
+		if external, ok := claims[accessTokenClaim].(string); ok && external != "" {
+			exchanged, exchangeErr := m.exchanger.exchange(ctx, external)
+			if exchangeErr != nil {
+				log.Debugf("token exchange failed: %v", exchangeErr)
+			} else if claimsError = authContext.setClaims(exchanged); claimsError == nil {
+				log.Debugf("authenticated via token exchange")
+				authenticationError = nil
+				authAttempted = true
+			}
+		}
+	}
+
+	// credentials on manual-approval products may still be pending
+	if authenticationError == nil && authContext.isAuthenticated() && !authContext.isApproved() {
+		log.Debugf("credential status is %s, not approved", authContext.CredentialStatus)
+		authenticationError = ErrBadAuth
+	}
+
+	// raw Apigee OAuth JWT access tokens get signature and revocation checks
+	// when a revocation endpoint is configured
+	if authenticationError == nil && m.revocation != nil && authContext.AccessToken != "" &&
+		strings.Count(authContext.AccessToken, ".") == 2 {
+		if _, err := m.VerifyAccessToken(ctx, authContext.AccessToken); err != nil {
+			log.Debugf("access token verification failed: %v", err)
+			authenticationError = ErrBadAuth
+		}
+	}
+
 	if authenticationError != nil && authenticationError != ErrBadAuth {
 		authenticationError = ErrInternalError
 	}
@@ -163,6 +233,51 @@ func (m *Manager) start() {
 	m.jwtMan.start(m.env)
 }
 
+// A RequestContexter optionally exposes the per-request context of an
+// adapter context so deadlines and cancellation propagate to synchronous
+// Apigee calls.
+type RequestContexter interface {
+	RequestContext() gocontext.Context
+}
+
+// WithRequestContext binds a per-request context to an adapter context.
+func WithRequestContext(ctx context.Context, reqCtx gocontext.Context) context.Context {
+	return &requestContext{Context: ctx, reqCtx: reqCtx}
+}
+
+type requestContext struct {
+	context.Context
+	reqCtx gocontext.Context
+}
+
+// RequestContext implements RequestContexter
+func (r *requestContext) RequestContext() gocontext.Context {
+	return r.reqCtx
+}
+
+// ClaimValue returns the value at the given claim key. The key may be a
+// dot-delimited path (eg. "identity.api_key") to address claims nested in
+// object-valued claims; a literal top-level key always takes precedence.
+func ClaimValue(claims map[string]interface{}, key string) interface{} {
+	if len(claims) == 0 || key == "" {
+		return nil
+	}
+	if v, ok := claims[key]; ok {
+		return v
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return current
+}
+
 // Options allows us to specify options for how this auth manager will run
 type Options struct {
 	// PollInterval sets refresh rate of JWT credentials, disabled if = 0
@@ -171,6 +286,24 @@ type Options struct {
 	Client *http.Client
 	// APIKeyCacheDuration is the length of time APIKeys are cached when unable to refresh
 	APIKeyCacheDuration time.Duration
+	// Key overrides the context key for API key verification calls (optional)
+	Key string
+	// Secret overrides the context secret for API key verification calls (optional)
+	Secret string
+	// JWTSkewTolerance is the nbf/exp leeway allowed for clock drift (default: 10s)
+	JWTSkewTolerance time.Duration
+	// TokenExchangeURL, if set, is used to exchange external IdP tokens for
+	// Apigee JWTs when other authentication methods do not apply
+	TokenExchangeURL string
+	// RevocationCheckURL, if set, enables revocation checks for Apigee OAuth
+	// JWT access tokens by jti
+	RevocationCheckURL string
+	// JWKSFile, if set, reads the JWKS from a local file (eg. mounted from a
+	// Kubernetes Secret on hybrid) instead of fetching it over the network
+	JWKSFile string
+	// HS256Secret, if set, additionally accepts JWTs HMAC-signed with this
+	// shared secret (for OPDK installs without an org keypair)
+	HS256Secret []byte
 }
 
 func (o *Options) validate() error {
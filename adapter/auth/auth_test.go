@@ -129,3 +129,35 @@ func TestAuthenticate(t *testing.T) {
 		}
 	}
 }
+
+func TestClaimValue(t *testing.T) {
+	claims := map[string]interface{}{
+		"api_key": "top",
+		"identity": map[string]interface{}{
+			"api_key": "nested",
+		},
+		"dotted.key": "literal",
+	}
+
+	cases := []struct {
+		key  string
+		want interface{}
+	}{
+		{"api_key", "top"},
+		{"identity.api_key", "nested"},
+		{"dotted.key", "literal"}, // literal key wins over path
+		{"identity.missing", nil},
+		{"missing", nil},
+		{"api_key.nested", nil}, // can't descend into a string
+		{"", nil},
+	}
+	for _, c := range cases {
+		if got := ClaimValue(claims, c.key); got != c.want {
+			t.Errorf("key %s: want %v, got %v", c.key, c.want, got)
+		}
+	}
+
+	if ClaimValue(nil, "api_key") != nil {
+		t.Errorf("nil claims should give nil")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/context"
@@ -33,13 +34,19 @@ const (
 	expClaim             = "exp"
 	developerEmailClaim  = "application_developeremail"
 	accessTokenClaim     = "access_token"
+	statusClaim          = "status"
+
+	// statusApproved is the credential status required for access; products
+	// with approvalType=manual issue credentials in a pending state until a
+	// human approves them
+	statusApproved = "approved"
 )
 
 var (
 	// AllValidClaims is a list of the claims expected from a JWT token
 	AllValidClaims = []string{
 		apiProductListClaim, audienceClaim, clientIDClaim, applicationNameClaim,
-		scopesClaim, expClaim, developerEmailClaim,
+		scopesClaim, expClaim, developerEmailClaim, statusClaim,
 	}
 )
 
@@ -55,6 +62,18 @@ type Context struct {
 	DeveloperEmail string
 	Scopes         []string
 	APIKey         string
+
+	// CredentialStatus is the approval status of the credential (eg.
+	// "approved" or "pending" for manual-approval products)
+	CredentialStatus string
+
+	// QuotaIdentifier optionally buckets quotas for requests with no
+	// application identity (eg. by a client header value)
+	QuotaIdentifier string
+
+	// ProductAttributes carries the custom attributes of the products the
+	// request resolved to, for downstream consumers
+	ProductAttributes map[string]string
 }
 
 func parseExp(claims map[string]interface{}) (time.Time, error) {
@@ -109,10 +128,17 @@ func (a *Context) setClaims(claims map[string]interface{}) error {
 	a.Expires = exp
 	a.DeveloperEmail, _ = claims[developerEmailClaim].(string)
 	a.AccessToken, _ = claims[accessTokenClaim].(string)
+	a.CredentialStatus, _ = claims[statusClaim].(string)
 
 	return nil
 }
 
+// isApproved is false if the credential carries a non-approved status
+// (eg. pending approval on a manual-approval product)
+func (a *Context) isApproved() bool {
+	return a.CredentialStatus == "" || strings.EqualFold(a.CredentialStatus, statusApproved)
+}
+
 func (a *Context) isAuthenticated() bool {
 	return a.ClientID != ""
 }
@@ -107,3 +107,22 @@ func TestSetClaims(t *testing.T) {
 	//Scopes         []string
 	//APIKey         string
 }
+
+func TestCredentialStatus(t *testing.T) {
+	cases := []struct {
+		status   string
+		approved bool
+	}{
+		{"", true}, // legacy tokens carry no status
+		{"approved", true},
+		{"Approved", true},
+		{"pending", false},
+		{"revoked", false},
+	}
+	for _, c := range cases {
+		ctx := &Context{CredentialStatus: c.status}
+		if got := ctx.isApproved(); got != c.approved {
+			t.Errorf("status %q: want approved=%v, got %v", c.status, c.approved, got)
+		}
+	}
+}
@@ -17,12 +17,17 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"path"
 	"sync"
 	"time"
 
 	adapterContext "github.com/apigee/istio-mixer-adapter/adapter/context"
 	"github.com/apigee/istio-mixer-adapter/adapter/util"
+	"github.com/lestrrat/go-jwx/jwa"
 	"github.com/lestrrat/go-jwx/jwk"
 	"github.com/lestrrat/go-jwx/jws"
 	"github.com/lestrrat/go-jwx/jwt"
@@ -31,8 +36,13 @@ import (
 )
 
 const (
-	certsPath      = "/certs"
-	acceptableSkew = 10 * time.Second
+	certsPath = "/certs"
+
+	// defaultSkew is the nbf/exp leeway applied when none is configured
+	defaultSkew = 10 * time.Second
+
+	// how often a mounted JWKS file is checked for rotation
+	jwksFileCheckInterval = 10 * time.Second
 )
 
 func newJWTManager(pollInterval time.Duration) *jwtManager {
@@ -44,25 +54,89 @@ func newJWTManager(pollInterval time.Duration) *jwtManager {
 
 // An jwtManager handles all of the various JWT authentication functionality.
 type jwtManager struct {
-	jwkSets       sync.Map
+	jwkSets       sync.Map // url -> *jwk.Set
+	etags         sync.Map // url -> last seen ETag
 	pollInterval  time.Duration
 	cancelPolling context.CancelFunc
+	client        *http.Client
+	skew          time.Duration // nbf/exp leeway for clock drift
+	jwksFile      string        // file-based JWKS (eg. mounted Kubernetes Secret)
+	hs256Secret   []byte        // shared-secret HMAC verification (OPDK installs)
+}
+
+func (a *jwtManager) acceptableSkew() time.Duration {
+	if a.skew > 0 {
+		return a.skew
+	}
+	return defaultSkew
+}
+
+func (a *jwtManager) httpClient() *http.Client {
+	if a.client != nil {
+		return a.client
+	}
+	return http.DefaultClient
 }
 
 func (a *jwtManager) start(env adapter.Env) {
+	if a.pollInterval <= 0 && a.jwksFile == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelPolling = cancel
+
 	if a.pollInterval > 0 {
 		env.Logger().Debugf("starting cert polling")
 		looper := util.Looper{
 			Env:     env,
 			Backoff: util.NewExponentialBackoff(200*time.Millisecond, a.pollInterval, 2, true),
 		}
-		ctx, cancel := context.WithCancel(context.Background())
-		a.cancelPolling = cancel
 		looper.Start(ctx, a.refresh, a.pollInterval, func(err error) error {
 			env.Logger().Errorf("Error refreshing cert set: %s", err)
 			return nil
 		})
 	}
+
+	// a mounted JWKS file (eg. a Kubernetes Secret) is watched for rotation
+	// independently of the cert poll interval, which is 0 in the adapter
+	if a.jwksFile != "" {
+		env.ScheduleDaemon(func() {
+			a.watchJWKSFile(ctx, env)
+		})
+	}
+}
+
+// watchJWKSFile re-reads the JWKS file whenever its mtime changes
+func (a *jwtManager) watchJWKSFile(ctx context.Context, env adapter.Env) {
+	var lastMod time.Time
+	if fi, err := os.Stat(a.jwksFile); err == nil {
+		lastMod = fi.ModTime()
+	}
+
+	t := time.NewTicker(jwksFileCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			fi, err := os.Stat(a.jwksFile)
+			if err != nil {
+				env.Logger().Warningf("jwks file %s: %s", a.jwksFile, err)
+				continue
+			}
+			if fi.ModTime().Equal(lastMod) {
+				continue
+			}
+			lastMod = fi.ModTime()
+			if err := a.ensureSet(a.jwksFile); err != nil {
+				env.Logger().Errorf("Error reloading rotated jwks file: %s", err)
+			} else {
+				env.Logger().Infof("reloaded rotated jwks file: %s", a.jwksFile)
+			}
+		}
+	}
 }
 
 func (a *jwtManager) stop() {
@@ -71,12 +145,63 @@ func (a *jwtManager) stop() {
 	}
 }
 
+// ensureSet fetches and caches the JWKS for the url. Sets are kept per issuer
+// url and refreshes are revalidated with If-None-Match when the issuer
+// returned an ETag, avoiding repeated downloads of unchanged key sets.
+// When a JWKS file is configured (eg. mounted from a Kubernetes Secret on
+// hybrid), it is (re)read instead of fetching from the network.
 func (a *jwtManager) ensureSet(url string) error {
-	set, err := jwk.FetchHTTP(url)
+	if a.jwksFile != "" && url == a.jwksFile {
+		data, err := ioutil.ReadFile(a.jwksFile)
+		if err != nil {
+			return err
+		}
+		set, err := jwk.ParseBytes(data)
+		if err != nil {
+			return fmt.Errorf("parse jwks file %s: %s", a.jwksFile, err)
+		}
+		a.jwkSets.Store(url, set)
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if etag, ok := a.etags.Load(url); ok {
+		if _, ok := a.jwkSets.Load(url); ok {
+			req.Header.Set("If-None-Match", etag.(string))
+		}
+	}
+
+	resp, err := a.httpClient().Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil // cached set is still valid
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks from %s: %s", url, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	set, err := jwk.ParseBytes(body)
+	if err != nil {
+		return err
+	}
+
 	a.jwkSets.Store(url, set)
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		a.etags.Store(url, etag)
+	} else {
+		a.etags.Delete(url)
+	}
 	return nil
 }
 
@@ -92,9 +217,12 @@ func (a *jwtManager) refresh(ctx context.Context) error {
 }
 
 func (a *jwtManager) jwkSet(ctx adapterContext.Context) (*jwk.Set, error) {
-	jwksURL := *ctx.CustomerBase()
-	jwksURL.Path = path.Join(jwksURL.Path, certsPath)
-	url := jwksURL.String()
+	url := a.jwksFile
+	if url == "" {
+		jwksURL := *ctx.CustomerBase()
+		jwksURL.Path = path.Join(jwksURL.Path, certsPath)
+		url = jwksURL.String()
+	}
 	if _, ok := a.jwkSets.Load(url); !ok {
 		if err := a.ensureSet(url); err != nil {
 			return nil, err
@@ -107,15 +235,26 @@ func (a *jwtManager) jwkSet(ctx adapterContext.Context) (*jwk.Set, error) {
 func (a *jwtManager) parseJWT(ctx adapterContext.Context, raw string, verify bool) (map[string]interface{}, error) {
 
 	if verify {
-		set, err := a.jwkSet(ctx)
-		if err != nil {
-			return nil, err
+		// OPDK installs may sign with an HS256 shared secret instead of the
+		// org keypair; accept either when a secret is configured
+		verified := false
+		if len(a.hs256Secret) > 0 {
+			if _, err := jws.Verify([]byte(raw), jwa.HS256, a.hs256Secret); err == nil {
+				verified = true
+			}
 		}
 
-		// verify against public keys
-		_, err = jws.VerifyWithJWKSet([]byte(raw), set, nil)
-		if err != nil {
-			return nil, err
+		if !verified {
+			set, err := a.jwkSet(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			// verify against public keys
+			_, err = jws.VerifyWithJWKSet([]byte(raw), set, nil)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -126,7 +265,7 @@ func (a *jwtManager) parseJWT(ctx adapterContext.Context, raw string, verify boo
 			return nil, errors.Wrap(err, "invalid jws message")
 		}
 
-		err = token.Verify(jwt.WithAcceptableSkew(acceptableSkew))
+		err = token.Verify(jwt.WithAcceptableSkew(a.acceptableSkew()))
 		if err != nil {
 			return nil, errors.Wrap(err, "invalid jws message")
 		}
@@ -105,6 +105,58 @@ func TestJWTCaching(t *testing.T) {
 	}
 }
 
+func TestJWKSETagRevalidation(t *testing.T) {
+	env := test.NewEnv(t)
+	jwtMan := newJWTManager(time.Hour)
+	jwtMan.start(env)
+	defer jwtMan.stop()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := goodJWTRequest(privateKey, t)
+	var fullFetches, revalidations int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidations++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullFetches++
+		w.Header().Set("ETag", `"v1"`)
+		good(w, r)
+	}))
+	defer ts.Close()
+
+	ctx := authtest.NewContext(ts.URL, test.NewEnv(t))
+
+	jwt, err := generateJWT(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwtMan.parseJWT(ctx, jwt, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// refresh should revalidate with If-None-Match and keep the cached set
+	if err := jwtMan.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if fullFetches != 1 {
+		t.Errorf("want 1 full fetch, got %d", fullFetches)
+	}
+	if revalidations != 1 {
+		t.Errorf("want 1 revalidation, got %d", revalidations)
+	}
+
+	// cached set must still verify JWTs after a 304
+	if _, err := jwtMan.parseJWT(ctx, jwt, true); err != nil {
+		t.Errorf("JWT should still verify after 304: %v", err)
+	}
+}
+
 func TestGoodAndBadJWT(t *testing.T) {
 	env := test.NewEnv(t)
 	jwtMan := newJWTManager(time.Hour)
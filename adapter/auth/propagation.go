@@ -0,0 +1,95 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/context"
+)
+
+// PropagationHeader is the suggested header for carrying a propagated auth
+// context between chained adapters.
+const PropagationHeader = "x-apigee-auth-context"
+
+// propagatedContext is the portable form of a Context. Credentials (API key,
+// access token) are deliberately not propagated - downstream adapters get
+// the authorization outcome, not the secrets that produced it.
+type propagatedContext struct {
+	ClientID         string            `json:"client_id,omitempty"`
+	Application      string            `json:"application,omitempty"`
+	APIProducts      []string          `json:"api_products,omitempty"`
+	Expires          int64             `json:"expires,omitempty"`
+	DeveloperEmail   string            `json:"developer_email,omitempty"`
+	Scopes           []string          `json:"scopes,omitempty"`
+	CredentialStatus string            `json:"credential_status,omitempty"`
+	Attributes       map[string]string `json:"attributes,omitempty"`
+}
+
+// Propagate encodes the portable parts of an authenticated Context as
+// base64 JSON, suitable for a header to chained adapters.
+func Propagate(ac *Context) (string, error) {
+	if ac == nil || !ac.isAuthenticated() {
+		return "", fmt.Errorf("cannot propagate an unauthenticated context")
+	}
+	data, err := json.Marshal(propagatedContext{
+		ClientID:         ac.ClientID,
+		Application:      ac.Application,
+		APIProducts:      ac.APIProducts,
+		Expires:          ac.Expires.Unix(),
+		DeveloperEmail:   ac.DeveloperEmail,
+		Scopes:           ac.Scopes,
+		CredentialStatus: ac.CredentialStatus,
+		Attributes:       ac.ProductAttributes,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// FromPropagated decodes a propagated auth context into a Context bound to
+// ctx. Expired contexts are rejected.
+func FromPropagated(ctx context.Context, encoded string) (*Context, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode propagated context: %s", err)
+	}
+	var pc propagatedContext
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("unmarshal propagated context: %s", err)
+	}
+	if pc.ClientID == "" {
+		return nil, fmt.Errorf("propagated context is not authenticated")
+	}
+	expires := time.Unix(pc.Expires, 0)
+	if time.Now().After(expires) {
+		return nil, fmt.Errorf("propagated context expired at %s", expires)
+	}
+	return &Context{
+		Context:           ctx,
+		ClientID:          pc.ClientID,
+		Application:       pc.Application,
+		APIProducts:       pc.APIProducts,
+		Expires:           expires,
+		DeveloperEmail:    pc.DeveloperEmail,
+		Scopes:            pc.Scopes,
+		CredentialStatus:  pc.CredentialStatus,
+		ProductAttributes: pc.Attributes,
+	}, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/authtest"
+	adaptertest "istio.io/istio/mixer/pkg/adapter/test"
+)
+
+func TestPropagation(t *testing.T) {
+	ac := &Context{
+		ClientID:       "clientID",
+		Application:    "app",
+		APIProducts:    []string{"product1", "product2"},
+		Expires:        time.Now().Add(time.Hour),
+		DeveloperEmail: "dev@example.com",
+		Scopes:         []string{"scope1"},
+		APIKey:         "secretKey",
+		AccessToken:    "secretToken",
+	}
+
+	encoded, err := Propagate(ac)
+	if err != nil {
+		t.Fatalf("Propagate: %v", err)
+	}
+	if strings.Contains(encoded, "secret") {
+		t.Errorf("credentials must not be propagated")
+	}
+
+	ctx := authtest.NewContext("", adaptertest.NewEnv(t))
+	got, err := FromPropagated(ctx, encoded)
+	if err != nil {
+		t.Fatalf("FromPropagated: %v", err)
+	}
+	if got.ClientID != ac.ClientID || got.Application != ac.Application ||
+		!reflect.DeepEqual(got.APIProducts, ac.APIProducts) ||
+		!reflect.DeepEqual(got.Scopes, ac.Scopes) ||
+		got.DeveloperEmail != ac.DeveloperEmail {
+		t.Errorf("round trip mismatch: %#v", got)
+	}
+	if got.APIKey != "" || got.AccessToken != "" {
+		t.Errorf("credentials must not survive propagation")
+	}
+
+	// unauthenticated contexts can't be propagated
+	if _, err := Propagate(&Context{}); err == nil {
+		t.Errorf("unauthenticated context should not propagate")
+	}
+
+	// expired contexts are rejected
+	ac.Expires = time.Now().Add(-time.Hour)
+	encoded, err = Propagate(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromPropagated(ctx, encoded); err == nil {
+		t.Errorf("expired context should be rejected")
+	}
+
+	// garbage is rejected
+	if _, err := FromPropagated(ctx, "not-base64-json!"); err == nil {
+		t.Errorf("garbage should be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/context"
+	"istio.io/istio/pkg/cache"
+)
+
+const (
+	revocationCacheTTL      = time.Minute
+	revocationCacheEviction = 10 * time.Second
+	revocationCacheEntries  = 10000
+)
+
+// A revocationChecker checks Apigee OAuth 2.0 JWT access tokens against a
+// revocation endpoint by jti, caching results briefly so hot tokens don't
+// hammer the endpoint.
+type revocationChecker struct {
+	url    string
+	client *http.Client
+	cache  cache.ExpiringCache
+}
+
+func newRevocationChecker(url string, client *http.Client) *revocationChecker {
+	return &revocationChecker{
+		url:    url,
+		client: client,
+		cache:  cache.NewLRU(revocationCacheTTL, revocationCacheEviction, revocationCacheEntries),
+	}
+}
+
+type revocationRequest struct {
+	JTI string `json:"jti"`
+}
+
+type revocationResponse struct {
+	Revoked bool `json:"revoked"`
+}
+
+// isRevoked is true if the token id has been revoked
+func (rc *revocationChecker) isRevoked(ctx context.Context, jti string) (bool, error) {
+	if cached, ok := rc.cache.Get(jti); ok {
+		return cached.(bool), nil
+	}
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(revocationRequest{JTI: jti}); err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rc.url, body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(ctx.Key(), ctx.Secret())
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("revocation check returned %s", resp.Status)
+	}
+
+	var result revocationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("error decoding revocation response: %s", err)
+	}
+
+	rc.cache.Set(jti, result.Revoked)
+	return result.Revoked, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/authtest"
+	"istio.io/istio/mixer/pkg/adapter/test"
+)
+
+func TestRevocationChecker(t *testing.T) {
+	var checks int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks++
+		var req revocationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revocationResponse{Revoked: req.JTI == "revoked-jti"})
+	}))
+	defer ts.Close()
+
+	checker := newRevocationChecker(ts.URL, http.DefaultClient)
+	ctx := authtest.NewContext("", test.NewEnv(t))
+
+	revoked, err := checker.isRevoked(ctx, "good-jti")
+	if err != nil {
+		t.Fatalf("isRevoked: %v", err)
+	}
+	if revoked {
+		t.Errorf("good-jti should not be revoked")
+	}
+
+	revoked, err = checker.isRevoked(ctx, "revoked-jti")
+	if err != nil {
+		t.Fatalf("isRevoked: %v", err)
+	}
+	if !revoked {
+		t.Errorf("revoked-jti should be revoked")
+	}
+
+	// repeats are served from cache
+	if _, err := checker.isRevoked(ctx, "good-jti"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checker.isRevoked(ctx, "revoked-jti"); err != nil {
+		t.Fatal(err)
+	}
+	if checks != 2 {
+		t.Errorf("want 2 checks, got %d", checks)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/context"
+	"golang.org/x/sync/singleflight"
+	"istio.io/istio/pkg/cache"
+)
+
+const (
+	// exchanged claims are cached (keyed by a hash of the external token) so
+	// hot tokens don't hammer the exchange endpoint on every request
+	exchangeCacheTTL      = 3 * time.Minute
+	exchangeCacheEviction = 30 * time.Second
+	exchangeCacheEntries  = 10000
+)
+
+// A tokenExchanger exchanges an external IdP token for an Apigee JWT via a
+// configured endpoint (eg. the istio-auth proxy's token endpoint), so
+// clients of an external identity provider can be authorized against Apigee
+// products without re-issuing credentials. Results are cached and concurrent
+// exchanges of the same token are deduplicated.
+type tokenExchanger struct {
+	url        string
+	client     *http.Client
+	jwtMan     *jwtManager
+	cache      cache.ExpiringCache
+	herdBuster singleflight.Group
+}
+
+func newTokenExchanger(url string, client *http.Client, jwtMan *jwtManager) *tokenExchanger {
+	return &tokenExchanger{
+		url:    url,
+		client: client,
+		jwtMan: jwtMan,
+		cache:  cache.NewLRU(exchangeCacheTTL, exchangeCacheEviction, exchangeCacheEntries),
+	}
+}
+
+type tokenExchangeRequest struct {
+	Token string `json:"token"`
+}
+
+type tokenExchangeResponse struct {
+	Token string `json:"token"`
+}
+
+// exchange trades an external token for verified Apigee JWT claims,
+// serving repeats from cache
+func (t *tokenExchanger) exchange(ctx context.Context, externalToken string) (map[string]interface{}, error) {
+	key := hashAPIKey(externalToken)
+	if cached, ok := t.cache.Get(key); ok {
+		return cached.(map[string]interface{}), nil
+	}
+
+	claims, err, _ := t.herdBuster.Do(key, func() (interface{}, error) {
+		claims, err := t.exchangeUncached(ctx, externalToken)
+		if err == nil {
+			t.cache.Set(key, claims)
+		}
+		return claims, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims.(map[string]interface{}), nil
+}
+
+func (t *tokenExchanger) exchangeUncached(ctx context.Context, externalToken string) (map[string]interface{}, error) {
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(tokenExchangeRequest{Token: externalToken}); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(ctx.Key(), ctx.Secret())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange returned %s", resp.Status)
+	}
+
+	var exchangeResp tokenExchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&exchangeResp); err != nil {
+		return nil, fmt.Errorf("error decoding token exchange response: %s", err)
+	}
+	if exchangeResp.Token == "" {
+		return nil, fmt.Errorf("token exchange returned no token")
+	}
+
+	// verify the exchanged Apigee JWT and return its claims
+	return t.jwtMan.parseJWT(ctx, exchangeResp.Token, true)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/authtest"
+	"istio.io/istio/mixer/pkg/adapter/test"
+)
+
+func TestTokenExchange(t *testing.T) {
+	env := test.NewEnv(t)
+	jwtMan := newJWTManager(time.Hour)
+	jwtMan.start(env)
+	defer jwtMan.stop()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apigeeJWT, err := generateJWT(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exchanges int
+	good := goodJWTRequest(privateKey, t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, certsPath) {
+			good(w, r)
+			return
+		}
+		// the exchange endpoint
+		exchanges++
+		var req tokenExchangeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Token != "external-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokenExchangeResponse{Token: apigeeJWT})
+	}))
+	defer ts.Close()
+
+	exchanger := newTokenExchanger(ts.URL+"/token", http.DefaultClient, jwtMan)
+	ctx := authtest.NewContext(ts.URL, test.NewEnv(t))
+
+	claims, err := exchanger.exchange(ctx, "external-token")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if claims["client_id"] != "yBQ5eXZA8rSoipYEi1Rmn0Z8RKtkGI4H" {
+		t.Errorf("exchanged claims should come from the Apigee JWT: %v", claims)
+	}
+
+	// repeats are served from cache
+	if _, err := exchanger.exchange(ctx, "external-token"); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if exchanges != 1 {
+		t.Errorf("want 1 exchange call, got %d", exchanges)
+	}
+
+	// rejected tokens are not cached as successes
+	if _, err := exchanger.exchange(ctx, "bad-token"); err == nil {
+		t.Errorf("rejected exchange should error")
+	}
+	if _, ok := exchanger.cache.Get(hashAPIKey("bad-token")); ok {
+		t.Errorf("failed exchanges must not be cached")
+	}
+}
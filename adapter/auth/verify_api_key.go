@@ -24,6 +24,8 @@ package auth
 import (
 	"bytes"
 	contex "context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"path"
@@ -45,13 +47,33 @@ const (
 	defaultMaxCachedEntries      = 10000
 	defaultBadEntryCacheTTL      = 10 * time.Second
 	parsedExpClaim               = "__apigeeParsedExp"
+
+	// rate limits on synchronous verifyApiKey calls: a minimum interval
+	// between fetches per key and a global concurrency cap
+	defaultPerKeyFetchInterval  = 100 * time.Millisecond
+	defaultMaxConcurrentFetches = 100
+	maxTrackedFetches           = 10000
+
+	// cached tokens this close to expiry are refreshed in the background,
+	// so hot keys never expire out of the cache and force a synchronous fetch
+	refreshAheadTime = time.Minute
 )
 
+// errRateLimited is returned when verifyApiKey calls exceed the limits
+var errRateLimited = errors.New("api key verification rate limited")
+
 // keyVerifier encapsulates API key verification logic.
 type keyVerifier interface {
 	Verify(ctx context.Context, apiKey string) (map[string]interface{}, error)
 }
 
+// hashAPIKey provides a stable, non-reversible identifier for an API key.
+// Caches and logs use the hash so raw keys are never retained or printed.
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
 type keyVerifierImpl struct {
 	env        adapter.Env
 	jwtMan     *jwtManager
@@ -61,6 +83,10 @@ type keyVerifierImpl struct {
 	herdBuster singleflight.Group
 	knownBad   cache.ExpiringCache
 	checking   sync.Map
+	key        string // overrides ctx.Key() if set with secret
+	secret     string
+	lastFetch  cache.ExpiringCache // hashed keys fetched within the interval
+	fetchSlots chan struct{}       // global concurrency cap
 }
 
 type keyVerifierOpts struct {
@@ -68,6 +94,8 @@ type keyVerifierOpts struct {
 	CacheEvictionInterval time.Duration
 	MaxCachedEntries      int
 	Client                *http.Client
+	Key                   string
+	Secret                string
 }
 
 func newVerifier(env adapter.Env, jwtMan *jwtManager, opts keyVerifierOpts) keyVerifier {
@@ -87,19 +115,41 @@ func newVerifier(env adapter.Env, jwtMan *jwtManager, opts keyVerifierOpts) keyV
 		now:      time.Now,
 		client:   opts.Client,
 		knownBad: cache.NewLRU(defaultBadEntryCacheTTL, opts.CacheEvictionInterval, 100),
+		key:      opts.Key,
+		secret:   opts.Secret,
+		// entries expire after the fetch interval, so presence alone means
+		// "fetched too recently"; LRU-capped like knownBad so a flood of
+		// random keys can't grow it without bound
+		lastFetch:  cache.NewLRU(defaultPerKeyFetchInterval, opts.CacheEvictionInterval, maxTrackedFetches),
+		fetchSlots: make(chan struct{}, defaultMaxConcurrentFetches),
 	}
 }
 
 func (kv *keyVerifierImpl) fetchToken(ctx context.Context, apiKey string) (map[string]interface{}, error) {
-	if errResp, ok := kv.knownBad.Get(apiKey); ok {
+	hashedKey := hashAPIKey(apiKey)
+	if errResp, ok := kv.knownBad.Get(hashedKey); ok {
 		if kv.env.Logger().DebugEnabled() {
-			kv.env.Logger().Debugf("fetchToken: known bad token: %s", util.Truncate(apiKey, 5))
+			kv.env.Logger().Debugf("fetchToken: known bad token: %s", util.Truncate(hashedKey, 10))
 		}
 		return nil, errResp.(error)
 	}
 
+	// per-key minimum fetch interval
+	if _, ok := kv.lastFetch.Get(hashedKey); ok {
+		return nil, errRateLimited
+	}
+	kv.lastFetch.Set(hashedKey, true)
+
+	// global concurrency cap
+	select {
+	case kv.fetchSlots <- struct{}{}:
+		defer func() { <-kv.fetchSlots }()
+	default:
+		return nil, errRateLimited
+	}
+
 	if kv.env.Logger().DebugEnabled() {
-		kv.env.Logger().Debugf("fetchToken fetching: %s", util.Truncate(apiKey, 5))
+		kv.env.Logger().Debugf("fetchToken fetching: %s", util.Truncate(hashedKey, 10))
 	}
 	verifyRequest := APIKeyRequest{
 		APIKey: apiKey,
@@ -116,13 +166,25 @@ func (kv *keyVerifierImpl) fetchToken(ctx context.Context, apiKey string) (map[s
 		return nil, err
 	}
 
+	// honor the caller's deadline, if it provided one and is still live
+	// (background refreshes fall back to the default client behavior)
+	if rc, ok := ctx.(RequestContexter); ok {
+		if reqCtx := rc.RequestContext(); reqCtx != nil && reqCtx.Err() == nil {
+			req = req.WithContext(reqCtx)
+		}
+	}
+
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.SetBasicAuth(ctx.Key(), ctx.Secret())
+	if kv.key != "" && kv.secret != "" {
+		req.SetBasicAuth(kv.key, kv.secret)
+	} else {
+		req.SetBasicAuth(ctx.Key(), ctx.Secret())
+	}
 
 	resp, err := kv.client.Do(req)
 	if err != nil {
-		kv.knownBad.Set(apiKey, err)
+		kv.knownBad.Set(hashedKey, err)
 		return nil, err
 	}
 	defer resp.Body.Close()
@@ -132,8 +194,8 @@ func (kv *keyVerifierImpl) fetchToken(ctx context.Context, apiKey string) (map[s
 
 	token := apiKeyResp.Token
 	if token == "" { // bad API Key
-		kv.knownBad.Set(apiKey, ErrBadAuth)
-		kv.cache.Remove(apiKey)
+		kv.knownBad.Set(hashedKey, ErrBadAuth)
+		kv.cache.Remove(hashedKey)
 		return nil, ErrBadAuth
 	}
 
@@ -141,20 +203,20 @@ func (kv *keyVerifierImpl) fetchToken(ctx context.Context, apiKey string) (map[s
 	claims, err := kv.jwtMan.parseJWT(ctx, token, false)
 	if err != nil {
 		err = errors.Wrap(err, "parsing jwt")
-		kv.knownBad.Set(apiKey, err)
+		kv.knownBad.Set(hashedKey, err)
 		return nil, err
 	}
 
 	exp, err := parseExp(claims)
 	if err != nil {
 		err = errors.Wrap(err, "bad exp")
-		kv.knownBad.Set(apiKey, err)
+		kv.knownBad.Set(hashedKey, err)
 		return nil, err
 	}
 	claims[parsedExpClaim] = exp
 
-	kv.cache.Set(apiKey, claims)
-	kv.knownBad.Remove(apiKey)
+	kv.cache.Set(hashedKey, claims)
+	kv.knownBad.Remove(hashedKey)
 
 	return claims, nil
 }
@@ -167,7 +229,7 @@ func (kv *keyVerifierImpl) singleFetchToken(ctx context.Context, apiKey string)
 	fetch := func() (interface{}, error) {
 		return kv.fetchToken(ctx, apiKey)
 	}
-	res, err, _ := kv.herdBuster.Do(apiKey, fetch)
+	res, err, _ := kv.herdBuster.Do(hashAPIKey(apiKey), fetch)
 	// if kv.env.Logger().DebugEnabled() {
 	// 	kv.env.Logger().Debugf("singleFetchToken: %s returning res: %#v, err: %#v", apiKey, res, err)
 	// }
@@ -180,17 +242,18 @@ func (kv *keyVerifierImpl) singleFetchToken(ctx context.Context, apiKey string)
 
 // verify returns the list of claims that an API key has.
 func (kv *keyVerifierImpl) Verify(ctx context.Context, apiKey string) (claims map[string]interface{}, err error) {
-	if existing, ok := kv.cache.Get(apiKey); ok {
+	hashedKey := hashAPIKey(apiKey)
+	if existing, ok := kv.cache.Get(hashedKey); ok {
 		claims = existing.(map[string]interface{})
 	}
 
-	// if token is expired, initiate a background refresh
+	// if token is expired or will expire soon, initiate a background refresh
 	if claims != nil {
 		exp := claims[parsedExpClaim].(time.Time)
 		ttl := exp.Sub(kv.now())
-		if ttl <= 0 { // refresh if possible
-			if _, ok := kv.checking.Load(apiKey); !ok { // one refresh per apiKey at a time
-				kv.checking.Store(apiKey, apiKey)
+		if ttl <= refreshAheadTime { // refresh if possible
+			if _, ok := kv.checking.Load(hashedKey); !ok { // one refresh per apiKey at a time
+				kv.checking.Store(hashedKey, hashedKey)
 
 				// make the call with a backoff
 				// will only call once and cancel loop if successful
@@ -205,7 +268,7 @@ func (kv *keyVerifierImpl) Verify(ctx context.Context, apiKey string) (claims ma
 						return err
 					}
 					cancel()
-					kv.checking.Delete(apiKey)
+					kv.checking.Delete(hashedKey)
 					return nil
 				}
 				looper.Start(c, work, time.Minute, func(err error) error {
@@ -332,3 +332,21 @@ func TestVerifyAPIKeyCallFail(t *testing.T) {
 		t.Errorf("error should not be %s", err.Error())
 	}
 }
+
+func TestHashAPIKey(t *testing.T) {
+	h1 := hashAPIKey("key1")
+	h2 := hashAPIKey("key2")
+
+	if h1 == "key1" {
+		t.Errorf("hash should not be the raw key")
+	}
+	if h1 != hashAPIKey("key1") {
+		t.Errorf("hash should be stable")
+	}
+	if h1 == h2 {
+		t.Errorf("different keys should hash differently")
+	}
+	if len(h1) != 64 {
+		t.Errorf("want 64 hex chars, got %d", len(h1))
+	}
+}
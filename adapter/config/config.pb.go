@@ -44,6 +44,7 @@
 package config
 
 import (
+	encoding_binary "encoding/binary"
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
@@ -107,6 +108,56 @@ type Params struct {
 	Analytics *ParamsAnalyticsOptions `protobuf:"bytes,16,opt,name=analytics,proto3" json:"analytics,omitempty"`
 	// Options specific to to auth handling.
 	Auth *ParamsAuthOptions `protobuf:"bytes,17,opt,name=auth,proto3" json:"auth,omitempty"`
+	// The name of an authorization subject property (eg. a request header
+	// attribute) whose value buckets quotas for requests that carry no
+	// application identity, so anonymous traffic can still be rate limited.
+	// Optional. Default: none.
+	QuotaIdentifierAttribute string `protobuf:"bytes,18,opt,name=quota_identifier_attribute,json=quotaIdentifierAttribute,proto3" json:"quota_identifier_attribute,omitempty"`
+	// Authorization mode: "enforce" or "shadow". In "shadow" mode, denials
+	// are logged but all requests are allowed, so authorization can be
+	// canaried without breaking traffic.
+	// Optional. Default: "enforce".
+	AuthorizationMode string `protobuf:"bytes,19,opt,name=authorization_mode,json=authorizationMode,proto3" json:"authorization_mode,omitempty"`
+	// HTTP status semantics for quota-exceeded responses: "429" (resource
+	// exhausted) or "403" (permission denied), for clients that only handle
+	// one of the two.
+	// Optional. Default: "429".
+	QuotaExceededStatus string `protobuf:"bytes,20,opt,name=quota_exceeded_status,json=quotaExceededStatus,proto3" json:"quota_exceeded_status,omitempty"`
+	// Set to true for Apigee X (non-hybrid) organizations. X has no
+	// edgemicro internal proxy; analytics are uploaded through the
+	// org runtime at customer_base and apigee_base is not required.
+	// Optional. Default: false.
+	ApigeeX bool `protobuf:"varint,21,opt,name=apigee_x,json=apigeeX,proto3" json:"apigee_x,omitempty"`
+	// Behavior when a request matches multiple products: "all" applies the
+	// quota of every matching product, "first" only the first matching
+	// product with a quota.
+	// Optional. Default: "all".
+	MultipleProductsMode string `protobuf:"bytes,22,opt,name=multiple_products_mode,json=multipleProductsMode,proto3" json:"multiple_products_mode,omitempty"`
+	// An identifier for this cluster, used to namespace analytics gateway
+	// flow ids so records from multiple clusters reporting into the same
+	// org/env can be deduplicated.
+	// Optional. Default: none.
+	ClusterId string `protobuf:"bytes,23,opt,name=cluster_id,json=clusterId,proto3" json:"cluster_id,omitempty"`
+	// If set, only requests for these namespaces are enforced; others are
+	// allowed without authorization checks.
+	// Optional. Default: all namespaces are enforced.
+	EnabledNamespaces []string `protobuf:"bytes,24,rep,name=enabled_namespaces,json=enabledNamespaces,proto3" json:"enabled_namespaces,omitempty"`
+	// Requests for these namespaces are allowed without authorization
+	// checks, even if listed in enabled_namespaces.
+	// Optional. Default: none.
+	ExcludedNamespaces []string `protobuf:"bytes,25,rep,name=excluded_namespaces,json=excludedNamespaces,proto3" json:"excluded_namespaces,omitempty"`
+	// How long authorization results without quota counting may be served
+	// from cache. The cache is flushed whenever products refresh; "0"
+	// disables it entirely.
+	// Optional. Default: "10s" (10 seconds).
+	AuthorizationCacheTtl *types.Duration `protobuf:"bytes,26,opt,name=authorization_cache_ttl,json=authorizationCacheTtl,proto3" json:"authorization_cache_ttl,omitempty"`
+	// Limits on untrusted request inputs.
+	Limits *ParamsLimitsOptions `protobuf:"bytes,27,opt,name=limits,proto3" json:"limits,omitempty"`
+	// Anchor locally estimated quota windows to calendar boundaries
+	// (start of minute/hour/day/month, UTC) as Edge does, instead of
+	// rolling from the first request.
+	// Optional. Default: false.
+	QuotaCalendarAligned bool `protobuf:"varint,28,opt,name=quota_calendar_aligned,json=quotaCalendarAligned,proto3" json:"quota_calendar_aligned,omitempty"`
 }
 
 func (m *Params) Reset()      { *m = Params{} }
@@ -146,6 +197,12 @@ type ParamsProductOptions struct {
 	// The rate at which the list of products is refreshed from Apigee.
 	// Optional. Default: "2m" (2 minutes).
 	RefreshRate *types.Duration `protobuf:"bytes,1,opt,name=refresh_rate,json=refreshRate,proto3" json:"refresh_rate,omitempty"`
+	// Key used for product refresh requests instead of the top-level key.
+	// Optional. Default: the top-level key.
+	Key string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// Secret used for product refresh requests instead of the top-level secret.
+	// Optional. Default: the top-level secret.
+	Secret string `protobuf:"bytes,3,opt,name=secret,proto3" json:"secret,omitempty"`
 }
 
 func (m *ParamsProductOptions) Reset()      { *m = ParamsProductOptions{} }
@@ -194,6 +251,46 @@ type ParamsAnalyticsOptions struct {
 	// How often spooled analytics are swept and sent to Apigee.
 	// Optional. Default: "2m" (2 minutes).
 	CollectionInterval *types.Duration `protobuf:"bytes,4,opt,name=collection_interval,json=collectionInterval,proto3" json:"collection_interval,omitempty"`
+	// Upload mode for buffered analytics: "auto", "signed", or "direct".
+	// "signed" PUTs files to a signed storage URL obtained from Apigee, "direct" POSTs
+	// gzip content through the Apigee proxy endpoint (for networks that block the storage
+	// domain), and "auto" uses "signed" until it consistently fails, then falls back.
+	// Optional. Default: "auto".
+	UploadMode string `protobuf:"bytes,5,opt,name=upload_mode,json=uploadMode,proto3" json:"upload_mode,omitempty"`
+	// Fraction of records to report, between 0 (exclusive) and 1 (inclusive).
+	// Non-sampled records are dropped before buffering. Use for very high
+	// traffic meshes where complete analytics are not required.
+	// Optional. Default: 1 (report everything).
+	SamplingRate float64 `protobuf:"fixed64,6,opt,name=sampling_rate,json=samplingRate,proto3" json:"sampling_rate,omitempty"`
+	// How old a record may be and still be reported.
+	// Optional. Default: "2160h" (90 days).
+	MaxRecordAge *types.Duration `protobuf:"bytes,7,opt,name=max_record_age,json=maxRecordAge,proto3" json:"max_record_age,omitempty"`
+	// How far in the future a record timestamp may be (eg. for clock drift).
+	// Optional. Default: "0".
+	MaxRecordSkew *types.Duration `protobuf:"bytes,8,opt,name=max_record_skew,json=maxRecordSkew,proto3" json:"max_record_skew,omitempty"`
+	// A local directory to mirror accepted records into as per-tenant
+	// NDJSON files, for debugging and integration testing.
+	// Optional. Default: none.
+	DebugMirrorDir string `protobuf:"bytes,9,opt,name=debug_mirror_dir,json=debugMirrorDir,proto3" json:"debug_mirror_dir,omitempty"`
+	// IANA timezone for upload window paths, for UAP deployments that
+	// expect a specific zone.
+	// Optional. Default: "UTC".
+	UploadTimezone string `protobuf:"bytes,10,opt,name=upload_timezone,json=uploadTimezone,proto3" json:"upload_timezone,omitempty"`
+	// A local file holding a 16, 24 or 32 byte AES key (eg. mounted from
+	// a Kubernetes Secret). When set, buffered analytics are encrypted
+	// at rest with AES-GCM.
+	// Optional. Default: none (no encryption at rest).
+	EncryptionKeyFile string `protobuf:"bytes,11,opt,name=encryption_key_file,json=encryptionKeyFile,proto3" json:"encryption_key_file,omitempty"`
+	// Record fields (by json name) whose PII is dropped before records
+	// are buffered: client_ip, useragent, developer_email, access_token
+	// or request_uri (query string only). Prefix a field with "hash:"
+	// to replace the value with a stable hash instead of dropping it.
+	// Optional. Default: none.
+	ScrubFields []string `protobuf:"bytes,12,rep,name=scrub_fields,json=scrubFields,proto3" json:"scrub_fields,omitempty"`
+	// Additional base URLs receiving a copy of every upload. Each
+	// destination has independent retry state.
+	// Optional. Default: none.
+	MirrorUrls []string `protobuf:"bytes,13,rep,name=mirror_urls,json=mirrorUrls,proto3" json:"mirror_urls,omitempty"`
 }
 
 func (m *ParamsAnalyticsOptions) Reset()      { *m = ParamsAnalyticsOptions{} }
@@ -236,6 +333,48 @@ type ParamsAuthOptions struct {
 	// The name of a JWT claim from which to look for an api_key.
 	// Optional. Default: none.
 	ApiKeyClaim string `protobuf:"bytes,2,opt,name=api_key_claim,json=apiKeyClaim,proto3" json:"api_key_claim,omitempty"`
+	// Scope rules of the form "<path pattern>=<scope>[,<scope>...]".
+	// Requests for paths matching a pattern must present at least one of the
+	// listed scopes in the JWT scopes claim, in addition to normal product
+	// resource matching. Path patterns use API Product resource syntax.
+	// Optional. Default: none.
+	ScopeRules []string `protobuf:"bytes,3,rep,name=scope_rules,json=scopeRules,proto3" json:"scope_rules,omitempty"`
+	// The name of a claim carrying the Istio end-user principal. When the
+	// verified credential has no developer email, the principal (or the
+	// authorization subject user) is recorded as the Apigee developer.
+	// Optional. Default: none.
+	DeveloperPrincipalClaim string `protobuf:"bytes,4,opt,name=developer_principal_claim,json=developerPrincipalClaim,proto3" json:"developer_principal_claim,omitempty"`
+	// Key used for API key verification instead of the top-level key.
+	// Optional. Default: the top-level key.
+	Key string `protobuf:"bytes,5,opt,name=key,proto3" json:"key,omitempty"`
+	// Secret used for API key verification instead of the top-level secret.
+	// Optional. Default: the top-level secret.
+	Secret string `protobuf:"bytes,6,opt,name=secret,proto3" json:"secret,omitempty"`
+	// The nbf/exp leeway allowed when verifying JWTs, for clock drift
+	// between the client, adapter and Apigee.
+	// Optional. Default: "10s" (10 seconds).
+	JwtClockSkew *types.Duration `protobuf:"bytes,7,opt,name=jwt_clock_skew,json=jwtClockSkew,proto3" json:"jwt_clock_skew,omitempty"`
+	// A URL used to exchange external IdP tokens for Apigee JWTs when
+	// other authentication methods do not apply.
+	// Optional. Default: none.
+	TokenExchangeUrl string `protobuf:"bytes,8,opt,name=token_exchange_url,json=tokenExchangeUrl,proto3" json:"token_exchange_url,omitempty"`
+	// A URL used to check Apigee OAuth 2.0 JWT access tokens for
+	// revocation by jti. Enables verification of raw access tokens.
+	// Optional. Default: none.
+	RevocationCheckUrl string `protobuf:"bytes,9,opt,name=revocation_check_url,json=revocationCheckUrl,proto3" json:"revocation_check_url,omitempty"`
+	// A local file to read the JWKS from (eg. mounted from a Kubernetes
+	// Secret on hybrid) instead of fetching it over the network.
+	// Optional. Default: none.
+	JwksFile string `protobuf:"bytes,10,opt,name=jwks_file,json=jwksFile,proto3" json:"jwks_file,omitempty"`
+	// A shared secret additionally accepted for HS256-signed JWTs, for
+	// OPDK installs without an org keypair.
+	// Optional. Default: none.
+	JwtSharedSecret string `protobuf:"bytes,11,opt,name=jwt_shared_secret,json=jwtSharedSecret,proto3" json:"jwt_shared_secret,omitempty"`
+	// Additional API key locations checked (in order) before the default
+	// api_key property. A location is a subject property name, or
+	// "claim:<name>" to read a (possibly nested) claim.
+	// Optional. Default: none.
+	ApiKeyAttributes []string `protobuf:"bytes,12,rep,name=api_key_attributes,json=apiKeyAttributes,proto3" json:"api_key_attributes,omitempty"`
 }
 
 func (m *ParamsAuthOptions) Reset()      { *m = ParamsAuthOptions{} }
@@ -270,11 +409,61 @@ func (m *ParamsAuthOptions) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_ParamsAuthOptions proto.InternalMessageInfo
 
+// Limits on untrusted request inputs. Requests exceeding a limit are
+// rejected and counted in expvar metrics.
+type ParamsLimitsOptions struct {
+	// Maximum size of the json_claims property.
+	// Optional. Default: 65536 (64KB).
+	MaxJsonClaimsBytes int64 `protobuf:"varint,1,opt,name=max_json_claims_bytes,json=maxJsonClaimsBytes,proto3" json:"max_json_claims_bytes,omitempty"`
+	// Maximum size of an API key.
+	// Optional. Default: 8192 (8KB).
+	MaxApiKeyBytes int64 `protobuf:"varint,2,opt,name=max_api_key_bytes,json=maxApiKeyBytes,proto3" json:"max_api_key_bytes,omitempty"`
+	// Maximum number of claims accepted from json_claims.
+	// Optional. Default: 100.
+	MaxClaimsCount int64 `protobuf:"varint,3,opt,name=max_claims_count,json=maxClaimsCount,proto3" json:"max_claims_count,omitempty"`
+	// Maximum instances accepted per report call.
+	// Optional. Default: 1000.
+	MaxInstances int64 `protobuf:"varint,4,opt,name=max_instances,json=maxInstances,proto3" json:"max_instances,omitempty"`
+}
+
+func (m *ParamsLimitsOptions) Reset()      { *m = ParamsLimitsOptions{} }
+func (*ParamsLimitsOptions) ProtoMessage() {}
+func (*ParamsLimitsOptions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a328857fcf72425d, []int{0, 3}
+}
+func (m *ParamsLimitsOptions) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ParamsLimitsOptions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ParamsLimitsOptions.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ParamsLimitsOptions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ParamsLimitsOptions.Merge(m, src)
+}
+func (m *ParamsLimitsOptions) XXX_Size() int {
+	return m.Size()
+}
+func (m *ParamsLimitsOptions) XXX_DiscardUnknown() {
+	xxx_messageInfo_ParamsLimitsOptions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ParamsLimitsOptions proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*Params)(nil), "config.Params")
 	proto.RegisterType((*ParamsProductOptions)(nil), "config.Params.product_options")
 	proto.RegisterType((*ParamsAnalyticsOptions)(nil), "config.Params.analytics_options")
 	proto.RegisterType((*ParamsAuthOptions)(nil), "config.Params.auth_options")
+	proto.RegisterType((*ParamsLimitsOptions)(nil), "config.Params.limits_options")
 }
 
 func init() { proto.RegisterFile("adapter/config/config.proto", fileDescriptor_a328857fcf72425d) }
@@ -441,6 +630,128 @@ func (m *Params) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i += n4
 	}
+	if len(m.QuotaIdentifierAttribute) > 0 {
+		dAtA[i] = 0x92
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.QuotaIdentifierAttribute)))
+		i += copy(dAtA[i:], m.QuotaIdentifierAttribute)
+	}
+	if len(m.AuthorizationMode) > 0 {
+		dAtA[i] = 0x9a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.AuthorizationMode)))
+		i += copy(dAtA[i:], m.AuthorizationMode)
+	}
+	if len(m.QuotaExceededStatus) > 0 {
+		dAtA[i] = 0xa2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.QuotaExceededStatus)))
+		i += copy(dAtA[i:], m.QuotaExceededStatus)
+	}
+	if m.ApigeeX {
+		dAtA[i] = 0xa8
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.ApigeeX {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.MultipleProductsMode) > 0 {
+		dAtA[i] = 0xb2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.MultipleProductsMode)))
+		i += copy(dAtA[i:], m.MultipleProductsMode)
+	}
+	if len(m.ClusterId) > 0 {
+		dAtA[i] = 0xba
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClusterId)))
+		i += copy(dAtA[i:], m.ClusterId)
+	}
+	if len(m.EnabledNamespaces) > 0 {
+		for _, s := range m.EnabledNamespaces {
+			dAtA[i] = 0xc2
+			i++
+			dAtA[i] = 0x1
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.ExcludedNamespaces) > 0 {
+		for _, s := range m.ExcludedNamespaces {
+			dAtA[i] = 0xca
+			i++
+			dAtA[i] = 0x1
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.AuthorizationCacheTtl != nil {
+		dAtA[i] = 0xd2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.AuthorizationCacheTtl.Size()))
+		n11, err := m.AuthorizationCacheTtl.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n11
+	}
+	if m.Limits != nil {
+		dAtA[i] = 0xda
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.Limits.Size()))
+		n12, err := m.Limits.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n12
+	}
+	if m.QuotaCalendarAligned {
+		dAtA[i] = 0xe0
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.QuotaCalendarAligned {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -469,6 +780,18 @@ func (m *ParamsProductOptions) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i += n5
 	}
+	if len(m.Key) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if len(m.Secret) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Secret)))
+		i += copy(dAtA[i:], m.Secret)
+	}
 	return i, nil
 }
 
@@ -517,6 +840,124 @@ func (m *ParamsAnalyticsOptions) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i += n6
 	}
+	if len(m.UploadMode) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.UploadMode)))
+		i += copy(dAtA[i:], m.UploadMode)
+	}
+	if m.SamplingRate != 0 {
+		dAtA[i] = 0x31
+		i++
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.SamplingRate))))
+		i += 8
+	}
+	if m.MaxRecordAge != nil {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxRecordAge.Size()))
+		n8, err := m.MaxRecordAge.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n8
+	}
+	if m.MaxRecordSkew != nil {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxRecordSkew.Size()))
+		n9, err := m.MaxRecordSkew.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n9
+	}
+	if len(m.DebugMirrorDir) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.DebugMirrorDir)))
+		i += copy(dAtA[i:], m.DebugMirrorDir)
+	}
+	if len(m.UploadTimezone) > 0 {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.UploadTimezone)))
+		i += copy(dAtA[i:], m.UploadTimezone)
+	}
+	if len(m.EncryptionKeyFile) > 0 {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.EncryptionKeyFile)))
+		i += copy(dAtA[i:], m.EncryptionKeyFile)
+	}
+	if len(m.ScrubFields) > 0 {
+		for _, s := range m.ScrubFields {
+			dAtA[i] = 0x62
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.MirrorUrls) > 0 {
+		for _, s := range m.MirrorUrls {
+			dAtA[i] = 0x6a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *ParamsLimitsOptions) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ParamsLimitsOptions) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.MaxJsonClaimsBytes != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxJsonClaimsBytes))
+	}
+	if m.MaxApiKeyBytes != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxApiKeyBytes))
+	}
+	if m.MaxClaimsCount != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxClaimsCount))
+	}
+	if m.MaxInstances != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.MaxInstances))
+	}
 	return i, nil
 }
 
@@ -551,6 +992,88 @@ func (m *ParamsAuthOptions) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintConfig(dAtA, i, uint64(len(m.ApiKeyClaim)))
 		i += copy(dAtA[i:], m.ApiKeyClaim)
 	}
+	if len(m.ScopeRules) > 0 {
+		for _, s := range m.ScopeRules {
+			dAtA[i] = 0x1a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.DeveloperPrincipalClaim) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.DeveloperPrincipalClaim)))
+		i += copy(dAtA[i:], m.DeveloperPrincipalClaim)
+	}
+	if len(m.Key) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if len(m.Secret) > 0 {
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.Secret)))
+		i += copy(dAtA[i:], m.Secret)
+	}
+	if m.JwtClockSkew != nil {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.JwtClockSkew.Size()))
+		n10, err := m.JwtClockSkew.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n10
+	}
+	if len(m.TokenExchangeUrl) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.TokenExchangeUrl)))
+		i += copy(dAtA[i:], m.TokenExchangeUrl)
+	}
+	if len(m.RevocationCheckUrl) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.RevocationCheckUrl)))
+		i += copy(dAtA[i:], m.RevocationCheckUrl)
+	}
+	if len(m.JwksFile) > 0 {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.JwksFile)))
+		i += copy(dAtA[i:], m.JwksFile)
+	}
+	if len(m.JwtSharedSecret) > 0 {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.JwtSharedSecret)))
+		i += copy(dAtA[i:], m.JwtSharedSecret)
+	}
+	if len(m.ApiKeyAttributes) > 0 {
+		for _, s := range m.ApiKeyAttributes {
+			dAtA[i] = 0x62
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
 	return i, nil
 }
 
@@ -620,6 +1143,52 @@ func (m *Params) Size() (n int) {
 		l = m.Auth.Size()
 		n += 2 + l + sovConfig(uint64(l))
 	}
+	l = len(m.QuotaIdentifierAttribute)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.AuthorizationMode)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.QuotaExceededStatus)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.ApigeeX {
+		n += 3
+	}
+	l = len(m.MultipleProductsMode)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ClusterId)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if len(m.EnabledNamespaces) > 0 {
+		for _, s := range m.EnabledNamespaces {
+			l = len(s)
+			n += 2 + l + sovConfig(uint64(l))
+		}
+	}
+	if len(m.ExcludedNamespaces) > 0 {
+		for _, s := range m.ExcludedNamespaces {
+			l = len(s)
+			n += 2 + l + sovConfig(uint64(l))
+		}
+	}
+	if m.AuthorizationCacheTtl != nil {
+		l = m.AuthorizationCacheTtl.Size()
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.Limits != nil {
+		l = m.Limits.Size()
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.QuotaCalendarAligned {
+		n += 3
+	}
 	return n
 }
 
@@ -633,6 +1202,14 @@ func (m *ParamsProductOptions) Size() (n int) {
 		l = m.RefreshRate.Size()
 		n += 1 + l + sovConfig(uint64(l))
 	}
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.Secret)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
 	return n
 }
 
@@ -655,22 +1232,126 @@ func (m *ParamsAnalyticsOptions) Size() (n int) {
 		l = m.CollectionInterval.Size()
 		n += 1 + l + sovConfig(uint64(l))
 	}
+	l = len(m.UploadMode)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.SamplingRate != 0 {
+		n += 9
+	}
+	if m.MaxRecordAge != nil {
+		l = m.MaxRecordAge.Size()
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.MaxRecordSkew != nil {
+		l = m.MaxRecordSkew.Size()
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.DebugMirrorDir)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.UploadTimezone)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.EncryptionKeyFile)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if len(m.ScrubFields) > 0 {
+		for _, s := range m.ScrubFields {
+			l = len(s)
+			n += 1 + l + sovConfig(uint64(l))
+		}
+	}
+	if len(m.MirrorUrls) > 0 {
+		for _, s := range m.MirrorUrls {
+			l = len(s)
+			n += 1 + l + sovConfig(uint64(l))
+		}
+	}
 	return n
 }
 
-func (m *ParamsAuthOptions) Size() (n int) {
+func (m *ParamsLimitsOptions) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	if m.ApiKeyCacheDuration != nil {
-		l = m.ApiKeyCacheDuration.Size()
-		n += 1 + l + sovConfig(uint64(l))
+	if m.MaxJsonClaimsBytes != 0 {
+		n += 1 + sovConfig(uint64(m.MaxJsonClaimsBytes))
 	}
-	l = len(m.ApiKeyClaim)
-	if l > 0 {
-		n += 1 + l + sovConfig(uint64(l))
+	if m.MaxApiKeyBytes != 0 {
+		n += 1 + sovConfig(uint64(m.MaxApiKeyBytes))
+	}
+	if m.MaxClaimsCount != 0 {
+		n += 1 + sovConfig(uint64(m.MaxClaimsCount))
+	}
+	if m.MaxInstances != 0 {
+		n += 1 + sovConfig(uint64(m.MaxInstances))
+	}
+	return n
+}
+
+func (m *ParamsAuthOptions) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.ApiKeyCacheDuration != nil {
+		l = m.ApiKeyCacheDuration.Size()
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.ApiKeyClaim)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if len(m.ScopeRules) > 0 {
+		for _, s := range m.ScopeRules {
+			l = len(s)
+			n += 1 + l + sovConfig(uint64(l))
+		}
+	}
+	l = len(m.DeveloperPrincipalClaim)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.Secret)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.JwtClockSkew != nil {
+		l = m.JwtClockSkew.Size()
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.TokenExchangeUrl)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.RevocationCheckUrl)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.JwksFile)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.JwtSharedSecret)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if len(m.ApiKeyAttributes) > 0 {
+		for _, s := range m.ApiKeyAttributes {
+			l = len(s)
+			n += 1 + l + sovConfig(uint64(l))
+		}
 	}
 	return n
 }
@@ -706,6 +1387,17 @@ func (this *Params) String() string {
 		`Products:` + strings.Replace(fmt.Sprintf("%v", this.Products), "ParamsProductOptions", "ParamsProductOptions", 1) + `,`,
 		`Analytics:` + strings.Replace(fmt.Sprintf("%v", this.Analytics), "ParamsAnalyticsOptions", "ParamsAnalyticsOptions", 1) + `,`,
 		`Auth:` + strings.Replace(fmt.Sprintf("%v", this.Auth), "ParamsAuthOptions", "ParamsAuthOptions", 1) + `,`,
+		`QuotaIdentifierAttribute:` + fmt.Sprintf("%v", this.QuotaIdentifierAttribute) + `,`,
+		`AuthorizationMode:` + fmt.Sprintf("%v", this.AuthorizationMode) + `,`,
+		`QuotaExceededStatus:` + fmt.Sprintf("%v", this.QuotaExceededStatus) + `,`,
+		`ApigeeX:` + fmt.Sprintf("%v", this.ApigeeX) + `,`,
+		`MultipleProductsMode:` + fmt.Sprintf("%v", this.MultipleProductsMode) + `,`,
+		`ClusterId:` + fmt.Sprintf("%v", this.ClusterId) + `,`,
+		`EnabledNamespaces:` + fmt.Sprintf("%v", this.EnabledNamespaces) + `,`,
+		`ExcludedNamespaces:` + fmt.Sprintf("%v", this.ExcludedNamespaces) + `,`,
+		`AuthorizationCacheTtl:` + strings.Replace(fmt.Sprintf("%v", this.AuthorizationCacheTtl), "Duration", "types.Duration", 1) + `,`,
+		`Limits:` + strings.Replace(fmt.Sprintf("%v", this.Limits), "ParamsLimitsOptions", "ParamsLimitsOptions", 1) + `,`,
+		`QuotaCalendarAligned:` + fmt.Sprintf("%v", this.QuotaCalendarAligned) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -716,6 +1408,8 @@ func (this *ParamsProductOptions) String() string {
 	}
 	s := strings.Join([]string{`&ParamsProductOptions{`,
 		`RefreshRate:` + strings.Replace(fmt.Sprintf("%v", this.RefreshRate), "Duration", "types.Duration", 1) + `,`,
+		`Key:` + fmt.Sprintf("%v", this.Key) + `,`,
+		`Secret:` + fmt.Sprintf("%v", this.Secret) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -729,6 +1423,28 @@ func (this *ParamsAnalyticsOptions) String() string {
 		`FileLimit:` + fmt.Sprintf("%v", this.FileLimit) + `,`,
 		`SendChannelSize:` + fmt.Sprintf("%v", this.SendChannelSize) + `,`,
 		`CollectionInterval:` + strings.Replace(fmt.Sprintf("%v", this.CollectionInterval), "Duration", "types.Duration", 1) + `,`,
+		`UploadMode:` + fmt.Sprintf("%v", this.UploadMode) + `,`,
+		`SamplingRate:` + fmt.Sprintf("%v", this.SamplingRate) + `,`,
+		`MaxRecordAge:` + strings.Replace(fmt.Sprintf("%v", this.MaxRecordAge), "Duration", "types.Duration", 1) + `,`,
+		`MaxRecordSkew:` + strings.Replace(fmt.Sprintf("%v", this.MaxRecordSkew), "Duration", "types.Duration", 1) + `,`,
+		`DebugMirrorDir:` + fmt.Sprintf("%v", this.DebugMirrorDir) + `,`,
+		`UploadTimezone:` + fmt.Sprintf("%v", this.UploadTimezone) + `,`,
+		`EncryptionKeyFile:` + fmt.Sprintf("%v", this.EncryptionKeyFile) + `,`,
+		`ScrubFields:` + fmt.Sprintf("%v", this.ScrubFields) + `,`,
+		`MirrorUrls:` + fmt.Sprintf("%v", this.MirrorUrls) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ParamsLimitsOptions) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ParamsLimitsOptions{`,
+		`MaxJsonClaimsBytes:` + fmt.Sprintf("%v", this.MaxJsonClaimsBytes) + `,`,
+		`MaxApiKeyBytes:` + fmt.Sprintf("%v", this.MaxApiKeyBytes) + `,`,
+		`MaxClaimsCount:` + fmt.Sprintf("%v", this.MaxClaimsCount) + `,`,
+		`MaxInstances:` + fmt.Sprintf("%v", this.MaxInstances) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -740,6 +1456,16 @@ func (this *ParamsAuthOptions) String() string {
 	s := strings.Join([]string{`&ParamsAuthOptions{`,
 		`ApiKeyCacheDuration:` + strings.Replace(fmt.Sprintf("%v", this.ApiKeyCacheDuration), "Duration", "types.Duration", 1) + `,`,
 		`ApiKeyClaim:` + fmt.Sprintf("%v", this.ApiKeyClaim) + `,`,
+		`ScopeRules:` + fmt.Sprintf("%v", this.ScopeRules) + `,`,
+		`DeveloperPrincipalClaim:` + fmt.Sprintf("%v", this.DeveloperPrincipalClaim) + `,`,
+		`Key:` + fmt.Sprintf("%v", this.Key) + `,`,
+		`Secret:` + fmt.Sprintf("%v", this.Secret) + `,`,
+		`JwtClockSkew:` + strings.Replace(fmt.Sprintf("%v", this.JwtClockSkew), "Duration", "types.Duration", 1) + `,`,
+		`TokenExchangeUrl:` + fmt.Sprintf("%v", this.TokenExchangeUrl) + `,`,
+		`RevocationCheckUrl:` + fmt.Sprintf("%v", this.RevocationCheckUrl) + `,`,
+		`JwksFile:` + fmt.Sprintf("%v", this.JwksFile) + `,`,
+		`JwtSharedSecret:` + fmt.Sprintf("%v", this.JwtSharedSecret) + `,`,
+		`ApiKeyAttributes:` + fmt.Sprintf("%v", this.ApiKeyAttributes) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1201,64 +1927,11 @@ func (m *Params) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipConfig(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthConfig
-			}
-			if (iNdEx + skippy) < 0 {
-				return ErrInvalidLengthConfig
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ParamsProductOptions) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowConfig
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: product_options: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: product_options: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 18:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RefreshRate", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaIdentifierAttribute", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowConfig
@@ -1268,86 +1941,61 @@ func (m *ParamsProductOptions) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthConfig
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthConfig
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.RefreshRate == nil {
-				m.RefreshRate = &types.Duration{}
-			}
-			if err := m.RefreshRate.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.QuotaIdentifierAttribute = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipConfig(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AuthorizationMode", wireType)
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthConfig
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if (iNdEx + skippy) < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthConfig
 			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowConfig
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
 			}
-			if iNdEx >= l {
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: analytics_options: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: analytics_options: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LegacyEndpoint", wireType)
+			m.AuthorizationMode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaExceededStatus", wireType)
 			}
-			var v int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowConfig
@@ -1357,17 +2005,29 @@ func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.LegacyEndpoint = bool(v != 0)
-		case 2:
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QuotaExceededStatus = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 21:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FileLimit", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ApigeeX", wireType)
 			}
-			m.FileLimit = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowConfig
@@ -1377,16 +2037,17 @@ func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.FileLimit |= int64(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SendChannelSize", wireType)
+			m.ApigeeX = bool(v != 0)
+		case 22:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MultipleProductsMode", wireType)
 			}
-			m.SendChannelSize = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowConfig
@@ -1396,16 +2057,29 @@ func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.SendChannelSize |= int64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MultipleProductsMode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 23:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CollectionInterval", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ClusterId", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowConfig
@@ -1415,28 +2089,180 @@ func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthConfig
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthConfig
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.CollectionInterval == nil {
-				m.CollectionInterval = &types.Duration{}
-			}
-			if err := m.CollectionInterval.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.ClusterId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 24:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EnabledNamespaces", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EnabledNamespaces = append(m.EnabledNamespaces, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 25:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExcludedNamespaces", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExcludedNamespaces = append(m.ExcludedNamespaces, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 26:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AuthorizationCacheTtl", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.AuthorizationCacheTtl == nil {
+				m.AuthorizationCacheTtl = &types.Duration{}
+			}
+			if err := m.AuthorizationCacheTtl.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 27:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limits", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Limits == nil {
+				m.Limits = &ParamsLimitsOptions{}
+			}
+			if err := m.Limits.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
+		case 28:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaCalendarAligned", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.QuotaCalendarAligned = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipConfig(dAtA[iNdEx:])
@@ -1461,7 +2287,7 @@ func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
+func (m *ParamsProductOptions) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1484,15 +2310,15 @@ func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: auth_options: wiretype end group for non-group")
+			return fmt.Errorf("proto: product_options: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: auth_options: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: product_options: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ApiKeyCacheDuration", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RefreshRate", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1519,16 +2345,16 @@ func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ApiKeyCacheDuration == nil {
-				m.ApiKeyCacheDuration = &types.Duration{}
+			if m.RefreshRate == nil {
+				m.RefreshRate = &types.Duration{}
 			}
-			if err := m.ApiKeyCacheDuration.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.RefreshRate.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ApiKeyClaim", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -1556,7 +2382,39 @@ func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ApiKeyClaim = string(dAtA[iNdEx:postIndex])
+			m.Key = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Secret", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Secret = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1582,6 +2440,1002 @@ func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ParamsAnalyticsOptions) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowConfig
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: analytics_options: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: analytics_options: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LegacyEndpoint", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.LegacyEndpoint = bool(v != 0)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FileLimit", wireType)
+			}
+			m.FileLimit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FileLimit |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SendChannelSize", wireType)
+			}
+			m.SendChannelSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SendChannelSize |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CollectionInterval", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CollectionInterval == nil {
+				m.CollectionInterval = &types.Duration{}
+			}
+			if err := m.CollectionInterval.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UploadMode", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UploadMode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SamplingRate", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.SamplingRate = float64(math.Float64frombits(v))
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxRecordAge", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MaxRecordAge == nil {
+				m.MaxRecordAge = &types.Duration{}
+			}
+			if err := m.MaxRecordAge.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxRecordSkew", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MaxRecordSkew == nil {
+				m.MaxRecordSkew = &types.Duration{}
+			}
+			if err := m.MaxRecordSkew.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DebugMirrorDir", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DebugMirrorDir = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UploadTimezone", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UploadTimezone = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EncryptionKeyFile", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EncryptionKeyFile = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ScrubFields", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ScrubFields = append(m.ScrubFields, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MirrorUrls", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MirrorUrls = append(m.MirrorUrls, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipConfig(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ParamsAuthOptions) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowConfig
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: auth_options: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: auth_options: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiKeyCacheDuration", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ApiKeyCacheDuration == nil {
+				m.ApiKeyCacheDuration = &types.Duration{}
+			}
+			if err := m.ApiKeyCacheDuration.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiKeyClaim", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ApiKeyClaim = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ScopeRules", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ScopeRules = append(m.ScopeRules, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DeveloperPrincipalClaim", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DeveloperPrincipalClaim = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Secret", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Secret = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field JwtClockSkew", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.JwtClockSkew == nil {
+				m.JwtClockSkew = &types.Duration{}
+			}
+			if err := m.JwtClockSkew.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TokenExchangeUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TokenExchangeUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RevocationCheckUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RevocationCheckUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field JwksFile", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.JwksFile = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field JwtSharedSecret", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.JwtSharedSecret = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiKeyAttributes", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ApiKeyAttributes = append(m.ApiKeyAttributes, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipConfig(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ParamsLimitsOptions) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowConfig
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: limits_options: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: limits_options: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxJsonClaimsBytes", wireType)
+			}
+			m.MaxJsonClaimsBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxJsonClaimsBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxApiKeyBytes", wireType)
+			}
+			m.MaxApiKeyBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxApiKeyBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxClaimsCount", wireType)
+			}
+			m.MaxClaimsCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxClaimsCount |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxInstances", wireType)
+			}
+			m.MaxInstances = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxInstances |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipConfig(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthConfig
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipConfig(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
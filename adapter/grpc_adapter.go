@@ -25,10 +25,14 @@ import (
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/config"
+	"github.com/apigee/istio-mixer-adapter/adapter/quota"
 	"github.com/apigee/istio-mixer-adapter/template/analytics"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	hproto "google.golang.org/grpc/health/grpc_health_v1"
+	grpcstatus "google.golang.org/grpc/status"
 	model "istio.io/api/mixer/adapter/model/v1beta1"
 	policy "istio.io/api/policy/v1beta1"
 	"istio.io/istio/mixer/pkg/adapter"
@@ -39,6 +43,13 @@ import (
 
 const workerPoolSize = 10
 
+// per-tenant resource quotas: bound the number of tenant handlers (each owns
+// buffers, caches and daemons) and the in-flight calls per tenant
+const (
+	maxTenants           = 100
+	perTenantConcurrency = 50
+)
+
 type (
 	// Server is the gRPC server instance
 	Server interface {
@@ -62,11 +73,27 @@ type (
 
 	// ApigeeHandler handles a single tenant (org/env)
 	ApigeeHandler struct {
-		env     adapter.Env
-		handler adapter.Handler
+		env       adapter.Env
+		handler   adapter.Handler
+		semaphore chan struct{} // bounds in-flight calls for this tenant
 	}
 )
 
+// acquire reserves an in-flight call slot, failing fast when the tenant is
+// at its concurrency limit
+func (h *ApigeeHandler) acquire() error {
+	select {
+	case h.semaphore <- struct{}{}:
+		return nil
+	default:
+		return grpcstatus.Error(codes.ResourceExhausted, "tenant concurrency limit reached")
+	}
+}
+
+func (h *ApigeeHandler) release() {
+	<-h.semaphore
+}
+
 // Ensure required interfaces are implemented.
 var _ authorization.HandleAuthorizationServiceServer = &GRPCAdapter{}
 var _ analytics.HandleAnalyticsServiceServer = &GRPCAdapter{}
@@ -79,6 +106,10 @@ func (g *GRPCAdapter) HandleAuthorization(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
+	if err := h.acquire(); err != nil {
+		return nil, err
+	}
+	defer h.release()
 
 	cr, err := h.HandleAuthorization(ctx, r.Instance)
 	if err != nil {
@@ -100,6 +131,10 @@ func (g *GRPCAdapter) HandleAnalytics(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
+	if err := h.acquire(); err != nil {
+		return nil, err
+	}
+	defer h.release()
 
 	err = h.HandleAnalytics(ctx, r.Instances)
 	return &model.ReportResult{}, err
@@ -110,7 +145,11 @@ func (g *GRPCAdapter) getHandler(rawConfig []byte) (*ApigeeHandler, error) {
 
 	cfg := *g.info.DefaultConfig.(*config.Params)
 	if err := cfg.Unmarshal(rawConfig); err != nil {
-		return nil, err
+		// a config this adapter can't parse usually means a version mismatch
+		// between the operator config and the deployed adapter
+		return nil, grpcstatus.Errorf(codes.InvalidArgument,
+			"can't unmarshal adapter config: %v (adapter %s supports templates %v; check that the operator config matches the deployed adapter version)",
+			err, versionOrDev(BuildInfo.Version), g.info.SupportedTemplates)
 	}
 
 	g.handlersLock.RLock()
@@ -133,12 +172,17 @@ func (g *GRPCAdapter) getHandler(rawConfig []byte) (*ApigeeHandler, error) {
 		return apigeeHandler, nil
 	}
 
+	if len(g.handlers) >= maxTenants {
+		return nil, grpcstatus.Errorf(codes.ResourceExhausted, "tenant limit (%d) reached", maxTenants)
+	}
+
 	// create new handler
 	goroutinePool := pool.NewGoroutinePool(workerPoolSize, false)
 	goroutinePool.AddWorkers(workerPoolSize)
 	env := rtHandler.NewEnv(0, tenant, goroutinePool)
 	apigeeHandler = &ApigeeHandler{
-		env: env,
+		env:       env,
+		semaphore: make(chan struct{}, perTenantConcurrency),
 	}
 
 	ctx := context.Background()
@@ -162,6 +206,50 @@ func (g *GRPCAdapter) getHandler(rawConfig []byte) (*ApigeeHandler, error) {
 	return apigeeHandler, nil
 }
 
+// VerifyCapabilities fails if any of the expected template names is not
+// supported by this adapter build, so deployments can fail fast on a
+// CLI/proxy/adapter version mismatch instead of erroring per-request.
+func (g *GRPCAdapter) VerifyCapabilities(expectedTemplates []string) error {
+	supported := map[string]bool{}
+	for _, t := range g.info.SupportedTemplates {
+		supported[t] = true
+	}
+	var missing []string
+	for _, t := range expectedTemplates {
+		if !supported[t] {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("adapter %s does not support expected template(s) %v (supported: %v); upgrade the adapter or adjust the operator config",
+			versionOrDev(BuildInfo.Version), missing, g.info.SupportedTemplates)
+	}
+	return nil
+}
+
+// RegisterRLS registers the Envoy RateLimitService, backed by the tenant's
+// quota manager, on the adapter's gRPC server. Must be called before Run.
+func (g *GRPCAdapter) RegisterRLS(tenant *ApigeeHandler) error {
+	h, ok := tenant.handler.(*handler)
+	if !ok || h.quotaMan == nil {
+		return fmt.Errorf("tenant has no quota manager")
+	}
+	rls.RegisterRateLimitServiceServer(g.server, &quota.RLSService{Manager: h.quotaMan})
+	return nil
+}
+
+// PrewarmTenant builds and registers the tenant handler for cfg at startup,
+// for standalone (ext_authz style) operation outside Mixer's config
+// pipeline. Configuration errors fail immediately instead of on the first
+// request.
+func (g *GRPCAdapter) PrewarmTenant(cfg *config.Params) (*ApigeeHandler, error) {
+	raw, err := cfg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return g.getHandler(raw)
+}
+
 // Addr returns the listening address of the server
 func (g *GRPCAdapter) Addr() string {
 	return g.listener.Addr().String()
@@ -282,6 +370,12 @@ func (h *ApigeeHandler) HandleAnalytics(ctx context.Context, im []*analytics.Ins
 			TargetSentEndTimestamp:       decodeTimestamp(inst.TargetSentEndTimestamp),
 			TargetReceivedStartTimestamp: decodeTimestamp(inst.TargetReceivedStartTimestamp),
 			TargetReceivedEndTimestamp:   decodeTimestamp(inst.TargetReceivedEndTimestamp),
+			SourceWorkload:               inst.SourceWorkload,
+			SourceWorkloadNamespace:      inst.SourceWorkloadNamespace,
+			DestinationWorkload:          inst.DestinationWorkload,
+			DestinationWorkloadNamespace: inst.DestinationWorkloadNamespace,
+			MeshId:                       inst.MeshId,
+			RequestId:                    inst.RequestId,
 			ApiClaims:                    inst.ApiClaims,
 			ApiKey:                       inst.ApiKey,
 		})
@@ -298,6 +392,18 @@ func (h *ApigeeHandler) HandleAnalytics(ctx context.Context, im []*analytics.Ins
 
 // NewGRPCAdapter creates a new no session server from given args.
 func NewGRPCAdapter(addr string) (*GRPCAdapter, error) {
+	return NewAuthenticatedGRPCAdapter(addr, nil)
+}
+
+// NewAuthenticatedGRPCAdapter creates a new no session server that
+// authenticates incoming Mixer connections according to authOpts.
+func NewAuthenticatedGRPCAdapter(addr string, authOpts *GRPCAuthOptions) (*GRPCAdapter, error) {
+	return NewGRPCAdapterWithLogging(addr, authOpts, 0)
+}
+
+// NewGRPCAdapterWithLogging additionally logs a sampled fraction of requests
+// (logSampleRate in [0,1]; 0 disables request logging).
+func NewGRPCAdapterWithLogging(addr string, authOpts *GRPCAuthOptions, logSampleRate float64) (*GRPCAdapter, error) {
 	s := &GRPCAdapter{
 		info:     GetInfo(),
 		handlers: handlerMap{},
@@ -307,15 +413,40 @@ func NewGRPCAdapter(addr string) (*GRPCAdapter, error) {
 		_ = s.Close()
 		return nil, fmt.Errorf("unable to listen on socket: %v", err)
 	}
+
+	// startup banner: who we are and what we can do
+	fmt.Printf("%s adapter %s (%s %s)\n", s.info.Name, versionOrDev(BuildInfo.Version),
+		BuildInfo.Commit, BuildInfo.Date)
+	fmt.Printf("supported templates: %v\n", s.info.SupportedTemplates)
 	fmt.Printf("listening on :%v\n", s.listener.Addr())
 
-	s.server = grpc.NewServer()
+	var serverOpts []grpc.ServerOption
+	var interceptors []grpc.UnaryServerInterceptor
+	if logSampleRate > 0 {
+		interceptors = append(interceptors, requestLoggingInterceptor(logSampleRate))
+	}
+	if !authOpts.empty() {
+		if serverOpts, err = authOpts.serverOptions(); err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("client auth options: %v", err)
+		}
+		interceptors = append(interceptors, authOpts.unaryInterceptor())
+	}
+	if len(interceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.UnaryInterceptor(chainUnaryInterceptors(interceptors...)))
+	}
+
+	s.server = grpc.NewServer(serverOpts...)
 	authorization.RegisterHandleAuthorizationServiceServer(s.server, s)
 	analytics.RegisterHandleAnalyticsServiceServer(s.server, s)
 
-	// health checks
+	// health checks: the empty service plus one entry per supported template,
+	// so Mixer (or operators) can probe individual capabilities
 	s.health = health.NewServer()
 	s.health.SetServingStatus("", hproto.HealthCheckResponse_SERVING)
+	for _, template := range s.info.SupportedTemplates {
+		s.health.SetServingStatus(template, hproto.HealthCheckResponse_SERVING)
+	}
 	hproto.RegisterHealthServer(s.server, s.health)
 
 	return s, nil
@@ -0,0 +1,157 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// SharedSecretHeader is the metadata key Mixer must send the shared secret in.
+const SharedSecretHeader = "x-apigee-adapter-secret"
+
+// GRPCAuthOptions authenticates incoming Mixer connections to the gRPC adapter
+// so a compromised pod in the cluster can't directly call HandleAuthorization
+// and probe credentials. Both mechanisms are optional and may be combined.
+type GRPCAuthOptions struct {
+	// SharedSecret, if set, must be presented by callers in the
+	// SharedSecretHeader metadata entry.
+	SharedSecret string
+
+	// AllowedSPIFFEIDs, if set, restricts callers to client certificates
+	// bearing one of the listed SPIFFE URI SANs. Requires mTLS (CACertFile).
+	AllowedSPIFFEIDs []string
+
+	// TLSCertFile and TLSKeyFile enable TLS on the adapter's listener.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// CACertFile, if set, requires and verifies client certificates against
+	// the given CA (mTLS).
+	CACertFile string
+}
+
+func (o *GRPCAuthOptions) empty() bool {
+	return o == nil || (o.SharedSecret == "" && len(o.AllowedSPIFFEIDs) == 0 &&
+		o.TLSCertFile == "" && o.CACertFile == "")
+}
+
+// serverOptions creates the transport-level grpc.ServerOptions for these
+// auth options; the unaryInterceptor must be installed separately
+func (o *GRPCAuthOptions) serverOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if len(o.AllowedSPIFFEIDs) > 0 && o.CACertFile == "" {
+		return nil, fmt.Errorf("SPIFFE allowlist requires a client CA cert (mTLS)")
+	}
+
+	if o.TLSCertFile != "" || o.CACertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.TLSCertFile, o.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls key pair: %v", err)
+		}
+		tlsConfig := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+		if o.CACertFile != "" {
+			caCert, err := ioutil.ReadFile(o.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("read ca cert: %v", err)
+			}
+			caCertPool := x509.NewCertPool()
+			if !caCertPool.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("no certs in ca cert file %s", o.CACertFile)
+			}
+			tlsConfig.ClientCAs = caCertPool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	return opts, nil
+}
+
+func (o *GRPCAuthOptions) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := o.authenticate(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authenticate checks the caller's shared secret and SPIFFE identity
+func (o *GRPCAuthOptions) authenticate(ctx context.Context) error {
+	if o.SharedSecret != "" {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return grpcstatus.Error(codes.Unauthenticated, "missing metadata")
+		}
+		secrets := md.Get(SharedSecretHeader)
+		if len(secrets) == 0 {
+			return grpcstatus.Errorf(codes.Unauthenticated, "missing %s", SharedSecretHeader)
+		}
+		if subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(o.SharedSecret)) != 1 {
+			return grpcstatus.Error(codes.PermissionDenied, "bad shared secret")
+		}
+	}
+
+	if len(o.AllowedSPIFFEIDs) > 0 {
+		id, err := peerSPIFFEID(ctx)
+		if err != nil {
+			return err
+		}
+		for _, allowed := range o.AllowedSPIFFEIDs {
+			if id == allowed {
+				return nil
+			}
+		}
+		return grpcstatus.Errorf(codes.PermissionDenied, "identity %s not allowed", id)
+	}
+
+	return nil
+}
+
+// peerSPIFFEID returns the SPIFFE URI SAN of the caller's verified certificate
+func peerSPIFFEID(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", grpcstatus.Error(codes.Unauthenticated, "no peer info")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return "", grpcstatus.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+	leaf := tlsInfo.State.VerifiedChains[0][0]
+	for _, uri := range leaf.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String(), nil
+		}
+	}
+	return "", grpcstatus.Error(codes.Unauthenticated, "no SPIFFE identity in client certificate")
+}
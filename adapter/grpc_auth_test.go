@@ -0,0 +1,84 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestSharedSecretAuth(t *testing.T) {
+	opts := &GRPCAuthOptions{SharedSecret: "secret"}
+
+	cases := []struct {
+		desc string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{
+			"no metadata",
+			context.Background(),
+			codes.Unauthenticated,
+		},
+		{
+			"missing secret",
+			metadata.NewIncomingContext(context.Background(), metadata.MD{}),
+			codes.Unauthenticated,
+		},
+		{
+			"wrong secret",
+			metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs(SharedSecretHeader, "wrong")),
+			codes.PermissionDenied,
+		},
+		{
+			"correct secret",
+			metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs(SharedSecretHeader, "secret")),
+			codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		err := opts.authenticate(c.ctx)
+		if got := grpcstatus.Code(err); got != c.want {
+			t.Errorf("%s: want %v, got %v (%v)", c.desc, c.want, got, err)
+		}
+	}
+}
+
+func TestSPIFFERequiresCA(t *testing.T) {
+	opts := &GRPCAuthOptions{AllowedSPIFFEIDs: []string{"spiffe://cluster.local/ns/istio-system/sa/mixer"}}
+	if _, err := opts.serverOptions(); err == nil {
+		t.Errorf("SPIFFE allowlist without CA cert should fail")
+	}
+}
+
+func TestEmptyAuthOptions(t *testing.T) {
+	var opts *GRPCAuthOptions
+	if !opts.empty() {
+		t.Errorf("nil options should be empty")
+	}
+	if !(&GRPCAuthOptions{}).empty() {
+		t.Errorf("zero options should be empty")
+	}
+	if (&GRPCAuthOptions{SharedSecret: "x"}).empty() {
+		t.Errorf("options with secret should not be empty")
+	}
+}
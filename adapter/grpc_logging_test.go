@@ -0,0 +1,53 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var order []string
+	mark := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}
+	}
+
+	chained := chainUnaryInterceptors(mark("first"), mark("second"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/test"}
+	resp, err := chained(context.Background(), "req", info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			order = append(order, "handler")
+			return "resp", nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "resp" {
+		t.Errorf("want resp, got %v", resp)
+	}
+
+	want := fmt.Sprintf("%v", []string{"first", "second", "handler"})
+	if got := fmt.Sprintf("%v", order); got != want {
+		t.Errorf("want order %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"istio.io/istio/mixer/pkg/adapter"
+)
+
+// A lifecycle tracks started components so they can be shut down in reverse
+// dependency order - both on handler Close and when a later component fails
+// during Build (previously, managers started before a Build failure leaked).
+type lifecycle struct {
+	components []lifecycleComponent
+}
+
+type lifecycleComponent struct {
+	name  string
+	close func()
+}
+
+// add registers a started component. Components are closed in reverse of the
+// order they were added.
+func (l *lifecycle) add(name string, close func()) {
+	l.components = append(l.components, lifecycleComponent{name: name, close: close})
+}
+
+// close shuts down all registered components in reverse order
+func (l *lifecycle) close(log adapter.Logger) {
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		log.Debugf("closing %s", c.name)
+		c.close()
+	}
+	l.components = nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"expvar"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/config"
+)
+
+// requestLimits are the guards applied to untrusted request inputs,
+// configurable via the handler's limits options. Zero values (including a
+// zero-value struct) fall back to the defaults.
+type requestLimits struct {
+	maxJSONClaims int // bytes of json_claims accepted
+	maxAPIKey     int // bytes of api_key accepted
+	maxClaims     int // number of claims accepted from json_claims
+	maxInstances  int // instances accepted per report call
+}
+
+// limit hit counters, published via expvar so any debug endpoint exposes
+// how often requests trip the guards
+var (
+	limitHitsJSONClaims = expvar.NewInt("apigee_limits_json_claims_exceeded")
+	limitHitsAPIKey     = expvar.NewInt("apigee_limits_api_key_exceeded")
+	limitHitsClaims     = expvar.NewInt("apigee_limits_claims_count_exceeded")
+	limitHitsInstances  = expvar.NewInt("apigee_limits_instances_exceeded")
+)
+
+// newRequestLimits captures the configured overrides
+func newRequestLimits(cfg *config.Params) requestLimits {
+	var limits requestLimits
+	if cfg.Limits == nil {
+		return limits
+	}
+	limits.maxJSONClaims = int(cfg.Limits.MaxJsonClaimsBytes)
+	limits.maxAPIKey = int(cfg.Limits.MaxApiKeyBytes)
+	limits.maxClaims = int(cfg.Limits.MaxClaimsCount)
+	limits.maxInstances = int(cfg.Limits.MaxInstances)
+	return limits
+}
+
+func (l requestLimits) jsonClaimsBytes() int {
+	if l.maxJSONClaims > 0 {
+		return l.maxJSONClaims
+	}
+	return maxJSONClaimsLength
+}
+
+func (l requestLimits) apiKeyBytes() int {
+	if l.maxAPIKey > 0 {
+		return l.maxAPIKey
+	}
+	return maxAPIKeyLength
+}
+
+func (l requestLimits) claimsCount() int {
+	if l.maxClaims > 0 {
+		return l.maxClaims
+	}
+	return maxClaimsCount
+}
+
+func (l requestLimits) instances() int {
+	if l.maxInstances > 0 {
+		return l.maxInstances
+	}
+	return maxAnalyticsInstances
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+	"github.com/apigee/istio-mixer-adapter/adapter/auth"
+	logentryT "istio.io/istio/mixer/template/logentry"
+)
+
+// Support for the standard Mixer logentry template: instances are mapped onto
+// analytics records so operators can reuse existing logentry instances for
+// Apigee analytics without defining apigee-analytics instances.
+
+// Ensure required interfaces are implemented.
+var (
+	_ logentryT.HandlerBuilder = &builder{}
+	_ logentryT.Handler        = &handler{}
+)
+
+func (*builder) SetLogEntryTypes(map[string]*logentryT.Type) {}
+
+// HandleLogEntry maps logentry instances to analytics records
+func (h *handler) HandleLogEntry(ctx context.Context, instances []*logentryT.Instance) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	if len(instances) > h.limits.instances() {
+		limitHitsInstances.Add(1)
+		return h.Log().Errorf("HandleLogEntry: %d instances exceeds limit of %d, rejecting",
+			len(instances), h.limits.instances())
+	}
+	h.Log().Debugf("HandleLogEntry: %d instances", len(instances))
+
+	var authContext *auth.Context
+	records := make([]analytics.Record, 0, len(instances))
+
+	for _, inst := range instances {
+		// entries for non-enforced namespaces are not reported
+		namespace := stringVariable(inst.Variables, "destinationNamespace", "destination_workload_namespace")
+		if !h.namespaceEnforced(namespace) {
+			continue
+		}
+
+		ts := timeToUnix(inst.Timestamp)
+		record := analytics.Record{
+			ClientReceivedStartTimestamp: ts,
+			ClientReceivedEndTimestamp:   ts,
+			ClientSentStartTimestamp:     ts,
+			ClientSentEndTimestamp:       ts,
+			APIProxy:                     stringVariable(inst.Variables, "apiProxy", "destinationService"),
+			RequestVerb:                  stringVariable(inst.Variables, "method"),
+			RequestURI:                   stringVariable(inst.Variables, "url"),
+			UserAgent:                    stringVariable(inst.Variables, "userAgent"),
+			ResponseStatusCode:           int(intVariable(inst.Variables, "responseCode")),
+			GatewaySource:                gatewaySource,
+		}
+
+		splits := strings.Split(stringVariable(inst.Variables, "url"), "?")
+		if len(splits) > 0 && splits[0] != "" {
+			record.RequestPath = splits[0]
+		} else {
+			record.RequestPath = "/"
+		}
+
+		// important: This assumes that the Auth is the same for all records!
+		if authContext == nil {
+			apiKey := stringVariable(inst.Variables, append(h.apiKeyAttributes, "apiKey")...)
+			ac, _ := h.authMan.Authenticate(h, apiKey, nil, h.apiKeyClaimKey)
+			// ignore error, take whatever we have
+			authContext = ac
+		}
+
+		records = append(records, record)
+	}
+
+	return h.analyticsMan.SendRecords(authContext, records)
+}
+
+// stringVariable returns the first non-empty string among the named variables
+func stringVariable(variables map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if s, ok := variables[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// intVariable returns the first int64 among the named variables
+func intVariable(variables map[string]interface{}, names ...string) int64 {
+	for _, name := range names {
+		if i, ok := variables[name].(int64); ok {
+			return i
+		}
+	}
+	return 0
+}
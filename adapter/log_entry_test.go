@@ -0,0 +1,44 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"testing"
+)
+
+func TestLogEntryVariables(t *testing.T) {
+	variables := map[string]interface{}{
+		"method":       "GET",
+		"url":          "/path?query=1",
+		"responseCode": int64(200),
+		"notAString":   int64(7),
+	}
+
+	if got := stringVariable(variables, "method"); got != "GET" {
+		t.Errorf("want GET, got %s", got)
+	}
+	if got := stringVariable(variables, "missing", "url"); got != "/path?query=1" {
+		t.Errorf("fallback name should be used, got %s", got)
+	}
+	if got := stringVariable(variables, "notAString"); got != "" {
+		t.Errorf("non-string should give empty, got %s", got)
+	}
+	if got := intVariable(variables, "responseCode"); got != 200 {
+		t.Errorf("want 200, got %d", got)
+	}
+	if got := intVariable(variables, "method"); got != 0 {
+		t.Errorf("non-int should give 0, got %d", got)
+	}
+}
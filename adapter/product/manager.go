@@ -25,6 +25,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/auth"
@@ -54,6 +56,7 @@ func createManager(options Options, log adapter.Logger) *Manager {
 		client:      options.Client,
 		key:         options.Key,
 		secret:      options.Secret,
+		onUpdate:    options.OnUpdate,
 	}
 }
 
@@ -71,6 +74,9 @@ type Manager struct {
 	secret           string
 	productsMux      productsMux
 	cancelPolling    context.CancelFunc
+	etag             string // conditional polling state
+	lastModified     string
+	onUpdate         func() // called after each successful products refresh
 }
 
 func (p *Manager) start(env adapter.Env) {
@@ -133,6 +139,14 @@ func (p *Manager) pollingClosure(apiURL url.URL) func(ctx context.Context) error
 		req.Header.Set("Accept", "application/json")
 		req.SetBasicAuth(p.key, p.secret)
 
+		// revalidate the cached set when the server supports it
+		if p.etag != "" {
+			req.Header.Set("If-None-Match", p.etag)
+		}
+		if p.lastModified != "" {
+			req.Header.Set("If-Modified-Since", p.lastModified)
+		}
+
 		p.log.Debugf("retrieving products from: %s", apiURL.String())
 
 		resp, err := p.client.Do(req)
@@ -141,6 +155,11 @@ func (p *Manager) pollingClosure(apiURL url.URL) func(ctx context.Context) error
 		}
 		defer resp.Body.Close()
 
+		if resp.StatusCode == http.StatusNotModified {
+			p.log.Debugf("products not modified")
+			return nil
+		}
+
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			p.log.Errorf("Unable to read server response: %v", err)
@@ -151,6 +170,9 @@ func (p *Manager) pollingClosure(apiURL url.URL) func(ctx context.Context) error
 			return p.log.Errorf("products request failed (%d): %s", resp.StatusCode, string(body))
 		}
 
+		p.etag = resp.Header.Get("ETag")
+		p.lastModified = resp.Header.Get("Last-Modified")
+
 		var res APIResponse
 		err = json.Unmarshal(body, &res)
 		if err != nil {
@@ -161,6 +183,10 @@ func (p *Manager) pollingClosure(apiURL url.URL) func(ctx context.Context) error
 		pm := p.getProductsMap(ctx, res)
 		p.productsMux.Set(pm)
 
+		if p.onUpdate != nil {
+			p.onUpdate()
+		}
+
 		p.log.Debugf("retrieved %d products, kept %d", len(res.APIProducts), len(pm))
 
 		return nil
@@ -171,51 +197,68 @@ func (p *Manager) getProductsMap(ctx context.Context, res APIResponse) ProductsM
 	pm := ProductsMap{}
 	for _, v := range res.APIProducts {
 		product := v
-		// only save products that actually map to something
+		if ctx.Err() != nil {
+			p.log.Debugf("product polling canceled, exiting")
+			return nil
+		}
+
+		// services bound via the istio-services attribute (Edge / SaaS)
 		for _, attr := range product.Attributes {
-			if ctx.Err() != nil {
-				p.log.Debugf("product polling canceled, exiting")
-				return nil
-			}
 			if attr.Name == ServicesAttr {
-				var err error
 				targets := strings.Split(attr.Value, ",")
 				for _, t := range targets {
 					product.Targets = append(product.Targets, strings.TrimSpace(t))
 				}
+				p.resolveResourceMatchers(&product)
+				break
+			}
+		}
 
-				// server returns empty scopes as array with a single empty string, remove for consistency
-				if len(product.Scopes) == 1 && product.Scopes[0] == "" {
-					product.Scopes = []string{}
-				}
+		// services bound via the operation group (Apigee X / hybrid)
+		p.resolveOperationMatchers(&product)
 
-				// parse limit from server
-				if product.QuotaLimit != "" && product.QuotaInterval != "null" {
-					product.QuotaLimitInt, err = strconv.ParseInt(product.QuotaLimit, 10, 64)
-					if err != nil {
-						p.log.Errorf("unable to parse quota limit: %#v", product)
-					}
-				}
+		// only save products that actually map to something
+		if len(product.Targets) == 0 && len(product.operationConfigs) == 0 {
+			continue
+		}
 
-				// parse interval from server
-				if product.QuotaInterval != "" && product.QuotaInterval != "null" {
-					product.QuotaIntervalInt, err = strconv.ParseInt(product.QuotaInterval, 10, 64)
-					if err != nil {
-						p.log.Errorf("unable to parse quota interval: %#v", product)
-					}
-				}
+		var err error
 
-				// normalize null from server to empty
-				if product.QuotaTimeUnit == "null" {
-					product.QuotaTimeUnit = ""
-				}
+		// server returns empty scopes as array with a single empty string, remove for consistency
+		if len(product.Scopes) == 1 && product.Scopes[0] == "" {
+			product.Scopes = []string{}
+		}
 
-				p.resolveResourceMatchers(&product)
+		// parse limit from server
+		if product.QuotaLimit != "" && product.QuotaInterval != "null" {
+			product.QuotaLimitInt, err = strconv.ParseInt(product.QuotaLimit, 10, 64)
+			if err != nil {
+				p.log.Errorf("unable to parse quota limit: %#v", product)
+			}
+		}
 
-				pm[product.Name] = &product
-				break
+		// parse interval from server
+		if product.QuotaInterval != "" && product.QuotaInterval != "null" {
+			product.QuotaIntervalInt, err = strconv.ParseInt(product.QuotaInterval, 10, 64)
+			if err != nil {
+				p.log.Errorf("unable to parse quota interval: %#v", product)
+			}
+		}
+
+		// normalize null from server to empty
+		if product.QuotaTimeUnit == "null" {
+			product.QuotaTimeUnit = ""
+		}
+
+		// optional product-wide quota cap across all apps
+		if v, ok := product.GetAttribute(ProductQuotaLimitAttr); ok && v != "" {
+			product.ProductQuotaLimitInt, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				p.log.Errorf("unable to parse product quota limit: %#v", product)
 			}
 		}
+
+		pm[product.Name] = &product
 	}
 	return pm
 }
@@ -223,7 +266,7 @@ func (p *Manager) getProductsMap(ctx context.Context, res APIResponse) ProductsM
 // generate matchers for resources (path)
 func (p *Manager) resolveResourceMatchers(product *APIProduct) {
 	for _, resource := range product.Resources {
-		reg, err := makeResourceRegex(resource)
+		reg, err := MakeResourceRegex(resource)
 		if err != nil {
 			p.log.Errorf("unable to create resource matcher: %#v", product)
 			continue
@@ -232,21 +275,75 @@ func (p *Manager) resolveResourceMatchers(product *APIProduct) {
 	}
 }
 
+// generate per-source matchers from the operation group (Apigee X / hybrid).
+// Matchers are scoped to their apiSource so path and method restrictions
+// never leak across sources within a product.
+func (p *Manager) resolveOperationMatchers(product *APIProduct) {
+	if product.OperationGroup == nil {
+		return
+	}
+	for _, oc := range product.OperationGroup.OperationConfigs {
+		if oc.APISource == "" {
+			continue
+		}
+		matcher := operationConfigMatcher{
+			source:  oc.APISource,
+			methods: map[string]bool{},
+		}
+		if len(oc.Operations) == 0 {
+			// a config without operations allows the entire source (only)
+			reg, _ := MakeResourceRegex("/")
+			matcher.regexps = append(matcher.regexps, reg)
+		}
+		for _, op := range oc.Operations {
+			reg, err := MakeResourceRegex(op.Resource)
+			if err != nil {
+				p.log.Errorf("unable to create operation matcher: %#v", product)
+				continue
+			}
+			matcher.regexps = append(matcher.regexps, reg)
+			for _, method := range op.Methods {
+				matcher.methods[strings.ToUpper(method)] = true
+			}
+		}
+		product.operationConfigs = append(product.operationConfigs, matcher)
+	}
+}
+
+// isValidOperation is true if any operation config matches the source, path
+// and method together. A config with no listed methods allows all methods.
+func (p *APIProduct) isValidOperation(api, path, method string) bool {
+	for _, oc := range p.operationConfigs {
+		if oc.source != api {
+			continue
+		}
+		if len(oc.methods) > 0 && !oc.methods[strings.ToUpper(method)] {
+			continue
+		}
+		for _, reg := range oc.regexps {
+			if reg.MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Resolve determines the valid products for a given API.
-func (p *Manager) Resolve(ac *auth.Context, api, path string) []*APIProduct {
-	validProducts, failHints := resolve(ac, p.Products(), api, path)
+func (p *Manager) Resolve(ac *auth.Context, api, path, method string) []*APIProduct {
+	validProducts, failHints := resolve(ac, p.Products(), api, path, method)
 	var selected []string
 	for _, p := range validProducts {
 		selected = append(selected, p.Name)
 	}
 	ac.Log().Debugf(`
-Resolve api: %s, path: %s, scopes: %v
+Resolve api: %s, path: %s, method: %s, scopes: %v
 Selected: %v
-Eliminated: %v`, api, path, ac.Scopes, selected, failHints)
+Eliminated: %v`, api, path, method, ac.Scopes, selected, failHints)
 	return validProducts
 }
 
-func resolve(ac *auth.Context, pMap map[string]*APIProduct, api, path string) (
+func resolve(ac *auth.Context, pMap map[string]*APIProduct, api, path, method string) (
 	result []*APIProduct, failHints []string) {
 
 	for _, name := range ac.APIProducts {
@@ -260,6 +357,18 @@ func resolve(ac *auth.Context, pMap map[string]*APIProduct, api, path string) (
 			failHints = append(failHints, fmt.Sprintf("%s doesn't match scopes: %s", name, ac.Scopes))
 			continue
 		}
+
+		// operation-based bindings (Apigee X / hybrid) authorize per config:
+		// the source, path and method must all match within one config
+		if apiProduct.isValidOperation(api, path, method) {
+			result = append(result, apiProduct)
+			continue
+		}
+		if len(apiProduct.operationConfigs) > 0 && len(apiProduct.Targets) == 0 {
+			failHints = append(failHints, fmt.Sprintf("%s doesn't match operation: %s %s %s", name, method, api, path))
+			continue
+		}
+
 		if !apiProduct.isValidPath(path) {
 			failHints = append(failHints, fmt.Sprintf("%s doesn't match path: %s", name, path))
 			continue
@@ -327,10 +436,68 @@ func (p *APIProduct) GetBoundServices() []string {
 	return nil
 }
 
+// GetAttribute returns the value of a named product attribute
+func (p *APIProduct) GetAttribute(name string) (string, bool) {
+	for _, attr := range p.Attributes {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// MergedAttributes merges the custom attributes of the given products. The
+// first product wins on conflicts and the services attribute is excluded.
+func MergedAttributes(products []*APIProduct) map[string]string {
+	if len(products) == 0 {
+		return nil
+	}
+	merged := map[string]string{}
+	for _, p := range products {
+		for _, attr := range p.Attributes {
+			if attr.Name == ServicesAttr {
+				continue
+			}
+			if _, ok := merged[attr.Name]; !ok {
+				merged[attr.Name] = attr.Value
+			}
+		}
+	}
+	return merged
+}
+
+// compiled resource matchers are cached across product refreshes since
+// resources rarely change between polls; the cache is reset if it grows
+// beyond regexCacheLimit distinct specifications
+var (
+	resourceRegexCache sync.Map
+	resourceRegexCount int64
+)
+
+const regexCacheLimit = 10000
+
+// MakeResourceRegex converts a resource path specification into a matcher:
 // - A single slash by itself matches any path
 // - * is valid anywhere and matches within a segment (between slashes)
 // - ** is valid only at the end and matches anything to EOL
-func makeResourceRegex(resource string) (*regexp.Regexp, error) {
+// Compiled matchers are cached across refreshes.
+func MakeResourceRegex(resource string) (*regexp.Regexp, error) {
+	if cached, ok := resourceRegexCache.Load(resource); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	reg, err := compileResourceRegex(resource)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.AddInt64(&resourceRegexCount, 1) > regexCacheLimit {
+		atomic.StoreInt64(&resourceRegexCount, 1)
+		resourceRegexCache = sync.Map{}
+	}
+	resourceRegexCache.Store(resource, reg)
+	return reg, nil
+}
+
+func compileResourceRegex(resource string) (*regexp.Regexp, error) {
 
 	if resource == "/" {
 		return regexp.Compile(".*")
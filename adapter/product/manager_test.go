@@ -175,12 +175,195 @@ func TestManagerPolling(t *testing.T) {
 	}
 }
 
+func TestManagerConditionalPolling(t *testing.T) {
+
+	var requests, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(APIResponse{
+			APIProducts: []APIProduct{{
+				Name: "Name 1",
+				Attributes: []Attribute{
+					{Name: ServicesAttr, Value: "attr value"},
+				},
+			}},
+		})
+	}))
+	defer ts.Close()
+
+	env := test.NewEnv(t)
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		BaseURL:     serverURL,
+		RefreshRate: 5 * time.Millisecond,
+		Client:      http.DefaultClient,
+	}
+	pp := createManager(opts, env)
+	pp.start(env)
+	defer pp.Close()
+
+	time.Sleep(opts.RefreshRate * 10)
+
+	// the cached set survives 304 responses
+	if len(pp.Products()) != 1 {
+		t.Errorf("num products want: 1, got: %d", len(pp.Products()))
+	}
+	if requests < 2 {
+		t.Errorf("expected more than one poll, got %d", requests)
+	}
+	if notModified == 0 {
+		t.Errorf("expected revalidations after the first poll")
+	}
+}
+
+func TestOperationGroup(t *testing.T) {
+
+	apiProducts := []APIProduct{
+		{
+			Name: "op product",
+			OperationGroup: &OperationGroup{
+				OperationConfigs: []OperationConfig{
+					{
+						APISource: "service.ns.svc.cluster.local",
+						Operations: []Operation{
+							{Resource: "/pets/**", Methods: []string{"GET"}},
+						},
+					},
+					{
+						APISource: "other.ns.svc.cluster.local",
+					},
+				},
+			},
+		},
+		{
+			Name: "unmapped product",
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result = APIResponse{
+			APIProducts: apiProducts,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer ts.Close()
+
+	env := test.NewEnv(t)
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		BaseURL:     serverURL,
+		RefreshRate: time.Hour,
+		Client:      http.DefaultClient,
+	}
+	pp := createManager(opts, env)
+	pp.start(env)
+	defer pp.Close()
+
+	if len(pp.Products()) != 1 {
+		t.Fatalf("num products want: 1, got: %d", len(pp.Products()))
+	}
+
+	got := pp.Products()["op product"]
+	if got == nil {
+		t.Fatal("op product should be mapped")
+	}
+	if len(got.operationConfigs) != 2 {
+		t.Errorf("operation configs want: 2, got: %d", len(got.operationConfigs))
+	}
+
+	// source + path + method must all match within one config
+	if !got.isValidOperation("service.ns.svc.cluster.local", "/pets/1", "GET") {
+		t.Errorf("matching operation should be valid")
+	}
+	if got.isValidOperation("service.ns.svc.cluster.local", "/pets/1", "DELETE") {
+		t.Errorf("method restrictions should be enforced")
+	}
+	if got.isValidOperation("service.ns.svc.cluster.local", "/anything", "GET") {
+		t.Errorf("path restrictions should be enforced per source")
+	}
+
+	// a config without operations allows its own source only
+	if !got.isValidOperation("other.ns.svc.cluster.local", "/anything", "DELETE") {
+		t.Errorf("config without operations should allow its source")
+	}
+	if got.isValidOperation("unrelated.ns.svc.cluster.local", "/anything", "GET") {
+		t.Errorf("allow-all must not leak to unrelated sources")
+	}
+}
+
+func TestMergedAttributes(t *testing.T) {
+	products := []*APIProduct{
+		{
+			Attributes: []Attribute{
+				{Name: ServicesAttr, Value: "service"},
+				{Name: "tier", Value: "gold"},
+				{Name: "owner", Value: "team-a"},
+			},
+		},
+		{
+			Attributes: []Attribute{
+				{Name: "tier", Value: "silver"}, // first product wins
+				{Name: "region", Value: "us"},
+			},
+		},
+	}
+
+	merged := MergedAttributes(products)
+	want := map[string]string{
+		"tier":   "gold",
+		"owner":  "team-a",
+		"region": "us",
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("want %v, got %v", want, merged)
+	}
+	for k, v := range want {
+		if merged[k] != v {
+			t.Errorf("attr %s: want %s, got %s", k, v, merged[k])
+		}
+	}
+
+	if MergedAttributes(nil) != nil {
+		t.Errorf("no products should give nil attributes")
+	}
+}
+
 func TestBadResource(t *testing.T) {
-	if _, e := makeResourceRegex("/**/bad"); e == nil {
+	if _, e := MakeResourceRegex("/**/bad"); e == nil {
 		t.Errorf("expected error for resource: %s", "/**/bad")
 	}
 }
 
+func TestResourceRegexCache(t *testing.T) {
+	first, err := MakeResourceRegex("/cached/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := MakeResourceRegex("/cached/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("compiled matchers should be cached across calls")
+	}
+}
+
 func TestUnreachable(t *testing.T) {
 
 	env := test.NewEnv(t)
@@ -26,6 +26,16 @@ import (
 // ServicesAttr is the name of the Product attribute that lists the Istio services it binds to (comma delim)
 const ServicesAttr = "istio-services"
 
+// ProductQuotaLimitAttr is the name of the optional Product attribute that caps
+// total usage across all apps, nesting the per-app quota within a product-wide limit
+const ProductQuotaLimitAttr = "istio-product-quota-limit"
+
+// ProxyRevisionAttr optionally records the API proxy revision to report in analytics
+const ProxyRevisionAttr = "istio-apiproxy-revision"
+
+// BasepathAttr optionally records the proxy basepath to report in analytics
+const BasepathAttr = "istio-basepath"
+
 // NewManager creates a new product.Manager. Call Close() when done.
 func NewManager(env adapter.Env, options Options) (*Manager, error) {
 	if err := options.validate(); err != nil {
@@ -48,6 +58,9 @@ type Options struct {
 	Key string
 	// Secret is provisioning secret
 	Secret string
+	// OnUpdate, if set, is called after each successful products refresh
+	// (eg. to invalidate caches derived from product state)
+	OnUpdate func()
 }
 
 func (o *Options) validate() error {
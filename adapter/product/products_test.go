@@ -121,7 +121,7 @@ func TestResolve(t *testing.T) {
 		APIProducts: []string{"Name 1", "Name 2", "Name 3", "Invalid"},
 		Scopes:      []string{"scope1", "scope2"},
 	}
-	resolved, failHints := resolve(ac, productsMap, api, path)
+	resolved, failHints := resolve(ac, productsMap, api, path, "GET")
 	if len(resolved) != 3 {
 		t.Errorf("want: 3, got: %v", failHints)
 	}
@@ -130,7 +130,7 @@ func TestResolve(t *testing.T) {
 	}
 
 	ac.Scopes = []string{"scope2"}
-	resolved, failHints = resolve(ac, productsMap, api, path)
+	resolved, failHints = resolve(ac, productsMap, api, path, "GET")
 	if len(resolved) != 2 {
 		t.Errorf("want: 2, got: %d", len(resolved))
 	} else {
@@ -145,7 +145,7 @@ func TestResolve(t *testing.T) {
 	}
 
 	ac.APIProducts = []string{"Name 1"}
-	resolved, failHints = resolve(ac, productsMap, api, path)
+	resolved, failHints = resolve(ac, productsMap, api, path, "GET")
 	if len(resolved) != 0 {
 		t.Errorf("want: 0, got: %d", len(resolved))
 	}
@@ -157,7 +157,7 @@ func TestResolve(t *testing.T) {
 	ac.APIKey = "x"
 	ac.APIProducts = []string{"Name 1", "Name 2", "Name 3"}
 	ac.Scopes = []string{}
-	resolved, failHints = resolve(ac, productsMap, api, path)
+	resolved, failHints = resolve(ac, productsMap, api, path, "GET")
 	if len(resolved) != 3 {
 		t.Errorf("want: 3, got: %d", len(resolved))
 	}
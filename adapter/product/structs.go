@@ -31,17 +31,50 @@ type APIProduct struct {
 	DisplayName  string   `json:"displayName,omitempty"`
 	Environments []string `json:"environments,omitempty"`
 	// LastModifiedAt   int64       `json:"lastModifiedAt,omitempty"`
-	LastModifiedBy   string   `json:"lastModifiedBy,omitempty"`
-	Name             string   `json:"name,omitempty"`
-	QuotaLimit       string   `json:"quota,omitempty"`
-	QuotaInterval    string   `json:"quotaInterval,omitempty"`
-	QuotaTimeUnit    string   `json:"quotaTimeUnit,omitempty"`
-	Resources        []string `json:"apiResources"`
-	Scopes           []string `json:"scopes"`
+	LastModifiedBy   string          `json:"lastModifiedBy,omitempty"`
+	Name             string          `json:"name,omitempty"`
+	QuotaLimit       string          `json:"quota,omitempty"`
+	QuotaInterval    string          `json:"quotaInterval,omitempty"`
+	QuotaTimeUnit    string          `json:"quotaTimeUnit,omitempty"`
+	Resources        []string        `json:"apiResources"`
+	Scopes           []string        `json:"scopes"`
+	OperationGroup   *OperationGroup `json:"operationGroup,omitempty"`
 	Targets          []string
 	QuotaLimitInt    int64
 	QuotaIntervalInt int64
-	resourceRegexps  []*regexp.Regexp
+	// ProductQuotaLimitInt caps total usage across all apps (from the
+	// istio-product-quota-limit attribute)
+	ProductQuotaLimitInt int64
+	resourceRegexps      []*regexp.Regexp
+	operationConfigs     []operationConfigMatcher
+}
+
+// an operationConfigMatcher authorizes source + path + method together,
+// scoped to a single apiSource of an operation group
+type operationConfigMatcher struct {
+	source  string
+	regexps []*regexp.Regexp
+	methods map[string]bool // empty allows all methods
+}
+
+// An OperationGroup holds the operation-based resource model used by API
+// products on Apigee X and hybrid, where services are bound per operation
+// config instead of via the istio-services attribute.
+type OperationGroup struct {
+	OperationConfigType string            `json:"operationConfigType,omitempty"`
+	OperationConfigs    []OperationConfig `json:"operationConfigs,omitempty"`
+}
+
+// An OperationConfig binds a set of operations to an API source (service).
+type OperationConfig struct {
+	APISource  string      `json:"apiSource,omitempty"`
+	Operations []Operation `json:"operations,omitempty"`
+}
+
+// An Operation is a resource path (and optional methods) within an API source.
+type Operation struct {
+	Resource string   `json:"resource,omitempty"`
+	Methods  []string `json:"methods,omitempty"`
 }
 
 // An Attribute is a name-value-pair attribute of an API product.
@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// A breaker is a simple circuit breaker for the quota service client. After
+// threshold consecutive failures the circuit opens for coolDown, during which
+// syncs are skipped instead of hammering an unhealthy quota service. Local
+// buckets continue to accumulate and syncs resume when the circuit closes.
+type breaker struct {
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+	threshold int
+	coolDown  time.Duration
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, coolDown time.Duration, now func() time.Time) *breaker {
+	return &breaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       now,
+	}
+}
+
+// ready is false while the circuit is open
+func (b *breaker) ready() bool {
+	if b == nil { // managers constructed directly have no breaker
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.now().Before(b.openUntil)
+}
+
+// success closes the circuit and resets the failure count
+func (b *breaker) success() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure counts a failure, true if this failure opened the circuit
+func (b *breaker) failure() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.failures = 0
+		b.openUntil = b.now().Add(b.coolDown)
+		return true
+	}
+	return false
+}
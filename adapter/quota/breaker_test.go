@@ -0,0 +1,59 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1521221450, 0)
+	b := newBreaker(3, 30*time.Second, func() time.Time { return now })
+
+	if !b.ready() {
+		t.Errorf("new breaker should be ready")
+	}
+
+	// failures below the threshold leave the circuit closed
+	for i := 0; i < 2; i++ {
+		if b.failure() {
+			t.Errorf("failure %d should not open circuit", i)
+		}
+	}
+	if !b.ready() {
+		t.Errorf("breaker should still be ready")
+	}
+
+	// success resets the count
+	b.success()
+	if b.failure() || b.failure() {
+		t.Errorf("failures after success should not open circuit")
+	}
+
+	// threshold reached: circuit opens for the cool-down
+	if !b.failure() {
+		t.Errorf("threshold failure should open circuit")
+	}
+	if b.ready() {
+		t.Errorf("open breaker should not be ready")
+	}
+
+	// cool-down elapses: circuit closes
+	now = now.Add(31 * time.Second)
+	if !b.ready() {
+		t.Errorf("breaker should be ready after cool-down")
+	}
+}
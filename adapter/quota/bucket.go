@@ -102,6 +102,10 @@ func (b *bucket) compatible(r *Request) bool {
 func (b *bucket) sync() error {
 
 	log := b.manager.log
+	if !b.manager.breaker.ready() {
+		log.Debugf("quota sync skipped, circuit open: %s", b.request.Identifier)
+		return nil
+	}
 	log.Debugf("syncing quota %s", b.request.Identifier)
 
 	b.lock.Lock()
@@ -129,8 +133,12 @@ func (b *bucket) sync() error {
 
 	log.Debugf("sending quota: %s", body)
 
+	syncStart := b.now()
 	resp, err := b.manager.client.Do(req)
 	if err != nil {
+		if b.manager.breaker.failure() {
+			log.Warningf("quota service unhealthy, circuit open for %s", b.manager.breaker.coolDown)
+		}
 		return log.Errorf("do request: %v", err)
 	}
 	defer resp.Body.Close()
@@ -144,12 +152,24 @@ func (b *bucket) sync() error {
 
 	switch resp.StatusCode {
 	case 200:
+		b.manager.breaker.success()
 		var quotaResult Result
 		if err = json.Unmarshal(respBody, &quotaResult); err != nil {
 			return log.Errorf("bad response: %s", string(respBody))
 		}
 
 		log.Debugf("quota synced: %#v", quotaResult)
+
+		// record latency and the delta between expected and server usage
+		var expected int64
+		b.lock.RLock()
+		if b.result != nil {
+			expected = b.result.Used + b.result.Exceeded
+		}
+		expected += r.Weight
+		b.lock.RUnlock()
+		b.manager.metrics.observeSync(b.now().Sub(syncStart), (quotaResult.Used+quotaResult.Exceeded)-expected)
+
 		b.lock.Lock()
 		b.synced = b.now()
 		if b.result != nil && b.result.ExpiryTime != quotaResult.ExpiryTime {
@@ -162,6 +182,9 @@ func (b *bucket) sync() error {
 		return nil
 
 	default:
+		if b.manager.breaker.failure() {
+			log.Warningf("quota service unhealthy, circuit open for %s", b.manager.breaker.coolDown)
+		}
 		return log.Errorf("bad response (%d): %s", resp.StatusCode, string(respBody))
 	}
 }
@@ -182,5 +205,10 @@ func (b *bucket) windowExpired() bool {
 	if b.result != nil {
 		return b.now().After(time.Unix(b.result.ExpiryTime, 0))
 	}
-	return false
+	// no server result yet, estimate locally from the request window
+	exp, err := windowExpiration(b.created, b.request.TimeUnit, b.request.Interval, b.manager.calendarAligned)
+	if err != nil {
+		return false
+	}
+	return b.now().After(exp)
 }
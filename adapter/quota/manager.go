@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apigee/istio-mixer-adapter/adapter/auth"
@@ -34,6 +35,10 @@ const (
 	defaultDeleteAfter    = 10 * time.Minute
 	syncQueueSize         = 100
 	resultCacheBufferSize = 30
+
+	// circuit breaker on the quota service client
+	defaultBreakerThreshold = 5
+	defaultBreakerCoolDown  = 30 * time.Second
 )
 
 // A Manager tracks multiple Apigee quotas
@@ -54,6 +59,38 @@ type Manager struct {
 	syncingBucketsLock sync.Mutex
 	key                string
 	secret             string
+	breaker            *breaker
+	calendarAligned    bool
+	metrics            *syncMetrics
+
+	// lifecycle counters, updated atomically
+	bucketsCreated int64
+	bucketsDeleted int64
+	syncs          int64
+	syncErrors     int64
+}
+
+// Stats is a snapshot of quota manager lifecycle counters.
+type Stats struct {
+	BucketsActive  int64 `json:"buckets_active"`
+	BucketsCreated int64 `json:"buckets_created"`
+	BucketsDeleted int64 `json:"buckets_deleted"`
+	Syncs          int64 `json:"syncs"`
+	SyncErrors     int64 `json:"sync_errors"`
+}
+
+// Stats returns a snapshot of bucket lifecycle and sync counters.
+func (m *Manager) Stats() Stats {
+	m.bucketsLock.RLock()
+	active := int64(len(m.buckets))
+	m.bucketsLock.RUnlock()
+	return Stats{
+		BucketsActive:  active,
+		BucketsCreated: atomic.LoadInt64(&m.bucketsCreated),
+		BucketsDeleted: atomic.LoadInt64(&m.bucketsDeleted),
+		Syncs:          atomic.LoadInt64(&m.syncs),
+		SyncErrors:     atomic.LoadInt64(&m.syncErrors),
+	}
 }
 
 // NewManager constructs and starts a new Manager. Call Close when done.
@@ -62,13 +99,17 @@ func NewManager(env adapter.Env, options Options) (*Manager, error) {
 		return nil, err
 	}
 	m := newManager(options.BaseURL, options.Client, options.Key, options.Secret)
+	m.calendarAligned = options.CalendarAligned
+	if options.Clock != nil {
+		m.now = options.Clock
+	}
 	m.Start(env)
 	return m, nil
 }
 
 // newManager constructs a new Manager
 func newManager(baseURL *url.URL, client *http.Client, key, secret string) *Manager {
-	return &Manager{
+	m := &Manager{
 		close:          make(chan bool),
 		closed:         make(chan bool),
 		client:         client,
@@ -83,6 +124,11 @@ func newManager(baseURL *url.URL, client *http.Client, key, secret string) *Mana
 		key:            key,
 		secret:         secret,
 	}
+	m.breaker = newBreaker(defaultBreakerThreshold, defaultBreakerCoolDown, func() time.Time {
+		return m.now()
+	})
+	m.metrics = newSyncMetrics()
+	return m
 }
 
 // Start starts the manager.
@@ -117,28 +163,70 @@ func (m *Manager) Close() {
 }
 
 func getQuotaID(auth *auth.Context, p *product.APIProduct) string {
-	return fmt.Sprintf("%s-%s", auth.Application, p.Name)
+	return fmt.Sprintf("%s-%s", quotaIdentifier(auth), p.Name)
+}
+
+// quotaIdentifier is normally the application, but anonymous traffic may be
+// bucketed by a configured identifier (eg. a client header value)
+func quotaIdentifier(auth *auth.Context) string {
+	if auth.Application == "" && auth.QuotaIdentifier != "" {
+		return "anon-" + auth.QuotaIdentifier
+	}
+	return auth.Application
 }
 
-// Apply a quota request to the local quota bucket and schedule for sync
+// Apply a quota request to the local quota bucket and schedule for sync.
+// If the product defines a product-wide cap, the per-app quota is nested
+// within it: both buckets are counted and either may report exceeded.
 func (m *Manager) Apply(auth *auth.Context, p *product.APIProduct, args adapter.QuotaArgs) (*Result, error) {
 
 	if result := m.dupCache.Get(args.DeduplicationID); result != nil {
 		return result, nil
 	}
 
-	quotaID := getQuotaID(auth, p)
-
 	req := &Request{
-		Identifier: quotaID,
+		Identifier: getQuotaID(auth, p),
 		Interval:   p.QuotaIntervalInt,
 		Allow:      p.QuotaLimitInt,
 		TimeUnit:   p.QuotaTimeUnit,
+		Weight:     args.QuotaAmount,
+	}
+	result, err := m.applyToBucket(req)
+	if err != nil {
+		return result, err
+	}
+
+	// hierarchical: also count against the product-wide cap, if any
+	if p.ProductQuotaLimitInt > 0 {
+		productReq := &Request{
+			Identifier: "product-" + p.Name,
+			Interval:   p.QuotaIntervalInt,
+			Allow:      p.ProductQuotaLimitInt,
+			TimeUnit:   p.QuotaTimeUnit,
+			Weight:     args.QuotaAmount,
+		}
+		productResult, err := m.applyToBucket(productReq)
+		if err != nil {
+			return result, err
+		}
+		if productResult.Exceeded > 0 && result.Exceeded == 0 {
+			m.log.Debugf("product-wide quota exceeded: %s", p.Name)
+			result.Exceeded = productResult.Exceeded
+		}
 	}
 
-	// a new bucket is created if missing or if product is no longer compatible
-	var result *Result
-	var err error
+	if result != nil && args.DeduplicationID != "" {
+		m.dupCache.Add(args.DeduplicationID, result)
+	}
+
+	return result, err
+}
+
+// applyToBucket applies a request to its local quota bucket, creating the
+// bucket if missing or if the product is no longer compatible
+func (m *Manager) applyToBucket(req *Request) (*Result, error) {
+	quotaID := req.Identifier
+
 	m.bucketsLock.RLock()
 	b, ok := m.buckets[quotaID]
 	m.bucketsLock.RUnlock()
@@ -146,21 +234,84 @@ func (m *Manager) Apply(auth *auth.Context, p *product.APIProduct, args adapter.
 		m.bucketsLock.Lock()
 		b, ok = m.buckets[quotaID]
 		if !ok || !b.compatible(req) {
-			b = newBucket(*req, m)
+			newReq := *req
+			newReq.Weight = 0 // the bucket accumulates weight via apply
+			b = newBucket(newReq, m)
 			m.buckets[quotaID] = b
+			atomic.AddInt64(&m.bucketsCreated, 1)
 			m.log.Debugf("new quota bucket: %s", quotaID)
 		}
 		m.bucketsLock.Unlock()
 	}
 
-	req.Weight = args.QuotaAmount
-	result, err = b.apply(req)
+	return b.apply(req)
+}
 
-	if result != nil && err == nil && args.DeduplicationID != "" {
-		m.dupCache.Add(args.DeduplicationID, result)
+// LatestResult returns a copy of the most recent result for the quota bucket
+// associated with auth and productName, or nil if no such bucket exists. It
+// allows quota state to be attached to analytics records without forcing a
+// quota application.
+func (m *Manager) LatestResult(auth *auth.Context, productName string) *Result {
+	if m == nil {
+		return nil
 	}
+	quotaID := fmt.Sprintf("%s-%s", quotaIdentifier(auth), productName)
 
-	return result, err
+	m.bucketsLock.RLock()
+	b, ok := m.buckets[quotaID]
+	m.bucketsLock.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	if b.result == nil {
+		return nil
+	}
+	result := *b.result
+	return &result
+}
+
+// ForceSync synchronously syncs the bucket with the given quota ID with the
+// server, outside the normal sync schedule. It is a no-op if no such bucket
+// exists. Intended as an admin trigger.
+func (m *Manager) ForceSync(quotaID string) error {
+	m.bucketsLock.RLock()
+	b, ok := m.buckets[quotaID]
+	m.bucketsLock.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	m.syncingBucketsLock.Lock()
+	m.syncingBuckets[b] = struct{}{}
+	m.syncingBucketsLock.Unlock()
+	defer func() {
+		m.syncingBucketsLock.Lock()
+		delete(m.syncingBuckets, b)
+		m.syncingBucketsLock.Unlock()
+	}()
+	return b.sync()
+}
+
+// ForceSyncAll synchronously syncs every active bucket with the server,
+// returning the first error encountered.
+func (m *Manager) ForceSyncAll() error {
+	m.bucketsLock.RLock()
+	ids := make([]string, 0, len(m.buckets))
+	for id := range m.buckets {
+		ids = append(ids, id)
+	}
+	m.bucketsLock.RUnlock()
+
+	var firstErr error
+	for _, id := range ids {
+		if err := m.ForceSync(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 // loop to sync active buckets and deletes old buckets
@@ -187,6 +338,9 @@ func (m *Manager) syncLoop() {
 					delete(m.buckets, id)
 				}
 				m.bucketsLock.Unlock()
+				atomic.AddInt64(&m.bucketsDeleted, int64(len(deleteIDs)))
+				m.log.Infof("pruned %d idle quota buckets (%d total pruned)",
+					len(deleteIDs), atomic.LoadInt64(&m.bucketsDeleted))
 			}
 		case <-m.close:
 			m.log.Debugf("closing quota sync loop")
@@ -206,7 +360,10 @@ func (m *Manager) syncBucketWorker() {
 			if _, ok := m.syncingBuckets[bucket]; !ok {
 				m.syncingBuckets[bucket] = struct{}{}
 				m.syncingBucketsLock.Unlock()
-				bucket.sync()
+				atomic.AddInt64(&m.syncs, 1)
+				if err := bucket.sync(); err != nil {
+					atomic.AddInt64(&m.syncErrors, 1)
+				}
 				m.syncingBucketsLock.Lock()
 				delete(m.syncingBuckets, bucket)
 			}
@@ -229,6 +386,13 @@ type Options struct {
 	Key string
 	// Secret is provisioning secret
 	Secret string
+	// CalendarAligned anchors locally estimated quota windows to calendar
+	// boundaries instead of rolling from the first request
+	CalendarAligned bool
+	// Clock optionally overrides the time source, for testing and simulation.
+	// All window math is done in UTC, so wall-clock DST shifts never change
+	// window lengths.
+	Clock func() time.Time
 }
 
 func (o *Options) validate() error {
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -305,7 +306,7 @@ func TestDisconnected(t *testing.T) {
 	}
 
 	// force sync error
-	err = m.forceSync(getQuotaID(authContext, p))
+	err = m.ForceSync(getQuotaID(authContext, p))
 	if err == nil {
 		t.Fatalf("should have received error: %s", err)
 	}
@@ -316,7 +317,7 @@ func TestDisconnected(t *testing.T) {
 	}
 
 	errC.send = 200
-	m.forceSync(getQuotaID(authContext, p))
+	m.ForceSync(getQuotaID(authContext, p))
 
 	res, err := m.Apply(authContext, p, args)
 	if err != nil {
@@ -385,7 +386,7 @@ func TestWindowExpired(t *testing.T) {
 	}
 
 	res, err := m.Apply(authContext, p, args)
-	m.forceSync(getQuotaID(authContext, p))
+	m.ForceSync(getQuotaID(authContext, p))
 
 	quotaID := fmt.Sprintf("%s-%s", authContext.Application, p.Name)
 	bucket := m.buckets[quotaID]
@@ -457,20 +458,85 @@ func testServer(serverResult *Result, now func() time.Time, errC *errControl) *h
 	}))
 }
 
-// ignores if no matching quota bucket
-func (m *Manager) forceSync(quotaID string) error {
-	m.bucketsLock.RLock()
-	b, ok := m.buckets[quotaID]
-	if !ok {
-		return nil
-	}
-	m.syncingBucketsLock.Lock()
-	m.syncingBuckets[b] = struct{}{}
-	m.syncingBucketsLock.Unlock()
-	defer func() {
-		m.syncingBucketsLock.Lock()
-		delete(m.syncingBuckets, b)
-		m.syncingBucketsLock.Unlock()
-	}()
-	return b.sync()
+func TestHierarchicalQuota(t *testing.T) {
+	m := newManager(&url.URL{}, http.DefaultClient, "key", "secret")
+
+	p := &product.APIProduct{
+		Name:                 "hier",
+		QuotaLimitInt:        10, // generous per-app limit
+		ProductQuotaLimitInt: 2,  // tight product-wide cap
+		QuotaIntervalInt:     1,
+		QuotaTimeUnit:        "minute",
+	}
+	args := adapter.QuotaArgs{QuotaAmount: 1}
+
+	app1 := &auth.Context{Application: "app1"}
+	app2 := &auth.Context{Application: "app2"}
+
+	// two different apps use up the product-wide cap
+	for _, ac := range []*auth.Context{app1, app2} {
+		result, err := m.Apply(ac, p, args)
+		if err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		if result.Exceeded != 0 {
+			t.Errorf("app %s should not be exceeded: %#v", ac.Application, result)
+		}
+	}
+
+	// a third request is within the per-app limit but over the product cap
+	result, err := m.Apply(app1, p, args)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if result.Exceeded == 0 {
+		t.Errorf("product-wide cap should be exceeded: %#v", result)
+	}
+}
+
+func TestStats(t *testing.T) {
+	m := newManager(&url.URL{}, http.DefaultClient, "key", "secret")
+
+	stats := m.Stats()
+	if stats != (Stats{}) {
+		t.Errorf("new manager should have zero stats, got: %#v", stats)
+	}
+
+	m.buckets["app-product"] = newBucket(Request{Identifier: "app-product"}, m)
+	m.bucketsCreated = 1
+
+	stats = m.Stats()
+	if stats.BucketsActive != 1 || stats.BucketsCreated != 1 {
+		t.Errorf("want 1 active/created, got: %#v", stats)
+	}
+}
+
+func TestLatestResult(t *testing.T) {
+	m := newManager(&url.URL{}, http.DefaultClient, "key", "secret")
+	authContext := &auth.Context{Application: "app"}
+
+	var nilM *Manager
+	if nilM.LatestResult(authContext, "product") != nil {
+		t.Errorf("nil manager should give nil result")
+	}
+
+	if m.LatestResult(authContext, "product") != nil {
+		t.Errorf("no bucket should give nil result")
+	}
+
+	b := newBucket(Request{Identifier: "app-product"}, m)
+	m.buckets["app-product"] = b
+	if m.LatestResult(authContext, "product") != nil {
+		t.Errorf("unsynced bucket should give nil result")
+	}
+
+	b.result = &Result{Allowed: 3, Used: 1}
+	got := m.LatestResult(authContext, "product")
+	if got == nil || got.Allowed != 3 || got.Used != 1 {
+		t.Errorf("want result, got: %v", got)
+	}
+	got.Used = 9
+	if b.result.Used != 1 {
+		t.Errorf("returned result should be a copy")
+	}
 }
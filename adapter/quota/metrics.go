@@ -0,0 +1,103 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// histogram bucket boundaries for sync latency (seconds) and for the
+// absolute delta between locally expected and server-reported usage
+var (
+	latencyBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	deltaBounds   = []float64{0, 1, 2, 5, 10, 25, 50, 100, 500, 1000}
+)
+
+// syncMetrics collects histograms of quota sync latency and server result
+// deltas, exposable in Prometheus text format via WriteMetrics.
+type syncMetrics struct {
+	lock    sync.Mutex
+	latency histogram
+	delta   histogram
+}
+
+type histogram struct {
+	bounds []float64
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write renders the histogram in Prometheus text exposition format
+func (h *histogram) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func newSyncMetrics() *syncMetrics {
+	return &syncMetrics{
+		latency: newHistogram(latencyBounds),
+		delta:   newHistogram(deltaBounds),
+	}
+}
+
+// observeSync records one sync round trip and the absolute difference
+// between the locally expected and server-reported usage
+func (s *syncMetrics) observeSync(latency time.Duration, delta int64) {
+	if s == nil {
+		return
+	}
+	if delta < 0 {
+		delta = -delta
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latency.observe(latency.Seconds())
+	s.delta.observe(float64(delta))
+}
+
+// WriteMetrics writes quota sync metrics in Prometheus text format. Embed it
+// in a /metrics handler to expose the histograms for scraping.
+func (m *Manager) WriteMetrics(w io.Writer) {
+	m.metrics.lock.Lock()
+	defer m.metrics.lock.Unlock()
+	m.metrics.latency.write(w, "apigee_quota_sync_latency_seconds")
+	m.metrics.delta.write(w, "apigee_quota_sync_result_delta")
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncMetrics(t *testing.T) {
+	m := newManager(&url.URL{}, http.DefaultClient, "key", "secret")
+
+	m.metrics.observeSync(50*time.Millisecond, 3)
+	m.metrics.observeSync(2*time.Second, -7) // deltas are recorded as absolute
+
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE apigee_quota_sync_latency_seconds histogram",
+		"apigee_quota_sync_latency_seconds_count 2",
+		"# TYPE apigee_quota_sync_result_delta histogram",
+		"apigee_quota_sync_result_delta_sum 10",
+		`apigee_quota_sync_result_delta_bucket{le="+Inf"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q:\n%s", want, out)
+		}
+	}
+
+	// nil metrics must not panic (direct bucket sync in tests)
+	var nilMetrics *syncMetrics
+	nilMetrics.observeSync(time.Second, 1)
+}
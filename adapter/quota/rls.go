@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/ratelimit"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
+)
+
+// descriptor keys understood by the RLS bridge
+const (
+	rlsIdentifierKey = "identifier"
+	rlsAllowKey      = "allow"
+	rlsIntervalKey   = "interval"
+	rlsTimeUnitKey   = "timeUnit"
+)
+
+// RLSService bridges Envoy's Rate Limit Service protocol onto the quota
+// manager, so sidecars configured for RLS can consult Apigee quota buckets
+// directly. Descriptors carry the quota parameters as entries:
+// identifier (required), allow, interval and timeUnit.
+type RLSService struct {
+	Manager *Manager
+}
+
+var _ rls.RateLimitServiceServer = &RLSService{}
+
+// ShouldRateLimit implements the RLS protocol against local quota buckets
+func (s *RLSService) ShouldRateLimit(ctx context.Context,
+	req *rls.RateLimitRequest) (*rls.RateLimitResponse, error) {
+
+	hits := req.HitsAddend
+	if hits == 0 {
+		hits = 1
+	}
+
+	overall := rls.RateLimitResponse_OK
+	statuses := make([]*rls.RateLimitResponse_DescriptorStatus, 0, len(req.Descriptors))
+
+	for _, descriptor := range req.Descriptors {
+		quotaReq, err := requestFromDescriptorEntries(descriptor.Entries)
+		if err != nil {
+			return nil, err
+		}
+		quotaReq.Weight = int64(hits)
+
+		result, err := s.Manager.applyToBucket(quotaReq)
+		if err != nil {
+			return nil, err
+		}
+
+		code := rls.RateLimitResponse_OK
+		remaining := result.Allowed - result.Used
+		if remaining < 0 {
+			remaining = 0 // never wrap a negative into uint32
+		}
+		if result.Exceeded > 0 {
+			code = rls.RateLimitResponse_OVER_LIMIT
+			overall = rls.RateLimitResponse_OVER_LIMIT
+			remaining = 0
+		}
+		statuses = append(statuses, &rls.RateLimitResponse_DescriptorStatus{
+			Code:           code,
+			LimitRemaining: uint32(remaining),
+		})
+	}
+
+	return &rls.RateLimitResponse{
+		OverallCode: overall,
+		Statuses:    statuses,
+	}, nil
+}
+
+// requestFromDescriptorEntries maps RLS descriptor entries to a quota Request
+func requestFromDescriptorEntries(entries []*ratelimit.RateLimitDescriptor_Entry) (*Request, error) {
+	req := &Request{
+		Interval: 1,
+		TimeUnit: timeUnitMinute,
+	}
+	for _, entry := range entries {
+		switch entry.Key {
+		case rlsIdentifierKey:
+			req.Identifier = entry.Value
+		case rlsAllowKey:
+			allow, err := strconv.ParseInt(entry.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad allow value %q: %v", entry.Value, err)
+			}
+			req.Allow = allow
+		case rlsIntervalKey:
+			interval, err := strconv.ParseInt(entry.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad interval value %q: %v", entry.Value, err)
+			}
+			req.Interval = interval
+		case rlsTimeUnitKey:
+			req.TimeUnit = entry.Value
+		}
+	}
+	if req.Identifier == "" {
+		return nil, fmt.Errorf("descriptor requires an %s entry", rlsIdentifierKey)
+	}
+	if req.Allow <= 0 {
+		return nil, fmt.Errorf("descriptor requires a positive %s entry", rlsAllowKey)
+	}
+	return req, nil
+}
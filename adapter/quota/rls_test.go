@@ -0,0 +1,88 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/ratelimit"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
+)
+
+func rlsDescriptor(identifier, allow string) *ratelimit.RateLimitDescriptor {
+	return &ratelimit.RateLimitDescriptor{
+		Entries: []*ratelimit.RateLimitDescriptor_Entry{
+			{Key: rlsIdentifierKey, Value: identifier},
+			{Key: rlsAllowKey, Value: allow},
+			{Key: rlsIntervalKey, Value: "1"},
+			{Key: rlsTimeUnitKey, Value: "minute"},
+		},
+	}
+}
+
+func TestRLSShouldRateLimit(t *testing.T) {
+	m := newManager(&url.URL{}, http.DefaultClient, "key", "secret")
+	service := &RLSService{Manager: m}
+
+	req := &rls.RateLimitRequest{
+		Descriptors: []*ratelimit.RateLimitDescriptor{rlsDescriptor("client-1", "2")},
+	}
+
+	// within limit
+	for i := 0; i < 2; i++ {
+		resp, err := service.ShouldRateLimit(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ShouldRateLimit: %v", err)
+		}
+		if resp.OverallCode != rls.RateLimitResponse_OK {
+			t.Errorf("request %d should be OK, got %v", i, resp.OverallCode)
+		}
+	}
+
+	// over limit, remaining clamped to zero
+	resp, err := service.ShouldRateLimit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ShouldRateLimit: %v", err)
+	}
+	if resp.OverallCode != rls.RateLimitResponse_OVER_LIMIT {
+		t.Errorf("want OVER_LIMIT, got %v", resp.OverallCode)
+	}
+	if len(resp.Statuses) != 1 || resp.Statuses[0].LimitRemaining != 0 {
+		t.Errorf("remaining should be 0: %v", resp.Statuses)
+	}
+
+	// missing identifier is an error
+	bad := &rls.RateLimitRequest{
+		Descriptors: []*ratelimit.RateLimitDescriptor{{
+			Entries: []*ratelimit.RateLimitDescriptor_Entry{
+				{Key: rlsAllowKey, Value: "1"},
+			},
+		}},
+	}
+	if _, err := service.ShouldRateLimit(context.Background(), bad); err == nil {
+		t.Errorf("descriptor without identifier should error")
+	}
+
+	// bad allow value is an error
+	badAllow := &rls.RateLimitRequest{
+		Descriptors: []*ratelimit.RateLimitDescriptor{rlsDescriptor("client-2", "lots")},
+	}
+	if _, err := service.ShouldRateLimit(context.Background(), badAllow); err == nil {
+		t.Errorf("unparseable allow should error")
+	}
+}
@@ -37,5 +37,5 @@ type Result struct {
 }
 
 func (r *Result) expiredAt(tm time.Time) bool {
-	return time.Unix(r.ExpiryTime, 0).After(tm)
+	return tm.After(time.Unix(r.ExpiryTime, 0))
 }
@@ -0,0 +1,81 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quota window time units, as returned in product quotaTimeUnit
+const (
+	timeUnitSecond = "second"
+	timeUnitMinute = "minute"
+	timeUnitHour   = "hour"
+	timeUnitDay    = "day"
+	timeUnitWeek   = "week"
+	timeUnitMonth  = "month"
+)
+
+// windowExpiration returns when a quota window starting at (or containing)
+// start expires. With calendar alignment the window is anchored to the
+// calendar boundary of the time unit (start of the minute, hour, day or
+// month in UTC) instead of rolling from the first request. Month windows
+// follow calendar months, so their length varies correctly across short
+// months and leap years.
+func windowExpiration(start time.Time, timeUnit string, interval int64, calendar bool) (time.Time, error) {
+	if interval <= 0 {
+		interval = 1
+	}
+	start = start.UTC()
+
+	switch strings.ToLower(timeUnit) {
+	case timeUnitSecond:
+		if calendar {
+			start = start.Truncate(time.Second)
+		}
+		return start.Add(time.Duration(interval) * time.Second), nil
+	case timeUnitMinute:
+		if calendar {
+			start = start.Truncate(time.Minute)
+		}
+		return start.Add(time.Duration(interval) * time.Minute), nil
+	case timeUnitHour:
+		if calendar {
+			start = start.Truncate(time.Hour)
+		}
+		return start.Add(time.Duration(interval) * time.Hour), nil
+	case timeUnitDay:
+		if calendar {
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		return start.AddDate(0, 0, int(interval)), nil
+	case timeUnitWeek:
+		if calendar {
+			start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			// align to Monday
+			offset := (int(start.Weekday()) + 6) % 7
+			start = start.AddDate(0, 0, -offset)
+		}
+		return start.AddDate(0, 0, 7*int(interval)), nil
+	case timeUnitMonth:
+		if calendar {
+			start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		return start.AddDate(0, int(interval), 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown quota time unit: %s", timeUnit)
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowExpiration(t *testing.T) {
+	// 2018-03-16 17:30:50 UTC, a Friday
+	start := time.Date(2018, 3, 16, 17, 30, 50, 0, time.UTC)
+
+	cases := map[string]struct {
+		timeUnit string
+		interval int64
+		calendar bool
+		want     time.Time
+	}{
+		"rolling minute": {
+			timeUnitMinute, 1, false,
+			time.Date(2018, 3, 16, 17, 31, 50, 0, time.UTC),
+		},
+		"calendar minute": {
+			timeUnitMinute, 1, true,
+			time.Date(2018, 3, 16, 17, 31, 0, 0, time.UTC),
+		},
+		"rolling hour x2": {
+			timeUnitHour, 2, false,
+			time.Date(2018, 3, 16, 19, 30, 50, 0, time.UTC),
+		},
+		"calendar day": {
+			timeUnitDay, 1, true,
+			time.Date(2018, 3, 17, 0, 0, 0, 0, time.UTC),
+		},
+		"calendar week aligns to monday": {
+			timeUnitWeek, 1, true,
+			time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC),
+		},
+		"rolling month": {
+			timeUnitMonth, 1, false,
+			time.Date(2018, 4, 16, 17, 30, 50, 0, time.UTC),
+		},
+		"calendar month": {
+			timeUnitMonth, 1, true,
+			time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"zero interval defaults to one": {
+			timeUnitSecond, 0, false,
+			start.Add(time.Second),
+		},
+	}
+
+	for desc, c := range cases {
+		got, err := windowExpiration(start, c.timeUnit, c.interval, c.calendar)
+		if err != nil {
+			t.Errorf("%s: %v", desc, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%s: want %s, got %s", desc, c.want, got)
+		}
+	}
+
+	// month windows follow calendar month lengths (leap year February)
+	leapStart := time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC)
+	got, err := windowExpiration(leapStart, timeUnitMonth, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("leap month: want %s, got %s", want, got)
+	}
+
+	// unknown unit is an error
+	if _, err := windowExpiration(start, "fortnight", 1, false); err == nil {
+		t.Errorf("unknown time unit should be an error")
+	}
+}
+
+func TestWindowDSTIndependence(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("timezone db unavailable: %v", err)
+	}
+
+	// 2018-03-11 01:30 PST: local clocks spring forward at 02:00, but quota
+	// windows are computed in UTC so a rolling day is always 24 hours
+	start := time.Date(2018, 3, 11, 1, 30, 0, 0, loc)
+	got, err := windowExpiration(start, timeUnitDay, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got.Sub(start.UTC()); d != 24*time.Hour {
+		t.Errorf("rolling day across DST should be 24h, got %s", d)
+	}
+}
+
+func TestResultExpiredAt(t *testing.T) {
+	r := &Result{ExpiryTime: 1521221450}
+	if r.expiredAt(time.Unix(1521221449, 0)) {
+		t.Errorf("should not be expired before ExpiryTime")
+	}
+	if !r.expiredAt(time.Unix(1521221451, 0)) {
+		t.Errorf("should be expired after ExpiryTime")
+	}
+}
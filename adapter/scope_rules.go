@@ -0,0 +1,87 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/product"
+)
+
+// A scopeRule requires specific OAuth scopes for paths matching a pattern.
+// Scope rules are evaluated in addition to product resource matching, so
+// finer-grained scope enforcement doesn't require separate products per scope.
+type scopeRule struct {
+	pattern *regexp.Regexp
+	scopes  []string
+}
+
+// parseScopeRules parses rules of the form "<path pattern>=<scope>[,<scope>...]".
+// Path patterns use the same syntax as API Product resources.
+func parseScopeRules(rules []string) ([]scopeRule, error) {
+	var parsed []scopeRule
+	for _, rule := range rules {
+		eq := strings.Index(rule, "=")
+		if eq <= 0 || eq == len(rule)-1 {
+			return nil, fmt.Errorf("scope rule must be <path pattern>=<scopes>: %s", rule)
+		}
+		reg, err := product.MakeResourceRegex(rule[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("bad path pattern in scope rule %s: %v", rule, err)
+		}
+		var scopes []string
+		for _, s := range strings.Split(rule[eq+1:], ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("scope rule requires at least one scope: %s", rule)
+		}
+		parsed = append(parsed, scopeRule{
+			pattern: reg,
+			scopes:  scopes,
+		})
+	}
+	return parsed, nil
+}
+
+// allowedByScopeRules is true if the claimed scopes satisfy every rule
+// matching the path. Paths matching no rule are allowed.
+func allowedByScopeRules(rules []scopeRule, path string, scopes []string) bool {
+	for _, rule := range rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		satisfied := false
+		for _, required := range rule.scopes {
+			for _, s := range scopes {
+				if required == s {
+					satisfied = true
+					break
+				}
+			}
+			if satisfied {
+				break
+			}
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"testing"
+)
+
+func TestParseScopeRules(t *testing.T) {
+	good := []string{
+		"/admin/**=scope1",
+		"/*/write=scope1,scope2",
+		"/=scope1, scope2",
+	}
+	rules, err := parseScopeRules(good)
+	if err != nil {
+		t.Fatalf("parseScopeRules: %v", err)
+	}
+	if len(rules) != len(good) {
+		t.Errorf("want %d rules, got %d", len(good), len(rules))
+	}
+	if len(rules[2].scopes) != 2 {
+		t.Errorf("want 2 scopes, got %v", rules[2].scopes)
+	}
+
+	bad := []string{
+		"/admin/**",      // no scopes
+		"=scope1",        // no path
+		"/admin/**=",     // empty scopes
+		"/**/bad=scope1", // bad pattern
+	}
+	for _, rule := range bad {
+		if _, err := parseScopeRules([]string{rule}); err == nil {
+			t.Errorf("rule %s should not parse", rule)
+		}
+	}
+}
+
+func TestAllowedByScopeRules(t *testing.T) {
+	rules, err := parseScopeRules([]string{
+		"/admin/**=admin",
+		"/users/*/data=read,write",
+	})
+	if err != nil {
+		t.Fatalf("parseScopeRules: %v", err)
+	}
+
+	cases := []struct {
+		path   string
+		scopes []string
+		want   bool
+	}{
+		{"/open", nil, true},                           // no matching rule
+		{"/admin/keys", []string{"admin"}, true},       // scope present
+		{"/admin/keys", []string{"read"}, false},       // wrong scope
+		{"/admin/keys", nil, false},                    // no scopes
+		{"/users/joe/data", []string{"write"}, true},   // any listed scope
+		{"/users/joe/data", []string{"admin"}, false},  // wrong scope
+		{"/users/joe/data/x", []string{"admin"}, true}, // * doesn't span segments
+		{"/admin/keys", []string{"x", "admin"}, true},  // extra scopes ok
+	}
+	for _, c := range cases {
+		if got := allowedByScopeRules(rules, c.path, c.scopes); got != c.want {
+			t.Errorf("path %s scopes %v: want %v got %v", c.path, c.scopes, c.want, got)
+		}
+	}
+
+	if !allowedByScopeRules(nil, "/admin/keys", nil) {
+		t.Errorf("no rules should allow everything")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ApplyFlagDefaults fills flags that were not set on the command line from
+// <envPrefix><FLAG> environment variables and an optional config file of
+// flag=value lines (command line > environment > config file).
+func ApplyFlagDefaults(cmd *cobra.Command, envPrefix, configFile string) error {
+	var fileValues map[string]string
+	if configFile != "" {
+		var err error
+		if fileValues, err = ReadPropertiesFile(configFile); err != nil {
+			return fmt.Errorf("error reading config file %s: %v", configFile, err)
+		}
+	}
+
+	var applyErr error
+	apply := func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		envKey := envPrefix + strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+		if value, ok := os.LookupEnv(envKey); ok {
+			if err := f.Value.Set(value); err != nil {
+				applyErr = fmt.Errorf("invalid value for %s: %v", envKey, err)
+				return
+			}
+			f.Changed = true
+			return
+		}
+		if value, ok := fileValues[f.Name]; ok {
+			if err := f.Value.Set(value); err != nil {
+				applyErr = fmt.Errorf("invalid value for %s in config file: %v", f.Name, err)
+				return
+			}
+			f.Changed = true
+		}
+	}
+	cmd.Flags().VisitAll(apply)
+	cmd.InheritedFlags().VisitAll(apply)
+	return applyErr
+}
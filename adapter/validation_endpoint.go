@@ -0,0 +1,170 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+	"github.com/apigee/istio-mixer-adapter/adapter/auth"
+	analyticsT "github.com/apigee/istio-mixer-adapter/template/analytics"
+	"github.com/hashicorp/go-multierror"
+	"istio.io/istio/mixer/pkg/adapter"
+)
+
+// analyticsValidationRequest is a sample analytics instance as a template
+// author would configure it, plus the org/env it would report to.
+// Timestamps are RFC3339.
+type analyticsValidationRequest struct {
+	Organization string                      `json:"organization"`
+	Environment  string                      `json:"environment"`
+	Instance     analyticsValidationInstance `json:"instance"`
+}
+
+type analyticsValidationInstance struct {
+	ApiProxy                     string            `json:"api_proxy"`
+	ResponseStatusCode           int64             `json:"response_status_code"`
+	ClientIp                     string            `json:"client_ip"`
+	RequestVerb                  string            `json:"request_verb"`
+	RequestUri                   string            `json:"request_uri"`
+	RequestPath                  string            `json:"request_path"`
+	Useragent                    string            `json:"useragent"`
+	ClientReceivedStartTimestamp string            `json:"client_received_start_timestamp"`
+	ClientReceivedEndTimestamp   string            `json:"client_received_end_timestamp"`
+	SourceWorkload               string            `json:"source_workload"`
+	SourceWorkloadNamespace      string            `json:"source_workload_namespace"`
+	DestinationWorkload          string            `json:"destination_workload"`
+	DestinationWorkloadNamespace string            `json:"destination_workload_namespace"`
+	MeshId                       string            `json:"mesh_id"`
+	RequestId                    string            `json:"request_id"`
+	ApiKey                       string            `json:"api_key"`
+	ApiClaims                    map[string]string `json:"api_claims"`
+}
+
+// analyticsValidationResult reports the Record the instance produces along
+// with its validation outcome
+type analyticsValidationResult struct {
+	Valid  bool              `json:"valid"`
+	Errors []string          `json:"errors,omitempty"`
+	Record *analytics.Record `json:"record,omitempty"`
+}
+
+// AnalyticsValidationHandler returns an http.Handler that runs a posted
+// sample analytics instance through the same attribute mapping, ensureFields
+// and validation the pipeline applies, and returns the resulting Record -
+// without buffering or sending anything. A shadow endpoint for template
+// authors to check their instance mappings.
+func AnalyticsValidationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a JSON analytics instance", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request analyticsValidationRequest
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONClaimsLength))
+		decoder.DisallowUnknownFields()
+		result := analyticsValidationResult{Valid: true}
+		if err := decoder.Decode(&request); err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, err.Error())
+			writeValidationResult(w, result)
+			return
+		}
+
+		inst, errs := request.Instance.toInstance()
+		for _, err := range errs {
+			result.Valid = false
+			result.Errors = append(result.Errors, err)
+		}
+
+		// attribute mapping, ensureFields and validation, as the pipeline would
+		record := (&handler{}).recordFromInstance(inst)
+		record = analytics.EnsureFields(record, &auth.Context{Context: &validationContext{
+			org: request.Organization,
+			env: request.Environment,
+		}})
+		if err := analytics.ValidateRecord(record, time.Now()); err != nil {
+			result.Valid = false
+			for _, err := range multierror.Append(nil, err).WrappedErrors() {
+				result.Errors = append(result.Errors, err.Error())
+			}
+		}
+		result.Record = &record
+
+		writeValidationResult(w, result)
+	})
+}
+
+func writeValidationResult(w http.ResponseWriter, result analyticsValidationResult) {
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// toInstance builds a template instance from the friendly JSON form
+func (i analyticsValidationInstance) toInstance() (*analyticsT.Instance, []string) {
+	var errs []string
+	parseTime := func(name, value string) time.Time {
+		if value == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, name+": "+err.Error())
+		}
+		return t
+	}
+
+	return &analyticsT.Instance{
+		ApiProxy:                     i.ApiProxy,
+		ResponseStatusCode:           i.ResponseStatusCode,
+		ClientIp:                     net.ParseIP(i.ClientIp),
+		RequestVerb:                  i.RequestVerb,
+		RequestUri:                   i.RequestUri,
+		RequestPath:                  i.RequestPath,
+		Useragent:                    i.Useragent,
+		ClientReceivedStartTimestamp: parseTime("client_received_start_timestamp", i.ClientReceivedStartTimestamp),
+		ClientReceivedEndTimestamp:   parseTime("client_received_end_timestamp", i.ClientReceivedEndTimestamp),
+		SourceWorkload:               i.SourceWorkload,
+		SourceWorkloadNamespace:      i.SourceWorkloadNamespace,
+		DestinationWorkload:          i.DestinationWorkload,
+		DestinationWorkloadNamespace: i.DestinationWorkloadNamespace,
+		MeshId:                       i.MeshId,
+		RequestId:                    i.RequestId,
+		ApiKey:                       i.ApiKey,
+		ApiClaims:                    i.ApiClaims,
+	}, errs
+}
+
+// validationContext is the minimal adapter context ensureFields needs
+type validationContext struct {
+	org string
+	env string
+}
+
+func (c *validationContext) Log() adapter.Logger    { return analytics.NewStandaloneEnv().Logger() }
+func (c *validationContext) Organization() string   { return c.org }
+func (c *validationContext) Environment() string    { return c.env }
+func (c *validationContext) Key() string            { return "" }
+func (c *validationContext) Secret() string         { return "" }
+func (c *validationContext) ApigeeBase() *url.URL   { return &url.URL{} }
+func (c *validationContext) CustomerBase() *url.URL { return &url.URL{} }
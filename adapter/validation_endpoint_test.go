@@ -0,0 +1,127 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+)
+
+func TestAnalyticsValidationHandler(t *testing.T) {
+	ts := httptest.NewServer(AnalyticsValidationHandler())
+	defer ts.Close()
+
+	now := time.Now().Format(time.RFC3339)
+	goodInstance := fmt.Sprintf(`{
+		"organization": "org",
+		"environment": "env",
+		"instance": {
+			"api_proxy": "service",
+			"response_status_code": 200,
+			"request_verb": "GET",
+			"request_uri": "/pets?limit=2",
+			"request_path": "/pets?limit=2",
+			"request_id": "req-1",
+			"client_received_start_timestamp": %q,
+			"client_received_end_timestamp": %q
+		}
+	}`, now, now)
+
+	var result struct {
+		Valid  bool              `json:"valid"`
+		Errors []string          `json:"errors"`
+		Record *analytics.Record `json:"record"`
+	}
+
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader(goodInstance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !result.Valid {
+		t.Fatalf("good instance should validate: %d %v", resp.StatusCode, result.Errors)
+	}
+
+	// the resulting Record is returned, after mapping and ensureFields
+	if result.Record == nil {
+		t.Fatal("result should include the mapped record")
+	}
+	if result.Record.RequestPath != "/pets" {
+		t.Errorf("query params should be stripped from request_path, got %q", result.Record.RequestPath)
+	}
+	if result.Record.Organization != "org" || result.Record.Environment != "env" {
+		t.Errorf("ensureFields should fill org/env, got %s/%s",
+			result.Record.Organization, result.Record.Environment)
+	}
+	if result.Record.GatewayFlowID != "req-1" {
+		t.Errorf("flow id should derive from the request id, got %q", result.Record.GatewayFlowID)
+	}
+
+	// an incomplete instance is rejected, but the mapped record still returned
+	resp, err = http.Post(ts.URL, "application/json",
+		strings.NewReader(`{"organization":"org","instance":{"api_proxy":"svc"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity || result.Valid || len(result.Errors) == 0 {
+		t.Errorf("bad instance should be rejected: %d %v", resp.StatusCode, result)
+	}
+	if result.Record == nil {
+		t.Errorf("the mapped record should be returned even when invalid")
+	}
+
+	// unknown fields indicate a bad mapping
+	resp, err = http.Post(ts.URL, "application/json", strings.NewReader(`{"not_a_field":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if result.Valid {
+		t.Errorf("unknown field should be rejected")
+	}
+
+	// bad timestamps are reported by name
+	resp, err = http.Post(ts.URL, "application/json", strings.NewReader(
+		`{"instance":{"client_received_start_timestamp":"not a time"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if result.Valid || len(result.Errors) == 0 {
+		t.Errorf("bad timestamp should be rejected: %v", result)
+	}
+
+	// GET is not allowed
+	resp, err = http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET should not be allowed, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+package apigee
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// respCache is a small TTL cache of GET response bodies with ETag revalidation.
+// It exists to speed up CLI verify/status flows which issue many repeated GETs
+// (proxy status, deployments) against slow management APIs.
+type respCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]*respCacheEntry
+	now     func() time.Time
+}
+
+type respCacheEntry struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+func newRespCache(ttl time.Duration) *respCache {
+	return &respCache{
+		ttl:     ttl,
+		entries: map[string]*respCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns any cached entry for the url and whether it is still fresh
+func (rc *respCache) get(url string) (entry *respCacheEntry, fresh bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	entry, ok := rc.entries[url]
+	if !ok {
+		return nil, false
+	}
+	return entry, rc.now().Before(entry.expires)
+}
+
+func (rc *respCache) put(url string, body []byte, etag string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.entries[url] = &respCacheEntry{
+		body:    body,
+		etag:    etag,
+		expires: rc.now().Add(rc.ttl),
+	}
+}
+
+// refresh extends the expiry of an existing entry (after a 304 revalidation)
+func (rc *respCache) refresh(url string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if entry, ok := rc.entries[url]; ok {
+		entry.expires = rc.now().Add(rc.ttl)
+	}
+}
+
+// cachedResponse synthesizes a Response for a cache hit
+func cachedResponse(req *http.Request, body []byte) *Response {
+	return newResponse(&http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Request:       req,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	})
+}
@@ -9,13 +9,17 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bgentry/go-netrc/netrc"
 	"github.com/google/go-querystring/query"
@@ -27,15 +31,42 @@ const (
 	userAgent      = "go-apigee-edge/" + libraryVersion
 	appJSON        = "application/json"
 	octetStream    = "application/octet-stream"
+
+	defaultClientTimeout = 2 * time.Minute
 )
 
+// defaultHTTPClient returns a client with connection pooling tuned for the
+// bursts of concurrent management calls the CLI makes; the default transport
+// only keeps two idle connections per host.
+func defaultHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: defaultClientTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+				DualStack: true,
+			}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   16,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
+
 // EdgeClient manages communication with Apigee Edge V1 Admin API.
+// It is safe for concurrent use by multiple goroutines.
 type EdgeClient struct {
 	// HTTP client used to communicate with the Edge API.
 	client *http.Client
 
-	auth  *EdgeAuth
-	debug bool
+	auth     *EdgeAuth
+	debug    bool
+	getCache *respCache
+	progress ProgressFunc
 
 	// Base URL for API requests.
 	BaseURL *url.URL
@@ -50,6 +81,14 @@ type EdgeClient struct {
 	Proxies ProxiesService
 
 	KVMService KVMService
+
+	Orgs OrgService
+
+	VirtualHosts VirtualHostsService
+
+	EnvGroups EnvGroupsService
+
+	Products ProductsService
 	// Account           AccountService
 	// Actions           ActionsService
 	// Domains           DomainsService
@@ -67,11 +106,43 @@ type EdgeClient struct {
 
 	// Optional function called after every successful request made to the DO APIs
 	onRequestCompleted RequestCompletionCallback
+	callbackLock       sync.RWMutex
+}
+
+func (c *EdgeClient) requestCompleted(req *http.Request, resp *http.Response) {
+	c.callbackLock.RLock()
+	callback := c.onRequestCompleted
+	c.callbackLock.RUnlock()
+	if callback != nil {
+		callback(req, resp)
+	}
 }
 
 // RequestCompletionCallback defines the type of the request callback function
 type RequestCompletionCallback func(*http.Request, *http.Response)
 
+// A ProgressEvent describes a step of a long-running client operation
+// (eg. a proxy import or deployment).
+type ProgressEvent struct {
+	Operation string // eg. "import", "deploy"
+	Step      string // human-readable description of the current step
+	Percent   int    // 0-100, best effort
+}
+
+// ProgressFunc receives progress events; it must not block
+type ProgressFunc func(ProgressEvent)
+
+// progressEvent emits a progress event if a ProgressFunc is configured
+func (c *EdgeClient) progressEvent(operation, step string, percent int) {
+	if c.progress != nil {
+		c.progress(ProgressEvent{
+			Operation: operation,
+			Step:      step,
+			Percent:   percent,
+		})
+	}
+}
+
 // ListOptions holds optional parameters to various List methods
 type ListOptions struct {
 	// to ask for expanded results
@@ -136,8 +207,17 @@ type EdgeClientOptions struct {
 	// Required. Authentication information for the Edge Management server.
 	Auth *EdgeAuth
 
-	// Optional. Warning: if set to true, HTTP Basic Auth base64 blobs will appear in output.
+	// Optional. If set to true, requests and responses are dumped to output
+	// with credentials and known secret fields redacted.
 	Debug bool
+
+	// Optional. If > 0, identical GET requests within the TTL are served from a
+	// small in-memory cache, with ETag revalidation (If-None-Match) once stale.
+	GetCacheTTL time.Duration
+
+	// Optional. Receives structured progress events for long-running
+	// operations such as proxy imports and deployments.
+	Progress ProgressFunc
 }
 
 // EdgeAuth holds information about how to authenticate to the Edge Management server.
@@ -191,7 +271,9 @@ func retrieveAuthFromNetrc(netrcPath, host string) (*EdgeAuth, error) {
 func NewEdgeClient(o *EdgeClientOptions) (*EdgeClient, error) {
 	httpClient := o.httpClient
 	if o.httpClient == nil {
-		httpClient = http.DefaultClient
+		httpClient = defaultHTTPClient()
+		// offline CLI testing: record or replay management responses
+		httpClient.Transport = replayTransportFromEnv(httpClient.Transport)
 	}
 	mgmtURL := o.MgmtURL
 	if o.MgmtURL == "" {
@@ -212,6 +294,14 @@ func NewEdgeClient(o *EdgeClientOptions) (*EdgeClient, error) {
 	c := &EdgeClient{client: httpClient, BaseURL: baseURL, BaseURLEnv: baseURLEnv, UserAgent: userAgent}
 	c.Proxies = &ProxiesServiceOp{client: c}
 	c.KVMService = &KVMServiceOp{client: c}
+	c.Orgs = &OrgServiceOp{client: c}
+	c.VirtualHosts = &VirtualHostsServiceOp{client: c}
+	c.EnvGroups = &EnvGroupsServiceOp{client: c}
+	c.Products = &ProductsServiceOp{client: c}
+	if o.GetCacheTTL > 0 {
+		c.getCache = newRespCache(o.GetCacheTTL)
+	}
+	c.progress = o.Progress
 
 	var e error
 	if o.Auth == nil {
@@ -358,7 +448,9 @@ func (c *EdgeClient) newRequest(method, urlStr string, body interface{}, include
 
 // OnRequestCompleted sets the request completion callback for the API
 func (c *EdgeClient) OnRequestCompleted(rc RequestCompletionCallback) {
+	c.callbackLock.Lock()
 	c.onRequestCompleted = rc
+	c.callbackLock.Unlock()
 }
 
 // newResponse creates a new Response for the provided http.Response
@@ -368,9 +460,25 @@ func newResponse(r *http.Response) *Response {
 	return &response
 }
 
+// redaction patterns for debug dumps: credentials in headers, well-known
+// secret fields in JSON bodies, and PEM private keys
+var redactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(Authorization: (?:Basic|Bearer) )\S+`),
+	regexp.MustCompile(`("(?:consumerKey|consumerSecret|key|secret|private_key)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(?s)(-----BEGIN [^-]*PRIVATE KEY-----).*?(-----END [^-]*PRIVATE KEY-----)`),
+}
+
+// redactDump masks credentials and secrets so debug output is safe to share
+func redactDump(data []byte) []byte {
+	for _, pattern := range redactPatterns {
+		data = pattern.ReplaceAll(data, []byte("${1}[REDACTED]${2}"))
+	}
+	return data
+}
+
 func debugDump(data []byte, err error) {
 	if err == nil {
-		fmt.Printf("%s\n\n", data)
+		fmt.Printf("%s\n\n", redactDump(data))
 	} else {
 		log.Fatalf("%s\n\n", err)
 	}
@@ -381,6 +489,10 @@ func debugDump(data []byte, err error) {
 // if an API error has occurred. If v implements the io.Writer interface, the
 // raw response will be written to v, without attempting to decode it.
 func (c *EdgeClient) Do(req *http.Request, v interface{}) (*Response, error) {
+	if c.getCache != nil && req.Method == http.MethodGet {
+		return c.doCachedGet(req, v)
+	}
+
 	if c.debug {
 		debugDump(httputil.DumpRequestOut(req, true))
 	}
@@ -389,9 +501,7 @@ func (c *EdgeClient) Do(req *http.Request, v interface{}) (*Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	if c.onRequestCompleted != nil {
-		c.onRequestCompleted(req, resp)
-	}
+	c.requestCompleted(req, resp)
 
 	defer func() {
 		if rerr := resp.Body.Close(); err == nil {
@@ -423,6 +533,69 @@ func (c *EdgeClient) Do(req *http.Request, v interface{}) (*Response, error) {
 	return response, err
 }
 
+// doCachedGet serves idempotent GETs through the response cache, revalidating
+// stale entries with If-None-Match when the server provided an ETag.
+func (c *EdgeClient) doCachedGet(req *http.Request, v interface{}) (*Response, error) {
+	url := req.URL.String()
+	entry, fresh := c.getCache.get(url)
+	if fresh {
+		if err := decodeBody(entry.body, v); err != nil {
+			return nil, err
+		}
+		return cachedResponse(req, entry.body), nil
+	}
+	if entry != nil && entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+
+	if c.debug {
+		debugDump(httputil.DumpRequestOut(req, true))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.requestCompleted(req, resp)
+	defer resp.Body.Close()
+
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		c.getCache.refresh(url)
+		if err := decodeBody(entry.body, v); err != nil {
+			return nil, err
+		}
+		return cachedResponse(req, entry.body), nil
+	}
+
+	response := newResponse(resp)
+	if err := CheckResponse(resp); err != nil {
+		return response, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.getCache.put(url, body, resp.Header.Get("ETag"))
+
+	if err := decodeBody(body, v); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// decodeBody decodes body into v as Do would
+func decodeBody(body []byte, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if w, ok := v.(io.Writer); ok {
+		_, err := w.Write(body)
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
 // IsHybrid returns true if Apigee hybrid target
 func (c *EdgeClient) IsHybrid() bool {
 	return strings.Contains(c.BaseURL.Host, "apigee.googleapis.com")
@@ -0,0 +1,96 @@
+package apigee
+
+import (
+	"fmt"
+	"path"
+)
+
+const envGroupsPath = "envgroups"
+
+// EnvGroupsService is an interface for interfacing with the Apigee hybrid/X
+// Admin API dealing with environment groups and their hostnames.
+type EnvGroupsService interface {
+	List() ([]EnvGroup, *Response, error)
+	Attachments(groupName string) ([]EnvGroupAttachment, *Response, error)
+	DiscoverHostnames(env string) ([]string, error)
+}
+
+// An EnvGroup routes a set of hostnames to its attached environments.
+type EnvGroup struct {
+	Name      string   `json:"name,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+	State     string   `json:"state,omitempty"`
+}
+
+// An EnvGroupAttachment attaches an environment to an environment group.
+type EnvGroupAttachment struct {
+	Name        string `json:"name,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// EnvGroupsServiceOp represents env group service operations
+type EnvGroupsServiceOp struct {
+	client *EdgeClient
+}
+
+var _ EnvGroupsService = &EnvGroupsServiceOp{}
+
+// List lists the environment groups in the organization
+func (s *EnvGroupsServiceOp) List() ([]EnvGroup, *Response, error) {
+	req, e := s.client.NewRequestNoEnv("GET", envGroupsPath, nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	result := struct {
+		EnvironmentGroups []EnvGroup `json:"environmentGroups,omitempty"`
+	}{}
+	resp, e := s.client.Do(req, &result)
+	if e != nil {
+		return nil, resp, e
+	}
+	return result.EnvironmentGroups, resp, e
+}
+
+// Attachments lists the environments attached to a group
+func (s *EnvGroupsServiceOp) Attachments(groupName string) ([]EnvGroupAttachment, *Response, error) {
+	path := path.Join(envGroupsPath, groupName, "attachments")
+	req, e := s.client.NewRequestNoEnv("GET", path, nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	result := struct {
+		EnvironmentGroupAttachments []EnvGroupAttachment `json:"environmentGroupAttachments,omitempty"`
+	}{}
+	resp, e := s.client.Do(req, &result)
+	if e != nil {
+		return nil, resp, e
+	}
+	return result.EnvironmentGroupAttachments, resp, e
+}
+
+// DiscoverHostnames returns the hostnames of all environment groups the
+// given environment is attached to.
+func (s *EnvGroupsServiceOp) DiscoverHostnames(env string) ([]string, error) {
+	groups, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var hostnames []string
+	for _, group := range groups {
+		attachments, _, err := s.Attachments(group.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, attachment := range attachments {
+			if attachment.Environment == env {
+				hostnames = append(hostnames, group.Hostnames...)
+				break
+			}
+		}
+	}
+	if len(hostnames) == 0 {
+		return nil, fmt.Errorf("no environment group hostnames found for env %s", env)
+	}
+	return hostnames, nil
+}
@@ -1,7 +1,11 @@
 package apigee
 
 import (
+	"encoding/json"
+	"fmt"
 	"path"
+	"strconv"
+	"strings"
 )
 
 const kvmPath = "keyvaluemaps"
@@ -11,8 +15,10 @@ const kvmPath = "keyvaluemaps"
 type KVMService interface {
 	Get(mapname string) (*KVM, *Response, error)
 	Create(kvm KVM) (*Response, error)
+	Delete(mapname string) (*Response, error)
 	UpdateEntry(kvmName string, entry Entry) (*Response, error)
 	AddEntry(kvmName string, entry Entry) (*Response, error)
+	DeleteEntry(kvmName, entryName string) (*Response, error)
 }
 
 // Entry is an entry in the KVM
@@ -21,11 +27,30 @@ type Entry struct {
 	Value string `json:"value,omitempty"`
 }
 
+// Encrypted is the KVM encryption flag. Edge returns it as a JSON boolean
+// while hybrid may return the strings "true"/"false"; this type accepts both.
+type Encrypted bool
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *Encrypted) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseBool(strings.Trim(string(b), `"`))
+	if err != nil {
+		return fmt.Errorf("invalid encrypted value: %s", string(b))
+	}
+	*e = Encrypted(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (e Encrypted) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bool(e))
+}
+
 // KVM represents an Apigee KVM
 type KVM struct {
-	Name      string  `json:"name,omitempty"`
-	Encrypted bool    `json:"encrypted,omitempty"`
-	Entries   []Entry `json:"entry,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Encrypted Encrypted `json:"encrypted,omitempty"`
+	Entries   []Entry   `json:"entry,omitempty"`
 }
 
 // GetValue returns a value from the KVM
@@ -71,8 +96,30 @@ func (s *KVMServiceOp) Create(kvm KVM) (*Response, error) {
 	return resp, e
 }
 
-// UpdateEntry updates a KVM entry
+// Delete deletes a KVM and returns a response
+func (s *KVMServiceOp) Delete(mapname string) (*Response, error) {
+	path := path.Join(kvmPath, mapname)
+	req, e := s.client.NewRequest("DELETE", path, nil)
+	if e != nil {
+		return nil, e
+	}
+	resp, e := s.client.Do(req, nil)
+	return resp, e
+}
+
+// UpdateEntry updates a KVM entry. The hybrid API has no in-place entry
+// update, so for parity the entry is deleted (ignoring a missing entry)
+// and recreated there.
 func (s *KVMServiceOp) UpdateEntry(kvmName string, entry Entry) (*Response, error) {
+	if s.client.IsHybrid() {
+		if resp, e := s.DeleteEntry(kvmName, entry.Name); e != nil {
+			if resp == nil || resp.StatusCode != 404 {
+				return resp, e
+			}
+		}
+		return s.AddEntry(kvmName, entry)
+	}
+
 	path := path.Join(kvmPath, kvmName, "entries", entry.Name)
 	req, e := s.client.NewRequest("POST", path, entry)
 	if e != nil {
@@ -92,3 +139,14 @@ func (s *KVMServiceOp) AddEntry(kvmName string, entry Entry) (*Response, error)
 	resp, e := s.client.Do(req, &entry)
 	return resp, e
 }
+
+// DeleteEntry deletes an entry from the KVM
+func (s *KVMServiceOp) DeleteEntry(kvmName, entryName string) (*Response, error) {
+	path := path.Join(kvmPath, kvmName, "entries", entryName)
+	req, e := s.client.NewRequest("DELETE", path, nil)
+	if e != nil {
+		return nil, e
+	}
+	resp, e := s.client.Do(req, nil)
+	return resp, e
+}
@@ -0,0 +1,67 @@
+package apigee
+
+// OrgService is an interface for interfacing with the Apigee Edge Admin API
+// dealing with organizations and their environments.
+type OrgService interface {
+	Get() (*Organization, *Response, error)
+	ListEnvironments() ([]string, *Response, error)
+	ValidateEnvironment(env string) (bool, error)
+}
+
+// Organization represents an Apigee organization
+type Organization struct {
+	Name         string   `json:"name,omitempty"`
+	DisplayName  string   `json:"displayName,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Environments []string `json:"environments,omitempty"`
+}
+
+// OrgServiceOp represents org service operations
+type OrgServiceOp struct {
+	client *EdgeClient
+}
+
+var _ OrgService = &OrgServiceOp{}
+
+// Get retrieves the client's organization, validating that it exists and the
+// credentials can access it.
+func (s *OrgServiceOp) Get() (*Organization, *Response, error) {
+	req, e := s.client.NewRequestNoEnv("GET", "", nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	org := Organization{}
+	resp, e := s.client.Do(req, &org)
+	if e != nil {
+		return nil, resp, e
+	}
+	return &org, resp, e
+}
+
+// ListEnvironments lists the environment names in the organization
+func (s *OrgServiceOp) ListEnvironments() ([]string, *Response, error) {
+	req, e := s.client.NewRequestNoEnv("GET", "environments", nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	envs := []string{}
+	resp, e := s.client.Do(req, &envs)
+	if e != nil {
+		return nil, resp, e
+	}
+	return envs, resp, e
+}
+
+// ValidateEnvironment is true if the named environment exists in the organization
+func (s *OrgServiceOp) ValidateEnvironment(env string) (bool, error) {
+	envs, _, err := s.ListEnvironments()
+	if err != nil {
+		return false, err
+	}
+	for _, e := range envs {
+		if e == env {
+			return true, nil
+		}
+	}
+	return false, nil
+}
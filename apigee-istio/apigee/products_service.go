@@ -0,0 +1,40 @@
+package apigee
+
+import (
+	"github.com/apigee/istio-mixer-adapter/adapter/product"
+)
+
+const apiProductsPath = "apiproducts"
+
+// ProductsService is an interface for interfacing with the Apigee Edge Admin
+// API dealing with API products.
+type ProductsService interface {
+	List() ([]product.APIProduct, error)
+}
+
+// ProductsServiceOp represents products service operations
+type ProductsServiceOp struct {
+	client *EdgeClient
+}
+
+var _ ProductsService = &ProductsServiceOp{}
+
+// List retrieves the expanded list of the organization's API products.
+func (s *ProductsServiceOp) List() ([]product.APIProduct, error) {
+	req, err := s.client.NewRequestNoEnv("GET", apiProductsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := req.URL.Query()
+	q.Add("expand", "true")
+	req.URL.RawQuery = q.Encode()
+
+	var res product.APIResponse
+	resp, err := s.client.Do(req, &res)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return res.APIProducts, nil
+}
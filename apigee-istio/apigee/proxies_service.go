@@ -2,7 +2,6 @@ package apigee
 
 import (
 	"archive/zip"
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -267,6 +266,7 @@ func (s *ProxiesServiceOp) Import(proxyName string, source string) (*ProxyRevisi
 	if err != nil {
 		return nil, nil, err
 	}
+	s.client.progressEvent("import", "preparing bundle "+source, 0)
 	zipfileName := source
 	if info.IsDir() {
 		// create a temporary zip file
@@ -305,6 +305,8 @@ func (s *ProxiesServiceOp) Import(proxyName string, source string) (*ProxyRevisi
 	origURL.RawQuery = q.Encode()
 	urlPath := origURL.String()
 
+	s.client.progressEvent("import", "uploading bundle", 30)
+
 	ioreader, err := os.Open(zipfileName)
 	if err != nil {
 		return nil, nil, err
@@ -317,19 +319,30 @@ func (s *ProxiesServiceOp) Import(proxyName string, source string) (*ProxyRevisi
 		if err != nil {
 			return nil, nil, err
 		}
+		req.ContentLength = info.Size()
 	} else { // hybrid API requires formdata format
-		var b bytes.Buffer
-		w := multipart.NewWriter(&b)
-		var fw io.Writer
-		if fw, err = w.CreateFormFile("file", zipfileName); err != nil {
-			return nil, nil, err
-		}
-		if _, err = io.Copy(fw, ioreader); err != nil {
-			return nil, nil, err
-		}
-		w.Close()
+		// stream the multipart body through a pipe so large bundles
+		// are never buffered in memory
+		pr, pw := io.Pipe()
+		w := multipart.NewWriter(pw)
+		go func() {
+			fw, err := w.CreateFormFile("file", zipfileName)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(fw, ioreader); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if err := w.Close(); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.Close()
+		}()
 
-		req, err = s.client.NewRequestNoEnv("POST", urlPath, &b)
+		req, err = s.client.NewRequestNoEnv("POST", urlPath, pr)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -341,6 +354,7 @@ func (s *ProxiesServiceOp) Import(proxyName string, source string) (*ProxyRevisi
 	if err != nil {
 		return nil, res, err
 	}
+	s.client.progressEvent("import", fmt.Sprintf("created revision %d", returnedProxyRevision.Revision), 100)
 	return &returnedProxyRevision, res, err
 }
 
@@ -424,6 +438,8 @@ func (s *ProxiesServiceOp) Undeploy(proxyName, env string, rev Revision) (*Proxy
 		return nil, nil, err
 	}
 
+	s.client.progressEvent("undeploy", fmt.Sprintf("undeploying %s revision %d from %s", proxyName, rev, env), 0)
+
 	deployment := ProxyRevisionDeployment{}
 	resp, err := s.client.Do(req, &deployment)
 	if err != nil {
@@ -455,6 +471,8 @@ func (s *ProxiesServiceOp) Deploy(proxyName, env string, rev Revision) (*ProxyRe
 		return nil, nil, e
 	}
 
+	s.client.progressEvent("deploy", fmt.Sprintf("deploying %s revision %d to %s", proxyName, rev, env), 0)
+
 	deployment := ProxyRevisionDeployment{}
 	resp, e := s.client.Do(req, &deployment)
 	if e != nil {
@@ -0,0 +1,73 @@
+package apigee
+
+import (
+	"path"
+)
+
+const virtualHostsPath = "virtualhosts"
+
+// VirtualHostsService is an interface for interfacing with the Apigee Edge
+// Admin API dealing with environment virtual hosts.
+type VirtualHostsService interface {
+	List() ([]string, *Response, error)
+	Get(name string) (*VirtualHost, *Response, error)
+}
+
+// A VirtualHost is an environment-level virtual host, optionally with TLS.
+type VirtualHost struct {
+	Name        string   `json:"name,omitempty"`
+	HostAliases []string `json:"hostAliases,omitempty"`
+	Port        string   `json:"port,omitempty"`
+	SSLInfo     *SSLInfo `json:"sSLInfo,omitempty"`
+}
+
+// IsTLS is true if the virtual host has TLS enabled
+func (v *VirtualHost) IsTLS() bool {
+	return v.SSLInfo != nil && v.SSLInfo.Enabled == "true"
+}
+
+// SSLInfo is the TLS configuration of a virtual host.
+type SSLInfo struct {
+	Enabled           string   `json:"enabled,omitempty"`
+	ClientAuthEnabled string   `json:"clientAuthEnabled,omitempty"`
+	KeyStore          string   `json:"keyStore,omitempty"`
+	KeyAlias          string   `json:"keyAlias,omitempty"`
+	TrustStore        string   `json:"trustStore,omitempty"`
+	Protocols         []string `json:"protocols,omitempty"`
+}
+
+// VirtualHostsServiceOp represents virtual host service operations
+type VirtualHostsServiceOp struct {
+	client *EdgeClient
+}
+
+var _ VirtualHostsService = &VirtualHostsServiceOp{}
+
+// List lists the virtual host names in the environment
+func (s *VirtualHostsServiceOp) List() ([]string, *Response, error) {
+	req, e := s.client.NewRequest("GET", virtualHostsPath, nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	names := []string{}
+	resp, e := s.client.Do(req, &names)
+	if e != nil {
+		return nil, resp, e
+	}
+	return names, resp, e
+}
+
+// Get retrieves a virtual host by name
+func (s *VirtualHostsServiceOp) Get(name string) (*VirtualHost, *Response, error) {
+	path := path.Join(virtualHostsPath, name)
+	req, e := s.client.NewRequest("GET", path, nil)
+	if e != nil {
+		return nil, nil, e
+	}
+	vh := VirtualHost{}
+	resp, e := s.client.Do(req, &vh)
+	if e != nil {
+		return nil, resp, e
+	}
+	return &vh, resp, e
+}
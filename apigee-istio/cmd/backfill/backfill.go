@@ -0,0 +1,150 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backfill
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const batchSize = 500
+
+type backfill struct {
+	*shared.RootArgs
+	provisionKey    string
+	provisionSecret string
+	fileName        string
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	b := &backfill{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "backfill [ndjson file]",
+		Short: "Backfill analytics records exported from Apigee Edge",
+		Long: `Reads newline-delimited JSON analytics records (as exported from Apigee Edge
+or the adapter's debug mirror) and uploads them through the standard
+analytics pipeline.`,
+		Args: cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(true)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if b.provisionKey == "" || b.provisionSecret == "" {
+				fatalf("backfill requires values for --key and --secret")
+			}
+			b.fileName = args[0]
+			b.run(printf, fatalf)
+		},
+	}
+
+	c.Flags().StringVarP(&b.provisionKey, "key", "k", "", "gateway key (from provision)")
+	c.Flags().StringVarP(&b.provisionSecret, "secret", "s", "", "gateway secret (from provision)")
+
+	return c
+}
+
+func (b *backfill) run(printf, fatalf shared.FormatFn) {
+	file, err := os.Open(b.fileName)
+	if err != nil {
+		fatalf("error opening %s: %v", b.fileName, err)
+	}
+	defer file.Close()
+
+	bufferPath, err := ioutil.TempDir("", "apigee-backfill")
+	if err != nil {
+		fatalf("error creating buffer dir: %v", err)
+	}
+	defer os.RemoveAll(bufferPath)
+
+	internalURL, err := url.Parse(b.InternalProxyURL)
+	if err != nil {
+		fatalf("error parsing internal proxy url: %v", err)
+	}
+
+	man, err := analytics.NewStandaloneManager(analytics.Options{
+		BufferPath:         bufferPath,
+		StagingFileLimit:   1024,
+		BaseURL:            internalURL,
+		Key:                b.provisionKey,
+		Secret:             b.provisionSecret,
+		Client:             http.DefaultClient,
+		CollectionInterval: time.Minute,
+	})
+	if err != nil {
+		fatalf("error starting analytics pipeline: %v", err)
+	}
+
+	tenant := analytics.Tenant{
+		Org:     b.Org,
+		Env:     b.Env,
+		Key:     b.provisionKey,
+		Secret:  b.provisionSecret,
+		BaseURL: internalURL,
+	}
+
+	var sent, dropped int
+	records := make([]analytics.Record, 0, batchSize)
+	flush := func() {
+		if len(records) == 0 {
+			return
+		}
+		if err := man.SendTenantRecords(tenant, records); err != nil {
+			fatalf("error sending records: %v", err)
+		}
+		sent += len(records)
+		records = records[:0]
+	}
+
+	scan := bufio.NewScanner(file)
+	scan.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	for scan.Scan() {
+		line := scan.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record analytics.Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			dropped++
+			continue
+		}
+		records = append(records, record)
+		if len(records) >= batchSize {
+			flush()
+		}
+	}
+	if err := scan.Err(); err != nil {
+		fatalf("error reading %s: %v", b.fileName, err)
+	}
+	flush()
+
+	// Close stages and uploads everything that was buffered
+	man.Close()
+
+	printf("backfilled %d records from %s (%d unparseable lines dropped)",
+		sent, filepath.Base(b.fileName), dropped)
+}
@@ -29,7 +29,6 @@ import (
 
 const (
 	servicesAttr          = "istio-services"
-	productsURLFormat     = "/v1/organizations/%s/apiproducts"               // ManagementBase
 	productAttrPathFormat = "/v1/organizations/%s/apiproducts/%s/attributes" // ManagementBase, prod
 )
 
@@ -140,21 +139,12 @@ func (b *bindings) getProducts() ([]product.APIProduct, error) {
 	if b.products != nil {
 		return b.products, nil
 	}
-	req, err := b.Client.NewRequest(http.MethodGet, "", nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-	req.URL.Path = fmt.Sprintf(productsURLFormat, b.Org) // hack: negate client's base URL
-	req.URL.RawQuery = "expand=true"
-
-	var res product.APIResponse
-	resp, err := b.Client.Do(req, &res)
+	products, err := b.Client.Products.List()
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving products: %v", err)
 	}
-	defer resp.Body.Close()
-
-	return res.APIProducts, nil
+	b.products = products
+	return products, nil
 }
 
 func (b *bindings) cmdList(printf, fatalf shared.FormatFn) error {
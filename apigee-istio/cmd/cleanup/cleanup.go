@@ -0,0 +1,140 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cleanup
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const istioNamespace = "istio-system"
+
+// clusterResources are the resources created by the sample configuration
+var clusterResources = [][]string{
+	{"rule", "apigee-rule"},
+	{"handler", "apigee-handler"},
+	{"instance", "apigee-authorization"},
+	{"instance", "apigee-analytics"},
+	{"adapter", "apigee"},
+	{"template", "apigee-authorization"},
+	{"template", "apigee-analytics"},
+	{"deployment", "apigee-adapter"},
+	{"service", "apigee-adapter"},
+}
+
+type cleanup struct {
+	*shared.RootArgs
+	dryRun        bool
+	undeployProxy bool
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	c := &cleanup{RootArgs: rootArgs}
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove cluster-side adapter resources (and optionally undeploy proxies)",
+		Long: `Removes the Mixer resources and adapter deployment created for the Apigee
+adapter from the cluster via kubectl. With --undeploy-proxies, the istio-auth
+proxy is also undeployed from the Apigee environment.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if c.undeployProxy {
+				return rootArgs.Resolve(false)
+			}
+			return nil
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			c.run(printf, fatalf)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&c.dryRun, "dry-run", "", false,
+		"print the kubectl commands instead of executing them")
+	cmd.Flags().BoolVarP(&c.undeployProxy, "undeploy-proxies", "", false,
+		"also undeploy the istio-auth proxy from the Apigee environment")
+
+	return cmd
+}
+
+func (c *cleanup) run(printf, fatalf shared.FormatFn) {
+	for _, resource := range clusterResources {
+		args := []string{"delete", resource[0], resource[1], "-n", istioNamespace, "--ignore-not-found"}
+		if c.dryRun {
+			printf("kubectl delete %s %s -n %s --ignore-not-found", resource[0], resource[1], istioNamespace)
+			continue
+		}
+		kubectl := exec.Command("kubectl", args...)
+		kubectl.Stdout = os.Stdout
+		kubectl.Stderr = os.Stderr
+		if err := kubectl.Run(); err != nil {
+			printf("warning: kubectl delete %s %s: %v", resource[0], resource[1], err)
+		}
+	}
+
+	if c.undeployProxy {
+		const authProxyName = "istio-auth"
+		var err error
+		if c.IsHybrid {
+			err = c.undeployHybrid(authProxyName, printf)
+		} else {
+			err = c.undeployLegacy(authProxyName, printf)
+		}
+		if err != nil {
+			fatalf("error undeploying %s: %v", authProxyName, err)
+		}
+	}
+
+	printf("cleanup complete")
+}
+
+func (c *cleanup) undeployLegacy(proxyName string, printf shared.FormatFn) error {
+	rev, err := c.Client.Proxies.GetDeployedRevision(proxyName)
+	if err != nil {
+		return err
+	}
+	if rev == nil {
+		printf("proxy %s is not deployed", proxyName)
+		return nil
+	}
+	printf("undeploying proxy %s revision %d from %s...", proxyName, *rev, c.Env)
+	if c.dryRun {
+		return nil
+	}
+	_, _, err = c.Client.Proxies.Undeploy(proxyName, c.Env, *rev)
+	return err
+}
+
+func (c *cleanup) undeployHybrid(proxyName string, printf shared.FormatFn) error {
+	rev, err := c.Client.Proxies.GetHybridDeployedRevision(proxyName)
+	if err != nil {
+		return err
+	}
+	if rev == nil {
+		printf("proxy %s is not deployed", proxyName)
+		return nil
+	}
+	printf("undeploying proxy %s revision %d from %s...", proxyName, *rev, c.Env)
+	if c.dryRun {
+		return nil
+	}
+	_, _, err = c.Client.Proxies.Undeploy(proxyName, c.Env, *rev)
+	return err
+}
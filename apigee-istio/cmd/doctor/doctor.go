@@ -0,0 +1,269 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const (
+	authProxyName     = "istio-auth"
+	kvmName           = "istio"
+	certsURLFormat    = "%s/certs"    // CustomerProxyURL
+	productsURLFormat = "%s/products" // CustomerProxyURL
+	versionURLFormat  = "%s/version"  // CustomerProxyURL
+	istioNamespace    = "istio-system"
+)
+
+// mixer CRDs the adapter configuration depends on
+var requiredCRDs = []string{
+	"handlers.config.istio.io",
+	"rules.config.istio.io",
+	"instances.config.istio.io",
+}
+
+// cluster resources created by the sample configuration
+var requiredResources = [][]string{
+	{"handler", "apigee-handler"},
+	{"rule", "apigee-rule"},
+	{"instance", "apigee-authorization"},
+	{"instance", "apigee-analytics"},
+}
+
+type doctor struct {
+	*shared.RootArgs
+	failures    int
+	skipCluster bool
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	d := &doctor{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose common misconfigurations",
+		Long: `Runs a series of checks against the management API, the deployed istio-auth
+proxy, the product bindings and (if kubectl is available) the cluster-side
+adapter resources, reporting common misconfigurations.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			d.run(printf)
+			if d.failures > 0 {
+				fatalf("%d check(s) failed", d.failures)
+			}
+			printf("all checks passed")
+		},
+	}
+
+	c.Flags().BoolVarP(&d.skipCluster, "skip-cluster", "", false,
+		"skip the kubectl-based cluster checks")
+
+	return c
+}
+
+func (d *doctor) pass(printf shared.FormatFn, format string, args ...interface{}) {
+	printf("  ok: "+format, args...)
+}
+
+func (d *doctor) fail(printf shared.FormatFn, format string, args ...interface{}) {
+	d.failures++
+	printf("FAIL: "+format, args...)
+}
+
+func (d *doctor) run(printf shared.FormatFn) {
+	printf("checking %s org %s env %s...", d.ManagementBase, d.Org, d.Env)
+
+	// management API reachable and authorized, istio-auth proxy present
+	proxy, resp, err := d.Client.Proxies.Get(authProxyName)
+	switch {
+	case err != nil && resp == nil:
+		d.fail(printf, "management API unreachable: %v", err)
+		return // nothing else can be checked
+	case resp != nil && resp.StatusCode == http.StatusUnauthorized:
+		d.fail(printf, "management credentials rejected (check --username/--password or --token)")
+		return
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		d.fail(printf, "proxy %s is not installed (run 'apigee-istio provision')", authProxyName)
+	case err != nil:
+		d.fail(printf, "error retrieving proxy %s: %v", authProxyName, err)
+	default:
+		d.pass(printf, "proxy %s is installed (%d revisions)", authProxyName, len(proxy.Revisions))
+	}
+
+	// environment exists in the organization
+	if ok, err := d.Client.Orgs.ValidateEnvironment(d.Env); err != nil {
+		d.fail(printf, "unable to list environments: %v", err)
+	} else if !ok {
+		d.fail(printf, "environment %s does not exist in org %s", d.Env, d.Org)
+	} else {
+		d.pass(printf, "environment %s exists in org %s", d.Env, d.Org)
+	}
+
+	// istio-auth proxy deployed to the environment
+	var deployed bool
+	if d.IsHybrid {
+		rev, e := d.Client.Proxies.GetHybridDeployedRevision(authProxyName)
+		deployed, err = rev != nil, e
+	} else {
+		rev, e := d.Client.Proxies.GetDeployedRevision(authProxyName)
+		deployed, err = rev != nil, e
+	}
+	if err != nil || !deployed {
+		d.fail(printf, "proxy %s is not deployed to env %s (run 'apigee-istio provision')", authProxyName, d.Env)
+	} else {
+		d.pass(printf, "proxy %s is deployed to env %s", authProxyName, d.Env)
+	}
+
+	// kvm exists (hybrid stores certs in the keystore instead)
+	if !d.IsHybrid {
+		if _, resp, err := d.Client.KVMService.Get(kvmName); err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				d.fail(printf, "kvm %s does not exist (run 'apigee-istio provision')", kvmName)
+			} else {
+				d.fail(printf, "error retrieving kvm %s: %v", kvmName, err)
+			}
+		} else {
+			d.pass(printf, "kvm %s exists", kvmName)
+		}
+	}
+
+	// customer proxy endpoints reachable
+	for _, target := range []string{
+		fmt.Sprintf(certsURLFormat, d.CustomerProxyURL),
+		fmt.Sprintf(productsURLFormat, d.CustomerProxyURL),
+	} {
+		resp, err := http.Get(target)
+		if err != nil {
+			d.fail(printf, "%s unreachable: %v", target, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			d.fail(printf, "%s returned %s", target, resp.Status)
+		} else {
+			d.pass(printf, "%s is reachable", target)
+		}
+	}
+
+	// at least one product is bound to a service
+	products, err := d.Client.Products.List()
+	if err != nil {
+		d.fail(printf, "unable to list products: %v", err)
+		return
+	}
+	var bound int
+	for _, p := range products {
+		if len(p.GetBoundServices()) > 0 || p.OperationGroup != nil {
+			bound++
+		}
+	}
+	if bound == 0 {
+		d.fail(printf, "no products are bound to services (run 'apigee-istio bindings add')")
+	} else {
+		d.pass(printf, "%d of %d products are bound to services", bound, len(products))
+	}
+
+	// deployed proxy matches this CLI's build
+	d.checkProxyVersion(printf)
+
+	if !d.skipCluster {
+		d.runClusterChecks(printf)
+	}
+}
+
+// checkProxyVersion compares the deployed istio-auth proxy version against
+// this CLI build; a mismatch usually means provision was run from another
+// version.
+func (d *doctor) checkProxyVersion(printf shared.FormatFn) {
+	target := fmt.Sprintf(versionURLFormat, d.CustomerProxyURL)
+	resp, err := http.Get(target)
+	if err != nil {
+		d.fail(printf, "%s unreachable: %v", target, err)
+		return
+	}
+	defer resp.Body.Close()
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		d.fail(printf, "can't read proxy version from %s: %v", target, err)
+		return
+	}
+	if shared.BuildInfo.Version != "" && version.Version != shared.BuildInfo.Version {
+		d.fail(printf, "istio-auth proxy version %s does not match CLI version %s (re-run 'apigee-istio provision -f')",
+			version.Version, shared.BuildInfo.Version)
+	} else {
+		d.pass(printf, "istio-auth proxy version %s matches CLI", version.Version)
+	}
+}
+
+// runClusterChecks verifies the kubernetes side of the installation: mixer
+// CRDs, the adapter resources and the adapter deployment itself. Checks are
+// skipped entirely when kubectl is not available.
+func (d *doctor) runClusterChecks(printf shared.FormatFn) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		printf("  skipping cluster checks: kubectl not found in PATH")
+		return
+	}
+	printf("checking cluster resources in namespace %s...", istioNamespace)
+
+	for _, crd := range requiredCRDs {
+		if _, err := kubectl("get", "crd", crd); err != nil {
+			d.fail(printf, "CRD %s not found (is Mixer installed?)", crd)
+		} else {
+			d.pass(printf, "CRD %s exists", crd)
+		}
+	}
+
+	for _, resource := range requiredResources {
+		if _, err := kubectl("get", resource[0], resource[1], "-n", istioNamespace); err != nil {
+			d.fail(printf, "%s %s not found (apply the configuration from 'apigee-istio provision')", resource[0], resource[1])
+		} else {
+			d.pass(printf, "%s %s exists", resource[0], resource[1])
+		}
+	}
+
+	// adapter deployment is present and has ready replicas
+	out, err := kubectl("get", "deployment", "apigee-adapter", "-n", istioNamespace,
+		"-o", "jsonpath={.status.readyReplicas}")
+	if err != nil {
+		d.fail(printf, "deployment apigee-adapter not found: %s", out)
+		return
+	}
+	if ready, _ := strconv.Atoi(out); ready < 1 {
+		d.fail(printf, "deployment apigee-adapter has no ready replicas (check 'kubectl logs -n %s deployment/apigee-adapter')", istioNamespace)
+	} else {
+		d.pass(printf, "deployment apigee-adapter has %s ready replica(s)", out)
+	}
+}
+
+// kubectl runs a kubectl command and returns its trimmed combined output
+func kubectl(args ...string) (string, error) {
+	out, err := exec.Command("kubectl", args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
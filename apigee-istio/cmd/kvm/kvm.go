@@ -0,0 +1,219 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kvm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/apigee"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/provision"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultKVMName  = "istio"
+	rotateURLFormat = "%s/rotate" // CustomerProxyURL
+)
+
+type kvm struct {
+	*shared.RootArgs
+	mapName               string
+	certExpirationInYears int
+	certKeyStrength       int
+	provisionKey          string
+	provisionSecret       string
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	k := &kvm{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "kvm",
+		Short: "Manage the KVM used by the istio-auth proxy",
+		Long:  "Manage the KVM used by the istio-auth proxy: inspect, set or delete entries and rotate the JWT certificate.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+	}
+
+	c.PersistentFlags().StringVarP(&k.mapName, "map", "", defaultKVMName, "KVM map name")
+
+	c.AddCommand(cmdKVMGet(k, printf, fatalf))
+	c.AddCommand(cmdKVMSet(k, printf, fatalf))
+	c.AddCommand(cmdKVMDelete(k, printf, fatalf))
+	c.AddCommand(cmdKVMRotateCert(k, printf, fatalf))
+
+	return c
+}
+
+func cmdKVMGet(k *kvm, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [entry name]",
+		Short: "Print the KVM, or the value of a single entry",
+		Args:  cobra.MaximumNArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			theKVM, resp, err := k.Client.KVMService.Get(k.mapName)
+			if err != nil {
+				if resp != nil && resp.StatusCode == http.StatusNotFound {
+					fatalf("kvm %s does not exist", k.mapName)
+				}
+				fatalf("error retrieving kvm %s: %v", k.mapName, err)
+			}
+			if len(args) == 1 {
+				value, ok := theKVM.GetValue(args[0])
+				if !ok {
+					fatalf("no entry %s in kvm %s", args[0], k.mapName)
+				}
+				printf("%s", value)
+				return
+			}
+			printf("kvm %s (encrypted: %v)", theKVM.Name, bool(theKVM.Encrypted))
+			for _, e := range theKVM.Entries {
+				printf("  %s: %s", e.Name, e.Value)
+			}
+		},
+	}
+}
+
+func cmdKVMSet(k *kvm, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set [entry name] [value]",
+		Short: "Set (add or update) a KVM entry",
+		Args:  cobra.ExactArgs(2),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			entry := apigee.Entry{
+				Name:  args[0],
+				Value: args[1],
+			}
+			theKVM, _, err := k.Client.KVMService.Get(k.mapName)
+			if err != nil {
+				fatalf("error retrieving kvm %s: %v", k.mapName, err)
+			}
+			if _, exists := theKVM.GetValue(entry.Name); exists {
+				if _, err := k.Client.KVMService.UpdateEntry(k.mapName, entry); err != nil {
+					fatalf("error updating entry %s: %v", entry.Name, err)
+				}
+			} else {
+				if _, err := k.Client.KVMService.AddEntry(k.mapName, entry); err != nil {
+					fatalf("error adding entry %s: %v", entry.Name, err)
+				}
+			}
+			printf("kvm %s entry %s set", k.mapName, entry.Name)
+		},
+	}
+}
+
+func cmdKVMDelete(k *kvm, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [entry name]",
+		Short: "Delete a KVM entry",
+		Args:  cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := k.Client.KVMService.DeleteEntry(k.mapName, args[0]); err != nil {
+				fatalf("error deleting entry %s: %v", args[0], err)
+			}
+			printf("kvm %s entry %s deleted", k.mapName, args[0])
+		},
+	}
+}
+
+func cmdKVMRotateCert(k *kvm, printf, fatalf shared.FormatFn) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "rotate-cert",
+		Short: "Generate and register a new JWT certificate and private key",
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			cert, privateKey, err := provision.GenKeyCert(k.certKeyStrength, k.certExpirationInYears)
+			if err != nil {
+				fatalf("error generating cert: %v", err)
+			}
+
+			if k.IsHybrid {
+				if k.provisionKey == "" || k.provisionSecret == "" {
+					fatalf("hybrid rotation requires --key and --secret")
+				}
+				if err := k.rotateHybrid(cert, privateKey); err != nil {
+					fatalf("error rotating cert: %v", err)
+				}
+			} else {
+				entries := []apigee.Entry{
+					{Name: "private_key", Value: privateKey},
+					{Name: "certificate1", Value: cert},
+					{Name: "certificate1_kid", Value: "1"},
+				}
+				for _, entry := range entries {
+					if _, err := k.Client.KVMService.UpdateEntry(k.mapName, entry); err != nil {
+						fatalf("error updating entry %s: %v", entry.Name, err)
+					}
+				}
+			}
+
+			printf("registered a new key and cert for JWTs:\n")
+			printf("certificate:\n%s", cert)
+			printf("private key:\n%s", privateKey)
+		},
+	}
+
+	c.Flags().IntVarP(&k.certExpirationInYears, "years", "", 1,
+		"number of years before the jwt cert expires")
+	c.Flags().IntVarP(&k.certKeyStrength, "strength", "", 2048,
+		"key strength")
+	c.Flags().StringVarP(&k.provisionKey, "key", "k", "", "gateway key (hybrid only)")
+	c.Flags().StringVarP(&k.provisionSecret, "secret", "s", "", "gateway secret (hybrid only)")
+
+	return c
+}
+
+// rotateHybrid registers the cert via the istio-auth proxy's rotate endpoint,
+// which updates the hybrid keystore.
+func (k *kvm) rotateHybrid(cert, privateKey string) error {
+	rotateReq := struct {
+		PrivateKey  string `json:"private_key"`
+		Certificate string `json:"certificate"`
+		KeyID       string `json:"kid"`
+	}{
+		PrivateKey:  privateKey,
+		Certificate: cert,
+		KeyID:       "1",
+	}
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(rotateReq); err != nil {
+		return err
+	}
+	rotateURL := fmt.Sprintf(rotateURLFormat, k.CustomerProxyURL)
+	req, err := http.NewRequest(http.MethodPost, rotateURL, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(k.provisionKey, k.provisionSecret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.Client.Do(req, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
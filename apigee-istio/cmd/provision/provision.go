@@ -20,6 +20,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/binary"
@@ -35,6 +36,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -64,6 +66,8 @@ const (
 	internalProxyZip   = "istio-internal.zip"
 
 	apiProductsPath        = "apiproducts"
+	servicesAttr           = "istio-services"
+	productAttrPathFormat  = "apiproducts/%s/attributes" // product name
 	developersPath         = "developers"
 	applicationsPathFormat = "developers/%s/apps"                // developer email
 	keyCreatePathFormat    = "developers/%s/apps/%s/keys/create" // developer email, app ID
@@ -81,6 +85,11 @@ const (
 	// virtualHost is only necessary for legacy
 	virtualHostReplaceText    = "<VirtualHost>default</VirtualHost>"
 	virtualHostReplacementFmt = "<VirtualHost>%s</VirtualHost>" // each virtualHost
+
+	// machine-readable exit codes for --ci
+	exitOK              = 0
+	exitProvisionFailed = 2
+	exitVerifyFailed    = 3
 )
 
 type provision struct {
@@ -93,6 +102,111 @@ type provision struct {
 	provisionKey          string
 	provisionSecret       string
 	developerEmail        string
+	certFile              string
+	keyFile               string
+	ci                    bool
+	ciResult              *ciResult
+	bindServices          string
+	checksumFile          string
+	checksums             map[string]string // asset name -> sha256
+	proxyVersion          string
+	pluginDir             string
+	resume                bool
+	stateFile             string
+	state                 *provisionState
+}
+
+// provisionState records completed provisioning steps so an interrupted run
+// can be resumed without redeploying proxies or minting new credentials.
+type provisionState struct {
+	InternalProxyDeployed bool   `json:"internal_proxy_deployed,omitempty"`
+	AuthProxyDeployed     bool   `json:"auth_proxy_deployed,omitempty"`
+	Key                   string `json:"key,omitempty"`
+	Secret                string `json:"secret,omitempty"`
+	KVMReady              bool   `json:"kvm_ready,omitempty"`
+}
+
+func (p *provision) defaultStateFile() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("apigee-istio-provision-%s-%s.json", p.Org, p.Env))
+}
+
+func (p *provision) loadState() {
+	p.state = &provisionState{}
+	if !p.resume {
+		return
+	}
+	data, err := ioutil.ReadFile(p.stateFile)
+	if err != nil {
+		return // no prior state, start fresh
+	}
+	json.Unmarshal(data, p.state)
+}
+
+func (p *provision) saveState(fatalf shared.FormatFn) {
+	data, err := json.Marshal(p.state)
+	if err != nil {
+		fatalf("error saving provisioning state: %v", err)
+	}
+	if err := ioutil.WriteFile(p.stateFile, data, 0600); err != nil {
+		fatalf("error saving provisioning state: %v", err)
+	}
+}
+
+// plugin phases, passed as the first argument to each plugin executable
+const (
+	pluginPhasePre  = "pre-provision"
+	pluginPhasePost = "post-provision"
+)
+
+// runPlugins executes the executables in the plugin dir in name order,
+// passing the phase as the first argument and the org/env context in the
+// environment. A failing plugin aborts provisioning.
+func (p *provision) runPlugins(phase string, printf shared.FormatFn) error {
+	if p.pluginDir == "" {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(p.pluginDir)
+	if err != nil {
+		return errors.Wrapf(err, "error reading plugin dir %s", p.pluginDir)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Mode()&0111 == 0 {
+			continue
+		}
+		pluginPath := filepath.Join(p.pluginDir, entry.Name())
+		printf("running plugin %s %s...", entry.Name(), phase)
+
+		plugin := exec.Command(pluginPath, phase)
+		plugin.Env = append(os.Environ(),
+			"APIGEE_ORG="+p.Org,
+			"APIGEE_ENV="+p.Env,
+			"APIGEE_MANAGEMENT_BASE="+p.ManagementBase,
+			"APIGEE_ROUTER_BASE="+p.RouterBase,
+			"APIGEE_CUSTOMER_PROXY_URL="+p.CustomerProxyURL,
+		)
+		plugin.Stdout = os.Stdout
+		plugin.Stderr = os.Stderr
+		if err := plugin.Run(); err != nil {
+			return errors.Wrapf(err, "plugin %s failed at %s", entry.Name(), phase)
+		}
+	}
+	return nil
+}
+
+// ciResult is the single JSON document emitted on stdout in --ci mode
+type ciResult struct {
+	Provisioned bool     `json:"provisioned"`
+	Verified    bool     `json:"verified"`
+	Output      []string `json:"output,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// emitCI prints the result as JSON and exits with the given code
+func emitCI(result *ciResult, code int) {
+	json.NewEncoder(os.Stdout).Encode(result)
+	os.Exit(code)
 }
 
 // Cmd returns base command
@@ -120,6 +234,19 @@ to your organization and environment.`,
 			if p.verifyOnly && (p.provisionKey == "" || p.provisionSecret == "") {
 				fatalf("--verifyOnly requires values for --key and --secret")
 			}
+			if (p.certFile == "") != (p.keyFile == "") {
+				fatalf("--certificate-file and --private-key-file must be used together")
+			}
+			if p.ci { // capture output, exit with machine-readable codes
+				p.ciResult = &ciResult{}
+				printf = func(format string, args ...interface{}) {
+					p.ciResult.Output = append(p.ciResult.Output, fmt.Sprintf(format, args...))
+				}
+				fatalf = func(format string, args ...interface{}) {
+					p.ciResult.Errors = append(p.ciResult.Errors, fmt.Sprintf(format, args...))
+					emitCI(p.ciResult, exitProvisionFailed)
+				}
+			}
 			p.run(printf, fatalf)
 		},
 	}
@@ -140,6 +267,30 @@ to your organization and environment.`,
 	c.Flags().StringVarP(&p.provisionKey, "key", "k", "", "gateway key (for --verify-only)")
 	c.Flags().StringVarP(&p.provisionSecret, "secret", "s", "", "gateway secret (for --verify-only)")
 
+	c.Flags().BoolVarP(&p.resume, "resume", "", false,
+		"resume an interrupted provisioning run, skipping completed steps")
+	c.Flags().StringVarP(&p.stateFile, "state-file", "", "",
+		"where provisioning state is recorded for --resume (default per org/env in the temp dir)")
+
+	c.Flags().StringVarP(&p.pluginDir, "plugin-dir", "", "",
+		"directory of executables run before and after provisioning (passed pre-provision/post-provision)")
+
+	c.Flags().StringVarP(&p.checksumFile, "verify-checksums", "", "",
+		"path to a release checksums file (sha256sum format) to verify embedded proxy bundles against")
+	c.Flags().StringVarP(&p.proxyVersion, "proxy-version", "", "",
+		"required version of the embedded proxy bundles; provisioning fails on mismatch")
+
+	c.Flags().StringVarP(&p.bindServices, "bind-services", "b", "",
+		"comma-separated Istio services to bind the istio-auth product to")
+
+	c.Flags().BoolVarP(&p.ci, "ci", "", false,
+		"non-interactive CI mode: single JSON result on stdout and machine-readable exit codes")
+
+	c.Flags().StringVarP(&p.certFile, "certificate-file", "", "",
+		"path to a PEM certificate for JWTs (defaults to generating a self-signed one)")
+	c.Flags().StringVarP(&p.keyFile, "private-key-file", "", "",
+		"path to the PEM private key for --certificate-file")
+
 	return c
 }
 
@@ -154,55 +305,39 @@ func (p *provision) run(printf, fatalf shared.FormatFn) {
 
 	if !p.verifyOnly {
 
-		tempDir, err := ioutil.TempDir("", "apigee")
-		if err != nil {
-			fatalf("error creating temp dir: %v", err)
+		if p.stateFile == "" {
+			p.stateFile = p.defaultStateFile()
 		}
-		defer os.RemoveAll(tempDir)
+		p.loadState()
 
-		replaceVH := func(proxyDir string) error {
-			proxiesFile := filepath.Join(proxyDir, "proxies", "default.xml")
-			bytes, err := ioutil.ReadFile(proxiesFile)
-			if err != nil {
-				return errors.Wrapf(err, "error reading file %s", proxiesFile)
-			}
-			newVH := ""
-			for _, vh := range strings.Split(p.virtualHosts, ",") {
-				if strings.TrimSpace(vh) != "" {
-					newVH = newVH + fmt.Sprintf(virtualHostReplacementFmt, vh)
-				}
-			}
-			bytes = []byte(strings.Replace(string(bytes), virtualHostReplaceText, newVH, 1))
-			if err := ioutil.WriteFile(proxiesFile, bytes, 0); err != nil {
-				return errors.Wrapf(err, "error writing %s", proxiesFile)
-			}
-			return nil
+		if err := p.runPlugins(pluginPhasePre, verbosef); err != nil {
+			fatalf("%v", err)
 		}
 
-		replaceVHAndAuthTarget := func(proxyDir string) error {
-			if err := replaceVH(proxyDir); err != nil {
-				return err
+		if p.checksumFile != "" {
+			var err error
+			if p.checksums, err = loadChecksums(p.checksumFile); err != nil {
+				fatalf("error loading checksums: %v", err)
 			}
+		}
 
-			if p.IsOPDK {
-				policiesFile := filepath.Join(proxyDir, "policies", "Authenticate-Call.xml")
-				bytes, err := ioutil.ReadFile(policiesFile)
-				if err != nil {
-					return errors.Wrapf(err, "error reading file %s", policiesFile)
-				}
-				oldTarget := "https://edgemicroservices.apigee.net"
-				bytes = []byte(strings.Replace(string(bytes), oldTarget, p.RouterBase, 1))
-				if err := ioutil.WriteFile(policiesFile, bytes, 0); err != nil {
-					return errors.Wrapf(err, "error writing %s", policiesFile)
-				}
-			}
-			return nil
+		if p.proxyVersion != "" && p.proxyVersion != proxies.ProxyVersion {
+			fatalf("embedded proxy bundles are version %s, --proxy-version requires %s (use a matching CLI release)",
+				proxies.ProxyVersion, p.proxyVersion)
 		}
 
-		if p.IsOPDK {
-			if err := p.deployInternalProxy(replaceVH, tempDir, verbosef); err != nil {
+		tempDir, err := ioutil.TempDir("", "apigee")
+		if err != nil {
+			fatalf("error creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if p.IsOPDK && !p.state.InternalProxyDeployed {
+			if err := p.deployInternalProxy(p.replaceVH, tempDir, verbosef); err != nil {
 				fatalf("error deploying internal proxy: %v", err)
 			}
+			p.state.InternalProxyDeployed = true
+			p.saveState(fatalf)
 		}
 
 		// input istio-auth proxy
@@ -210,27 +345,66 @@ func (p *provision) run(printf, fatalf shared.FormatFn) {
 		if p.IsHybrid {
 			customizedProxy, err = getCustomizedProxy(tempDir, hybridAuthProxyZip, nil)
 		} else {
-			customizedProxy, err = getCustomizedProxy(tempDir, legacyAuthProxyZip, replaceVHAndAuthTarget)
+			customizedProxy, err = getCustomizedProxy(tempDir, legacyAuthProxyZip, p.replaceVHAndAuthTarget)
 		}
 		if err != nil {
 			fatalf(err.Error())
 		}
 
-		if err := p.checkAndDeployProxy(authProxyName, customizedProxy, verbosef); err != nil {
-			fatalf("error deploying %s proxy: %v", authProxyName, err)
-		}
-
 		if p.IsHybrid {
-			cred, err = p.createHybridCredential(verbosef)
+			err = p.verifyReleaseAsset(tempDir, hybridAuthProxyZip)
 		} else {
-			cred, err = p.createLegacyCredential(verbosef)
+			err = p.verifyReleaseAsset(tempDir, legacyAuthProxyZip)
 		}
 		if err != nil {
-			fatalf("error generating credential: %v", err)
+			fatalf("release artifact verification failed: %v", err)
 		}
 
-		if err := p.getOrCreateKVM(cred, verbosef); err != nil {
-			fatalf("error retrieving or creating kvm: %v", err)
+		if !p.state.AuthProxyDeployed {
+			if err := p.checkAndDeployProxy(authProxyName, customizedProxy, verbosef); err != nil {
+				fatalf("error deploying %s proxy: %v", authProxyName, err)
+			}
+			p.state.AuthProxyDeployed = true
+			p.saveState(fatalf)
+		} else {
+			verbosef("resume: proxy %s already deployed", authProxyName)
+		}
+
+		if p.state.Key != "" && p.state.Secret != "" {
+			verbosef("resume: reusing recorded credential")
+			cred = &credential{
+				Key:    p.state.Key,
+				Secret: p.state.Secret,
+			}
+		} else {
+			if p.IsHybrid {
+				cred, err = p.createHybridCredential(verbosef)
+			} else {
+				cred, err = p.createLegacyCredential(verbosef)
+				if err == nil && p.bindServices != "" {
+					err = p.ensureIstioAuthProduct(verbosef)
+				}
+			}
+			if err != nil {
+				fatalf("error generating credential: %v", err)
+			}
+			p.state.Key = cred.Key
+			p.state.Secret = cred.Secret
+			p.saveState(fatalf)
+		}
+
+		if !p.state.KVMReady {
+			if err := p.getOrCreateKVM(cred, verbosef); err != nil {
+				fatalf("error retrieving or creating kvm: %v", err)
+			}
+			p.state.KVMReady = true
+			p.saveState(fatalf)
+		} else {
+			verbosef("resume: kvm already created")
+		}
+
+		if err := p.runPlugins(pluginPhasePost, verbosef); err != nil {
+			fatalf("%v", err)
 		}
 
 	} else { // verifyOnly == true
@@ -277,18 +451,38 @@ func (p *provision) run(printf, fatalf shared.FormatFn) {
 		}
 	}
 
+	if p.ci {
+		p.ciResult.Provisioned = !p.verifyOnly
+		p.ciResult.Verified = verifyErrors == nil
+		code := exitOK
+		if verifyErrors != nil {
+			for _, err := range multierr.Errors(verifyErrors) {
+				p.ciResult.Errors = append(p.ciResult.Errors, err.Error())
+			}
+			code = exitVerifyFailed
+		}
+		emitCI(p.ciResult, code)
+	}
+
 	if verifyErrors != nil {
-		os.Exit(1)
+		os.Exit(exitVerifyFailed)
+	}
+
+	// a verified run needs no resume state
+	if !p.verifyOnly && p.stateFile != "" {
+		os.Remove(p.stateFile)
 	}
 
 	verbosef("provisioning verified OK")
 }
 
 // ensures that there's a product, developer, and app
-func (p *provision) createHybridCredential(verbosef shared.FormatFn) (*credential, error) {
+// ensureIstioAuthProduct creates the istio-auth product, carrying the
+// istio-services binding attribute when --bind-services is set. If the
+// product already exists, its attributes are updated to match.
+func (p *provision) ensureIstioAuthProduct(verbosef shared.FormatFn) error {
 	const istioAuthName = "istio-auth"
 
-	// create product
 	product := apiProduct{
 		Name:         istioAuthName,
 		DisplayName:  istioAuthName,
@@ -301,16 +495,41 @@ func (p *provision) createHybridCredential(verbosef shared.FormatFn) (*credentia
 		Environments: []string{p.Env},
 		Proxies:      []string{istioAuthName},
 	}
+	if p.bindServices != "" {
+		product.Attributes = append(product.Attributes, attribute{
+			Name:  servicesAttr,
+			Value: p.bindServices,
+		})
+	}
 	req, err := p.Client.NewRequestNoEnv(http.MethodPost, apiProductsPath, product)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	res, err := p.Client.Do(req, nil)
 	if err != nil {
 		if res.StatusCode != http.StatusConflict { // exists
-			return nil, err
+			return err
 		}
 		verbosef("product %s already exists", istioAuthName)
+
+		// ensure the existing product carries the requested bindings
+		if p.bindServices != "" {
+			if err := p.updateProductAttributes(istioAuthName, product.Attributes); err != nil {
+				return err
+			}
+			verbosef("product %s bound to: %s", istioAuthName, p.bindServices)
+		}
+	} else if p.bindServices != "" {
+		verbosef("product %s bound to: %s", istioAuthName, p.bindServices)
+	}
+	return nil
+}
+
+func (p *provision) createHybridCredential(verbosef shared.FormatFn) (*credential, error) {
+	const istioAuthName = "istio-auth"
+
+	if err := p.ensureIstioAuthProduct(verbosef); err != nil {
+		return nil, err
 	}
 
 	// create developer
@@ -321,11 +540,11 @@ func (p *provision) createHybridCredential(verbosef shared.FormatFn) (*credentia
 		LastName:  istioAuthName,
 		UserName:  istioAuthName,
 	}
-	req, err = p.Client.NewRequestNoEnv(http.MethodPost, developersPath, dev)
+	req, err := p.Client.NewRequestNoEnv(http.MethodPost, developersPath, dev)
 	if err != nil {
 		return nil, err
 	}
-	res, err = p.Client.Do(req, nil)
+	res, err := p.Client.Do(req, nil)
 	if err != nil {
 		if res.StatusCode != http.StatusConflict { // exists
 			return nil, err
@@ -393,6 +612,93 @@ func (p *provision) createHybridCredential(verbosef shared.FormatFn) (*credentia
 	return cred, nil
 }
 
+// updateProductAttributes replaces a product's attribute set
+func (p *provision) updateProductAttributes(productName string, attributes []attribute) error {
+	update := struct {
+		Attributes []attribute `json:"attribute,omitempty"`
+	}{
+		Attributes: attributes,
+	}
+	path := fmt.Sprintf(productAttrPathFormat, productName)
+	req, err := p.Client.NewRequestNoEnv(http.MethodPost, path, update)
+	if err != nil {
+		return err
+	}
+	if _, err := p.Client.Do(req, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replaceVH rewrites the proxy's virtual host bindings to p.virtualHosts
+func (p *provision) replaceVH(proxyDir string) error {
+	proxiesFile := filepath.Join(proxyDir, "proxies", "default.xml")
+	bytes, err := ioutil.ReadFile(proxiesFile)
+	if err != nil {
+		return errors.Wrapf(err, "error reading file %s", proxiesFile)
+	}
+	newVH := ""
+	for _, vh := range strings.Split(p.virtualHosts, ",") {
+		if strings.TrimSpace(vh) != "" {
+			newVH = newVH + fmt.Sprintf(virtualHostReplacementFmt, vh)
+		}
+	}
+	bytes = []byte(strings.Replace(string(bytes), virtualHostReplaceText, newVH, 1))
+	if err := ioutil.WriteFile(proxiesFile, bytes, 0); err != nil {
+		return errors.Wrapf(err, "error writing %s", proxiesFile)
+	}
+	return nil
+}
+
+// replaceVHAndAuthTarget rewrites virtual host bindings and, on OPDK, the
+// authentication target host
+func (p *provision) replaceVHAndAuthTarget(proxyDir string) error {
+	if err := p.replaceVH(proxyDir); err != nil {
+		return err
+	}
+
+	if p.IsOPDK {
+		policiesFile := filepath.Join(proxyDir, "policies", "Authenticate-Call.xml")
+		bytes, err := ioutil.ReadFile(policiesFile)
+		if err != nil {
+			return errors.Wrapf(err, "error reading file %s", policiesFile)
+		}
+		oldTarget := "https://edgemicroservices.apigee.net"
+		bytes = []byte(strings.Replace(string(bytes), oldTarget, p.RouterBase, 1))
+		if err := ioutil.WriteFile(policiesFile, bytes, 0); err != nil {
+			return errors.Wrapf(err, "error writing %s", policiesFile)
+		}
+	}
+	return nil
+}
+
+// RedeployAuthProxy re-imports and deploys the istio-auth proxy bound to the
+// given virtual hosts, replacing the deployed revision. It does not apply to
+// hybrid, where routing is configured through environment groups.
+func RedeployAuthProxy(r *shared.RootArgs, virtualHosts string, printf shared.FormatFn) error {
+	if r.IsHybrid {
+		return fmt.Errorf("virtual hosts do not apply to hybrid; configure routing via environment groups")
+	}
+
+	p := &provision{
+		RootArgs:          r,
+		virtualHosts:      virtualHosts,
+		forceProxyInstall: true,
+	}
+
+	tempDir, err := ioutil.TempDir("", "apigee")
+	if err != nil {
+		return errors.Wrap(err, "error creating temp dir")
+	}
+	defer os.RemoveAll(tempDir)
+
+	customizedProxy, err := getCustomizedProxy(tempDir, legacyAuthProxyZip, p.replaceVHAndAuthTarget)
+	if err != nil {
+		return err
+	}
+	return p.checkAndDeployProxy(authProxyName, customizedProxy, printf)
+}
+
 func (p *provision) deployInternalProxy(replaceVirtualHosts func(proxyDir string) error, tempDir string, verbosef shared.FormatFn) error {
 
 	customizedZip, err := getCustomizedProxy(tempDir, internalProxyZip, func(proxyDir string) error {
@@ -447,9 +753,63 @@ func (p *provision) deployInternalProxy(replaceVirtualHosts func(proxyDir string
 		return err
 	}
 
+	if err := p.verifyReleaseAsset(tempDir, internalProxyZip); err != nil {
+		return errors.Wrap(err, "release artifact verification failed")
+	}
+
 	return p.checkAndDeployProxy(internalProxyName, customizedZip, verbosef)
 }
 
+// loadChecksums parses a sha256sum-format manifest: "<hex>  <file name>"
+func loadChecksums(fileName string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	checksums := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		checksums[filepath.Base(fields[1])] = strings.ToLower(fields[0])
+	}
+	if len(checksums) == 0 {
+		return nil, fmt.Errorf("no checksums in %s", fileName)
+	}
+	return checksums, nil
+}
+
+// verifyReleaseAsset checks the restored (pre-customization) proxy bundle
+// against the checksums embedded at release build time and, if one was
+// provided, against an external release checksums manifest.
+func (p *provision) verifyReleaseAsset(tempDir, name string) error {
+	if p.checksums == nil && len(proxies.AssetChecksums) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(tempDir, name))
+	if err != nil {
+		return err
+	}
+	got := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	// self-check: the restored file must match the bytes this build shipped
+	if want, ok := proxies.AssetChecksums[name]; ok && got != want {
+		return fmt.Errorf("embedded asset %s is corrupted: want %s, got %s", name, want, got)
+	}
+
+	if p.checksums != nil {
+		want, ok := p.checksums[name]
+		if !ok {
+			return fmt.Errorf("no checksum for %s in manifest", name)
+		}
+		if got != want {
+			return fmt.Errorf("%s checksum mismatch: want %s, got %s", name, want, got)
+		}
+	}
+	return nil
+}
+
 type proxyModFunc func(name string) error
 
 // returns filename of zipped proxy
@@ -538,10 +898,35 @@ func GenKeyCert(keyStrength, certExpirationInYears int) (string, string, error)
 	return string(certBytes), string(keyBytes), nil
 }
 
-//check if the KVM exists, if it doesn't, create a new one and sets certs for JWT
+// keyCert returns the cert and private key to register for JWTs: either the
+// pair imported from --certificate-file and --private-key-file, or a
+// generated self-signed pair.
+func (p *provision) keyCert() (string, string, error) {
+	if p.certFile == "" {
+		return GenKeyCert(p.certKeyStrength, p.certExpirationInYears)
+	}
+
+	certBytes, err := ioutil.ReadFile(p.certFile)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "error reading %s", p.certFile)
+	}
+	keyBytes, err := ioutil.ReadFile(p.keyFile)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "error reading %s", p.keyFile)
+	}
+
+	// ensure the cert and key are a valid PEM pair before importing
+	if _, err := tls.X509KeyPair(certBytes, keyBytes); err != nil {
+		return "", "", errors.Wrap(err, "invalid certificate or private key")
+	}
+
+	return string(certBytes), string(keyBytes), nil
+}
+
+// check if the KVM exists, if it doesn't, create a new one and sets certs for JWT
 func (p *provision) getOrCreateKVM(cred *credential, printf shared.FormatFn) error {
 
-	cert, privateKey, err := GenKeyCert(p.certKeyStrength, p.certExpirationInYears)
+	cert, privateKey, err := p.keyCert()
 	if err != nil {
 		return err
 	}
@@ -644,7 +1029,9 @@ func (p *provision) createLegacyCredential(printf shared.FormatFn) (*credential,
 	return cred, nil
 }
 
-func (p *provision) printApigeeHandler(cred *credential, printf shared.FormatFn, verifyErrors error) error {
+// HandlerYAML renders the Istio handler configuration for the org/env with
+// the given gateway credentials.
+func HandlerYAML(r *shared.RootArgs, key, secret string) (string, error) {
 	handler := apigeeHandler{
 		APIVersion: "config.istio.io/v1alpha2",
 		Kind:       "handler",
@@ -658,27 +1045,43 @@ func (p *provision) printApigeeHandler(cred *credential, printf shared.FormatFn,
 				Address: "apigee-adapter:5000",
 			},
 			Params: params{
-				ApigeeBase:   p.InternalProxyURL,
-				CustomerBase: p.CustomerProxyURL,
-				OrgName:      p.Org,
-				EnvName:      p.Env,
-				Key:          cred.Key,
-				Secret:       cred.Secret,
+				ApigeeBase:   r.InternalProxyURL,
+				CustomerBase: r.CustomerProxyURL,
+				OrgName:      r.Org,
+				EnvName:      r.Env,
+				Key:          key,
+				Secret:       secret,
 			},
 		},
 	}
-	if p.IsOPDK {
+	if r.IsOPDK {
 		handler.Spec.Params.AnalyticsOptions = analyticsOptions{
 			LegacyEndpoint: true,
 		}
 	}
-	if p.IsHybrid {
+	if r.IsApigeeX {
+		// X has no UDCA properties file; the adapter uploads through the
+		// org runtime instead
+		handler.Spec.Params.ApigeeX = true
+		handler.Spec.Params.ApigeeBase = ""
+		handler.Spec.Params.AnalyticsOptions = analyticsOptions{
+			CollectionInterval: "10s",
+		}
+	} else if r.IsHybrid {
 		handler.Spec.Params.HybridConfig = "/opt/apigee/customer/default.properties"
 		handler.Spec.Params.AnalyticsOptions = analyticsOptions{
 			CollectionInterval: "10s",
 		}
 	}
 	formattedBytes, err := yaml.Marshal(handler)
+	if err != nil {
+		return "", err
+	}
+	return string(formattedBytes), nil
+}
+
+func (p *provision) printApigeeHandler(cred *credential, printf shared.FormatFn, verifyErrors error) error {
+	formatted, err := HandlerYAML(p.RootArgs, cred.Key, cred.Secret)
 	if err != nil {
 		return err
 	}
@@ -687,7 +1090,7 @@ func (p *provision) printApigeeHandler(cred *credential, printf shared.FormatFn,
 	if verifyErrors != nil {
 		printf("# WARNING: verification of provision failed. May not be valid.")
 	}
-	printf(string(formattedBytes))
+	printf(formatted)
 	return nil
 }
 
@@ -987,6 +1390,7 @@ type params struct {
 	ApigeeBase       string           `yaml:"apigee_base,omitempty"`
 	CustomerBase     string           `yaml:"customer_base"`
 	HybridConfig     string           `yaml:"hybrid_config,omitempty"`
+	ApigeeX          bool             `yaml:"apigee_x,omitempty"`
 	OrgName          string           `yaml:"org_name"`
 	EnvName          string           `yaml:"env_name"`
 	Key              string           `yaml:"key"`
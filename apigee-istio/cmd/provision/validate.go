@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v2"
+)
+
+// looseHandler mirrors the handler shape but leaves params as a free-form
+// map: the adapter's params schema grows release to release, so unknown
+// params must not fail validation here.
+type looseHandler struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Metadata   metadata  `yaml:"metadata"`
+	Spec       looseSpec `yaml:"spec"`
+}
+
+type looseSpec struct {
+	Adapter    string                 `yaml:"adapter"`
+	Connection connection             `yaml:"connection"`
+	Params     map[string]interface{} `yaml:"params"`
+}
+
+// ValidateHandlerYAML validates the schema of a generated Istio handler
+// configuration: the handler envelope must be well-formed and the params the
+// adapter requires must be present and valid. Params this validator doesn't
+// know about are allowed - the adapter's schema is the source of truth.
+func ValidateHandlerYAML(data []byte) error {
+	var handler looseHandler
+	if err := yaml.Unmarshal(data, &handler); err != nil {
+		return fmt.Errorf("invalid handler yaml: %v", err)
+	}
+
+	var errs error
+	appendErr := func(format string, args ...interface{}) {
+		errs = multierr.Append(errs, fmt.Errorf(format, args...))
+	}
+
+	if handler.Kind != "handler" {
+		appendErr("kind must be handler, got %q", handler.Kind)
+	}
+	if handler.Spec.Adapter != "apigee" {
+		appendErr("spec.adapter must be apigee, got %q", handler.Spec.Adapter)
+	}
+	if handler.Spec.Connection.Address == "" {
+		appendErr("spec.connection.address is required")
+	}
+
+	params := handler.Spec.Params
+	stringParam := func(name string) string {
+		v, _ := params[name].(string)
+		return v
+	}
+
+	for _, required := range []string{"org_name", "env_name", "key", "secret"} {
+		if stringParam(required) == "" {
+			appendErr("spec.params.%s is required", required)
+		}
+	}
+	if customerBase := stringParam("customer_base"); customerBase == "" {
+		appendErr("spec.params.customer_base is required")
+	} else if _, err := url.ParseRequestURI(customerBase); err != nil {
+		appendErr("spec.params.customer_base must be a valid url: %v", err)
+	}
+	apigeeBase := stringParam("apigee_base")
+	if apigeeBase == "" && stringParam("hybrid_config") == "" {
+		if apigeeX, _ := params["apigee_x"].(bool); !apigeeX {
+			appendErr("spec.params.apigee_base or hybrid_config is required")
+		}
+	} else if apigeeBase != "" {
+		if _, err := url.ParseRequestURI(apigeeBase); err != nil {
+			appendErr("spec.params.apigee_base must be a valid url: %v", err)
+		}
+	}
+
+	return errs
+}
+
+// ValidateHandlerCmd returns the validate-handler command
+func ValidateHandlerCmd(printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate-handler [handler yaml file]",
+		Short: "Validate the schema of a generated handler configuration",
+		Args:  cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				fatalf("error reading %s: %v", args[0], err)
+			}
+			if err := ValidateHandlerYAML(data); err != nil {
+				for _, err := range multierr.Errors(err) {
+					printf("invalid: %s", err)
+				}
+				fatalf("%s is not a valid handler configuration", args[0])
+			}
+			printf("%s is a valid handler configuration", args[0])
+		},
+	}
+}
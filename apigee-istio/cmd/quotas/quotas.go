@@ -0,0 +1,119 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quotas
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/product"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+type quotas struct {
+	*shared.RootArgs
+	all bool
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	q := &quotas{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "quotas",
+		Short: "Inspect the quota configuration of API Products",
+		Long: `Lists the quota configuration the adapter will enforce per product: the
+per-app limit, interval and time unit, plus any product-wide cap from the
+istio-product-quota-limit attribute.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			q.run(printf, fatalf)
+		},
+	}
+
+	c.Flags().BoolVarP(&q.all, "all", "", false,
+		"include products without quotas")
+
+	return c
+}
+
+func (q *quotas) run(printf, fatalf shared.FormatFn) {
+	products, err := q.Client.Products.List()
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+
+	// parse exactly as the adapter's product manager does, so string values
+	// that would silently disable quotas surface here
+	parseQuota := func(value string) (int64, error) {
+		if value == "" || value == "null" {
+			return 0, nil
+		}
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	var listed, parseErrors int
+	for _, p := range products {
+		hasQuota := p.QuotaLimit != "" && p.QuotaLimit != "null"
+		if !hasQuota && !q.all {
+			continue
+		}
+		listed++
+
+		printf("%s:", p.Name)
+		if hasQuota {
+			limit, limitErr := parseQuota(p.QuotaLimit)
+			interval, intervalErr := parseQuota(p.QuotaInterval)
+			switch {
+			case limitErr != nil:
+				parseErrors++
+				printf("  quota: PARSE ERROR, adapter will not enforce: limit %q: %v", p.QuotaLimit, limitErr)
+			case intervalErr != nil:
+				parseErrors++
+				printf("  quota: PARSE ERROR, adapter will not enforce: interval %q: %v", p.QuotaInterval, intervalErr)
+			default:
+				printf("  quota: %d requests every %d %s", limit, interval, p.QuotaTimeUnit)
+			}
+		} else {
+			printf("  quota: none")
+		}
+		if cap, ok := p.GetAttribute(product.ProductQuotaLimitAttr); ok && cap != "" {
+			if capInt, err := parseQuota(cap); err != nil {
+				parseErrors++
+				printf("  product-wide cap: PARSE ERROR, adapter will not enforce: %q: %v", cap, err)
+			} else {
+				printf("  product-wide cap: %d", capInt)
+			}
+		}
+		if services := p.GetBoundServices(); services != nil {
+			printf("  services: %s", strings.Join(services, ", "))
+		}
+	}
+
+	if listed == 0 {
+		printf("no products with quotas (use --all to list all products)")
+	}
+	if parseErrors > 0 {
+		fatalf("%d quota value(s) cannot be parsed and will not be enforced by the adapter", parseErrors)
+	}
+}
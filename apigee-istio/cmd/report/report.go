@@ -0,0 +1,152 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/product"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+type report struct {
+	*shared.RootArgs
+	format  string
+	service string
+}
+
+// A serviceReport describes the API traffic readiness of one Istio service.
+type serviceReport struct {
+	Service  string   `json:"service"`
+	Ready    bool     `json:"ready"`
+	Products []string `json:"products"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Quotas   []string `json:"quotas,omitempty"`
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	r := &report{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "report",
+		Short: "Report API traffic readiness per Istio service",
+		Long: `Emits a per-service report of API traffic readiness: which Apigee Products each
+bound Istio service resolves to, along with required scopes and quotas. Useful
+to verify configuration before routing traffic through the adapter.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			if r.format != formatJSON && r.format != formatCSV {
+				fatalf("invalid format: %s (must be %s or %s)", r.format, formatJSON, formatCSV)
+			}
+			r.run(printf, fatalf)
+		},
+	}
+
+	c.Flags().StringVarP(&r.format, "format", "f", formatJSON,
+		"output format: json or csv")
+	c.Flags().StringVarP(&r.service, "service", "s", "",
+		"limit the report to a single service")
+
+	return c
+}
+
+func (r *report) run(printf, fatalf shared.FormatFn) {
+	products, err := r.Client.Products.List()
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	reports := buildServiceReports(products, r.service)
+
+	switch r.format {
+	case formatCSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"service", "ready", "products", "scopes", "quotas"})
+		for _, sr := range reports {
+			w.Write([]string{
+				sr.Service,
+				fmt.Sprintf("%v", sr.Ready),
+				strings.Join(sr.Products, ";"),
+				strings.Join(sr.Scopes, ";"),
+				strings.Join(sr.Quotas, ";"),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			fatalf("error writing csv: %v", err)
+		}
+	case formatJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fatalf("error writing json: %v", err)
+		}
+	}
+}
+
+// buildServiceReports aggregates products by bound service
+func buildServiceReports(products []product.APIProduct, onlyService string) []serviceReport {
+	byService := map[string]*serviceReport{}
+	for _, p := range products {
+		for _, service := range p.GetBoundServices() {
+			service = strings.TrimSpace(service)
+			if service == "" || (onlyService != "" && service != onlyService) {
+				continue
+			}
+			sr, ok := byService[service]
+			if !ok {
+				sr = &serviceReport{Service: service, Ready: true}
+				byService[service] = sr
+			}
+			sr.Products = append(sr.Products, p.Name)
+			for _, scope := range p.Scopes {
+				if scope != "" {
+					sr.Scopes = append(sr.Scopes, scope)
+				}
+			}
+			if p.QuotaLimit != "" && p.QuotaLimit != "null" {
+				sr.Quotas = append(sr.Quotas, fmt.Sprintf("%s: %s per %s %s",
+					p.Name, p.QuotaLimit, p.QuotaInterval, p.QuotaTimeUnit))
+			}
+		}
+	}
+
+	var reports []serviceReport
+	for _, sr := range byService {
+		reports = append(reports, *sr)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Service < reports[j].Service })
+
+	if onlyService != "" && len(reports) == 0 {
+		reports = append(reports, serviceReport{Service: onlyService, Ready: false})
+	}
+	return reports
+}
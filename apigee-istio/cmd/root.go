@@ -22,9 +22,21 @@ import (
 	"os"
 	"strings"
 
+	"github.com/apigee/istio-mixer-adapter/adapter/util"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/backfill"
 	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/bindings"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/cleanup"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/doctor"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/kvm"
 	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/provision"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/quotas"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/report"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/security"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/smoke"
 	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/token"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/traffic"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/vhosts"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/watch"
 	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
 	"github.com/spf13/cobra"
 )
@@ -36,9 +48,13 @@ func init() {
 	}
 }
 
+// envVarPrefix is prepended to upper-cased flag names for environment defaults
+const envVarPrefix = "APIGEE_ISTIO_"
+
 // GetRootCmd returns the root of the cobra command-tree.
 func GetRootCmd(args []string, printf, fatalf shared.FormatFn) *cobra.Command {
 	rootArgs := &shared.RootArgs{}
+	var configFile string
 
 	c := &cobra.Command{
 		Use:   "apigee-istio",
@@ -47,9 +63,22 @@ func GetRootCmd(args []string, printf, fatalf shared.FormatFn) *cobra.Command {
 	}
 	c.SetArgs(args)
 	c.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	c.PersistentFlags().StringVarP(&configFile, "config", "c", "",
+		"config file of flag=value lines (flags may also be set via APIGEE_ISTIO_* env vars)")
 
 	var addCommand = func(cmds ...*cobra.Command) {
 		for _, subC := range cmds {
+			// fill unset flags from env vars and the config file first
+			prevPreRun := subC.PersistentPreRunE
+			subC.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+				if err := util.ApplyFlagDefaults(cmd, envVarPrefix, configFile); err != nil {
+					return err
+				}
+				if prevPreRun != nil {
+					return prevPreRun(cmd, args)
+				}
+				return nil
+			}
 			// add general flags
 			subC.PersistentFlags().StringVarP(&rootArgs.RouterBase, "routerBase", "r",
 				shared.DefaultRouterBase, "Apigee router base")
@@ -59,6 +88,8 @@ func GetRootCmd(args []string, printf, fatalf shared.FormatFn) *cobra.Command {
 				false, "verbose output")
 			subC.PersistentFlags().BoolVarP(&rootArgs.IsHybrid, "hybrid", "y",
 				false, "Apigee hybrid (automatically sets management base)")
+			subC.PersistentFlags().BoolVarP(&rootArgs.IsApigeeX, "apigee-x", "x",
+				false, "Apigee X (automatically sets management base, requires --token)")
 			subC.PersistentFlags().StringVarP(&rootArgs.NetrcPath, "netrc", "n",
 				"", "Path to a .netrc file to use (default is $HOME/.netrc")
 
@@ -83,6 +114,22 @@ func GetRootCmd(args []string, printf, fatalf shared.FormatFn) *cobra.Command {
 	addCommand(provision.Cmd(rootArgs, printf, fatalf))
 	addCommand(bindings.Cmd(rootArgs, printf, fatalf))
 	addCommand(token.Cmd(rootArgs, printf, fatalf))
+	addCommand(report.Cmd(rootArgs, printf, fatalf))
+	addCommand(kvm.Cmd(rootArgs, printf, fatalf))
+	addCommand(doctor.Cmd(rootArgs, printf, fatalf))
+	addCommand(vhosts.Cmd(rootArgs, printf, fatalf))
+	addCommand(security.Cmd(rootArgs, printf, fatalf))
+	addCommand(watch.Cmd(rootArgs, printf, fatalf))
+	addCommand(cleanup.Cmd(rootArgs, printf, fatalf))
+	addCommand(smoke.Cmd(rootArgs, printf, fatalf))
+	addCommand(backfill.Cmd(rootArgs, printf, fatalf))
+	addCommand(quotas.Cmd(rootArgs, printf, fatalf))
+
+	// traffic's backend checks use the org/env flags
+	addCommand(traffic.Cmd(rootArgs, printf, fatalf))
+
+	// validate-handler needs no org/env, don't use addCommand
+	c.AddCommand(provision.ValidateHandlerCmd(printf, fatalf))
 
 	c.AddCommand(version(rootArgs, printf, fatalf))
 
@@ -0,0 +1,236 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	issuerFormat  = "%s/token" // CustomerProxyURL
+	jwksURIFormat = "%s/certs" // CustomerProxyURL
+)
+
+type security struct {
+	*shared.RootArgs
+	namespace string
+}
+
+// requestAuthentication is the subset of the Istio CRD we generate
+type requestAuthentication struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Metadata   metadata         `yaml:"metadata"`
+	Spec       requestAuthnSpec `yaml:"spec"`
+}
+
+type requestAuthnSpec struct {
+	Selector *selector `yaml:"selector,omitempty"`
+	JWTRules []jwtRule `yaml:"jwtRules"`
+}
+
+type jwtRule struct {
+	Issuer  string `yaml:"issuer"`
+	JwksURI string `yaml:"jwksUri"`
+}
+
+type authorizationPolicy struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Metadata   metadata  `yaml:"metadata"`
+	Spec       authzSpec `yaml:"spec"`
+}
+
+type authzSpec struct {
+	Selector *selector   `yaml:"selector,omitempty"`
+	Action   string      `yaml:"action"`
+	Rules    []authzRule `yaml:"rules"`
+}
+
+type authzRule struct {
+	From []authzFrom `yaml:"from"`
+}
+
+type authzFrom struct {
+	Source authzSource `yaml:"source"`
+}
+
+type authzSource struct {
+	RequestPrincipals []string `yaml:"requestPrincipals"`
+}
+
+type selector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type metadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	s := &security{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "security",
+		Short: "Generate and validate Istio security policy for bound services",
+		Long: `Generates RequestAuthentication and AuthorizationPolicy resources equivalent
+to the adapter's JWT verification for services bound to Apigee products, and
+validates existing policy files against the org/env endpoints.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+	}
+
+	c.PersistentFlags().StringVarP(&s.namespace, "namespace", "", "default",
+		"kubernetes namespace for generated resources")
+
+	c.AddCommand(cmdSecurityGenerate(s, printf, fatalf))
+	c.AddCommand(cmdSecurityValidate(s, printf, fatalf))
+
+	return c
+}
+
+func cmdSecurityGenerate(s *security, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "generate",
+		Short: "Generate policy for all services bound to Apigee products",
+		Args:  cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			products, err := s.Client.Products.List()
+			if err != nil {
+				fatalf("%v", err)
+			}
+
+			services := map[string]bool{}
+			for _, p := range products {
+				for _, svc := range p.GetBoundServices() {
+					if svc = strings.TrimSpace(svc); svc != "" {
+						services[svc] = true
+					}
+				}
+			}
+			if len(services) == 0 {
+				fatalf("no products are bound to services")
+			}
+
+			for service := range services {
+				docs, err := s.policiesForService(service)
+				if err != nil {
+					fatalf("error generating policy for %s: %v", service, err)
+				}
+				printf("%s", docs)
+			}
+		},
+	}
+}
+
+func cmdSecurityValidate(s *security, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [policy file]",
+		Short: "Validate a policy file references this org/env's issuer and JWKS",
+		Args:  cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				fatalf("error reading %s: %v", args[0], err)
+			}
+
+			issuer := fmt.Sprintf(issuerFormat, s.CustomerProxyURL)
+			jwksURI := fmt.Sprintf(jwksURIFormat, s.CustomerProxyURL)
+
+			content := string(data)
+			var problems []string
+			if !strings.Contains(content, issuer) {
+				problems = append(problems, fmt.Sprintf("missing issuer %s", issuer))
+			}
+			if !strings.Contains(content, jwksURI) {
+				problems = append(problems, fmt.Sprintf("missing jwksUri %s", jwksURI))
+			}
+			if len(problems) > 0 {
+				fatalf("policy does not match org %s env %s:\n  %s",
+					s.Org, s.Env, strings.Join(problems, "\n  "))
+			}
+			printf("policy references the expected issuer and JWKS for org %s env %s", s.Org, s.Env)
+		},
+	}
+}
+
+// policiesForService renders the RequestAuthentication and
+// AuthorizationPolicy pair for one bound service.
+func (s *security) policiesForService(service string) (string, error) {
+	// istio service hosts look like name.namespace.svc.cluster.local
+	app := service
+	if dot := strings.Index(service, "."); dot > 0 {
+		app = service[:dot]
+	}
+	sel := &selector{MatchLabels: map[string]string{"app": app}}
+	issuer := fmt.Sprintf(issuerFormat, s.CustomerProxyURL)
+
+	reqAuthn := requestAuthentication{
+		APIVersion: "security.istio.io/v1beta1",
+		Kind:       "RequestAuthentication",
+		Metadata: metadata{
+			Name:      "apigee-" + app,
+			Namespace: s.namespace,
+		},
+		Spec: requestAuthnSpec{
+			Selector: sel,
+			JWTRules: []jwtRule{{
+				Issuer:  issuer,
+				JwksURI: fmt.Sprintf(jwksURIFormat, s.CustomerProxyURL),
+			}},
+		},
+	}
+
+	authz := authorizationPolicy{
+		APIVersion: "security.istio.io/v1beta1",
+		Kind:       "AuthorizationPolicy",
+		Metadata: metadata{
+			Name:      "apigee-" + app,
+			Namespace: s.namespace,
+		},
+		Spec: authzSpec{
+			Selector: sel,
+			Action:   "ALLOW",
+			Rules: []authzRule{{
+				From: []authzFrom{{
+					Source: authzSource{
+						RequestPrincipals: []string{issuer + "/*"},
+					},
+				}},
+			}},
+		},
+	}
+
+	reqAuthnBytes, err := yaml.Marshal(reqAuthn)
+	if err != nil {
+		return "", err
+	}
+	authzBytes, err := yaml.Marshal(authz)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("---\n%s---\n%s", reqAuthnBytes, authzBytes), nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoke
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const (
+	certsURLFormat        = "%s/certs"        // CustomerProxyURL
+	productsURLFormat     = "%s/products"     // CustomerProxyURL
+	verifyAPIKeyURLFormat = "%s/verifyApiKey" // CustomerProxyURL
+	quotasURLFormat       = "%s/quotas"       // CustomerProxyURL
+
+	analyticsURLFormat = "%s/analytics/organization/%s/environment/%s" // InternalProxyURL, org, env
+
+	apiProductsPath        = "apiproducts"
+	developersPath         = "developers"
+	applicationsPathFormat = "developers/%s/apps" // developer email
+	servicesAttr           = "istio-services"
+
+	// how long to wait for a new product/key to propagate to the adapter
+	propagationTimeout = 3 * time.Minute
+	propagationPoll    = 10 * time.Second
+)
+
+type smoke struct {
+	*shared.RootArgs
+	provisionKey    string
+	provisionSecret string
+	service         string
+	ingress         string
+	requestPath     string
+	failures        int
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	s := &smoke{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "smoke",
+		Short: "Run an end-to-end smoke test of a provisioned environment",
+		Long: `Exercises the full adapter path against a provisioned org/env: certs, product
+list, API key verification, quota allocation and the analytics upload
+endpoint, using the gateway credentials generated by provision.
+
+With --service and --ingress, additionally creates a temporary product bound
+to the service (quota 1 per minute), a temporary developer, app and key, and
+sends live requests through the ingress verifying authorized (200),
+unauthorized (401) and quota-exceeded (429) behavior. The temporary
+resources are removed afterward.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			if s.provisionKey == "" || s.provisionSecret == "" {
+				fatalf("smoke requires values for --key and --secret")
+			}
+			if (s.service == "") != (s.ingress == "") {
+				fatalf("--service and --ingress must be used together")
+			}
+			s.run(printf)
+			if s.service != "" {
+				s.runE2E(printf, fatalf)
+			}
+			if s.failures > 0 {
+				fatalf("%d smoke check(s) failed", s.failures)
+			}
+			printf("smoke test passed")
+		},
+	}
+
+	c.Flags().StringVarP(&s.provisionKey, "key", "k", "", "gateway key (from provision)")
+	c.Flags().StringVarP(&s.provisionSecret, "secret", "s", "", "gateway secret (from provision)")
+	c.Flags().StringVarP(&s.service, "service", "", "", "mesh service to bind the temporary test product to")
+	c.Flags().StringVarP(&s.ingress, "ingress", "", "", "ingress base URL to send live test requests through")
+	c.Flags().StringVarP(&s.requestPath, "path", "", "/", "request path for live test requests")
+
+	return c
+}
+
+func (s *smoke) check(printf shared.FormatFn, desc string, ok bool, detail string) {
+	if ok {
+		printf("  ok: %s", desc)
+		return
+	}
+	s.failures++
+	printf("FAIL: %s: %s", desc, detail)
+}
+
+func (s *smoke) run(printf shared.FormatFn) {
+	printf("smoke testing org %s env %s...", s.Org, s.Env)
+
+	do := func(method, target, body string) (int, string) {
+		var reader *strings.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		} else {
+			reader = strings.NewReader("")
+		}
+		req, err := http.NewRequest(method, target, reader)
+		if err != nil {
+			return 0, err.Error()
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.SetBasicAuth(s.provisionKey, s.provisionSecret)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Status
+	}
+
+	statusIn := func(got int, want ...int) bool {
+		for _, w := range want {
+			if got == w {
+				return true
+			}
+		}
+		return false
+	}
+
+	// JWKS certs
+	code, detail := do(http.MethodGet, fmt.Sprintf(certsURLFormat, s.CustomerProxyURL), "")
+	s.check(printf, "certs endpoint", code == http.StatusOK, detail)
+
+	// product list (exercises the gateway credentials)
+	code, detail = do(http.MethodGet, fmt.Sprintf(productsURLFormat, s.CustomerProxyURL), "")
+	s.check(printf, "products endpoint", code == http.StatusOK, detail)
+
+	// verifyApiKey: a fake key must be rejected, not error
+	code, detail = do(http.MethodPost, fmt.Sprintf(verifyAPIKeyURLFormat, s.CustomerProxyURL),
+		`{"apiKey":"smoke-test-fake-key"}`)
+	s.check(printf, "verifyApiKey endpoint", statusIn(code, http.StatusUnauthorized, http.StatusOK), detail)
+
+	// quota allocation
+	code, detail = do(http.MethodPost, fmt.Sprintf(quotasURLFormat, s.CustomerProxyURL),
+		`{"identifier":"smoke-test","weight":0,"interval":1,"allow":1,"timeUnit":"minute"}`)
+	s.check(printf, "quotas endpoint", code == http.StatusOK, detail)
+
+	// analytics signed URL (not applicable on hybrid)
+	if !s.IsHybrid {
+		analyticsURL := fmt.Sprintf(analyticsURLFormat, s.InternalProxyURL, s.Org, s.Env)
+		req, err := http.NewRequest(http.MethodGet, analyticsURL, nil)
+		if err != nil {
+			s.check(printf, "analytics endpoint", false, err.Error())
+			return
+		}
+		q := req.URL.Query()
+		q.Add("tenant", fmt.Sprintf("%s~%s", s.Org, s.Env))
+		q.Add("relative_file_path", "smoke-test")
+		q.Add("file_content_type", "application/x-gzip")
+		q.Add("encrypt", "true")
+		req.URL.RawQuery = q.Encode()
+		req.SetBasicAuth(s.provisionKey, s.provisionSecret)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			s.check(printf, "analytics endpoint", false, err.Error())
+		} else {
+			resp.Body.Close()
+			s.check(printf, "analytics endpoint", resp.StatusCode == http.StatusOK, resp.Status)
+		}
+	}
+}
+
+// runE2E drives live requests through the mesh ingress using a temporary
+// product (quota 1 per minute), developer, app and key, verifying the
+// authorized, unauthorized and quota-exceeded paths end to end.
+func (s *smoke) runE2E(printf, fatalf shared.FormatFn) {
+	name := "smoke-" + randomSuffix()
+	printf("running end-to-end test %s against %s via %s...", name, s.service, s.ingress)
+
+	cleanup, key, err := s.createTestResources(name, printf)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		fatalf("unable to create test resources: %v", err)
+	}
+
+	target := strings.TrimSuffix(s.ingress, "/") + s.requestPath
+
+	send := func(apiKey string) (int, error) {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			return 0, err
+		}
+		if apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	// the adapter refreshes products and keys on its own schedule; poll
+	// until the new key is accepted before making assertions
+	printf("waiting for product and key to propagate (up to %s)...", propagationTimeout)
+	deadline := time.Now().Add(propagationTimeout)
+	var code int
+	for {
+		if code, err = send(key); err != nil {
+			fatalf("request to %s: %v", target, err)
+		}
+		if code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(propagationPoll)
+	}
+	s.check(printf, "authorized request returns 200", code == http.StatusOK, fmt.Sprintf("got %d", code))
+
+	// no key must be rejected
+	if code, err = send(""); err != nil {
+		fatalf("request to %s: %v", target, err)
+	}
+	s.check(printf, "request without key returns 401", code == http.StatusUnauthorized, fmt.Sprintf("got %d", code))
+
+	// the product allows 1 per minute and the authorized request above used
+	// it; the next authorized request must be rejected for quota
+	if code, err = send(key); err != nil {
+		fatalf("request to %s: %v", target, err)
+	}
+	s.check(printf, "over-quota request returns 429", code == http.StatusTooManyRequests, fmt.Sprintf("got %d", code))
+}
+
+// createTestResources creates a product bound to the target service with a
+// 1-per-minute quota, plus a developer, app and key. The returned cleanup
+// func removes whatever was created, in reverse order.
+func (s *smoke) createTestResources(name string, printf shared.FormatFn) (func(), string, error) {
+	var created []func()
+	cleanup := func() {
+		for i := len(created) - 1; i >= 0; i-- {
+			created[i]()
+		}
+	}
+
+	remove := func(path string) {
+		req, err := s.Client.NewRequestNoEnv(http.MethodDelete, path, nil)
+		if err == nil {
+			_, err = s.Client.Do(req, nil)
+		}
+		if err != nil {
+			printf("warning: unable to remove %s: %v", path, err)
+		}
+	}
+
+	// product with a 1-per-minute quota, bound to the service
+	product := apiProduct{
+		Name:          name,
+		DisplayName:   name,
+		ApprovalType:  "auto",
+		Description:   "temporary apigee-istio smoke test product",
+		APIResources:  []string{"/**"},
+		Environments:  []string{s.Env},
+		Quota:         "1",
+		QuotaInterval: "1",
+		QuotaTimeUnit: "minute",
+		Attributes: []attribute{{
+			Name:  servicesAttr,
+			Value: s.service,
+		}},
+	}
+	req, err := s.Client.NewRequestNoEnv(http.MethodPost, apiProductsPath, product)
+	if err != nil {
+		return cleanup, "", err
+	}
+	if _, err = s.Client.Do(req, nil); err != nil {
+		return cleanup, "", fmt.Errorf("create product %s: %v", name, err)
+	}
+	created = append(created, func() { remove(apiProductsPath + "/" + name) })
+	printf("created product %s (quota 1 per minute)", name)
+
+	// developer
+	devEmail := name + "@apigee-istio-smoke.net"
+	dev := developer{
+		Email:     devEmail,
+		FirstName: name,
+		LastName:  name,
+		UserName:  name,
+	}
+	if req, err = s.Client.NewRequestNoEnv(http.MethodPost, developersPath, dev); err != nil {
+		return cleanup, "", err
+	}
+	if _, err = s.Client.Do(req, nil); err != nil {
+		return cleanup, "", fmt.Errorf("create developer %s: %v", devEmail, err)
+	}
+	created = append(created, func() { remove(developersPath + "/" + devEmail) })
+	printf("created developer %s", devEmail)
+
+	// app with a key for the product
+	app := application{
+		Name:        name,
+		APIProducts: []string{name},
+	}
+	applicationsPath := fmt.Sprintf(applicationsPathFormat, devEmail)
+	if req, err = s.Client.NewRequestNoEnv(http.MethodPost, applicationsPath, &app); err != nil {
+		return cleanup, "", err
+	}
+	if _, err = s.Client.Do(req, &app); err != nil {
+		return cleanup, "", fmt.Errorf("create app %s: %v", name, err)
+	}
+	created = append(created, func() { remove(applicationsPath + "/" + name) })
+	if len(app.Credentials) == 0 {
+		return cleanup, "", fmt.Errorf("app %s has no credentials", name)
+	}
+	printf("created app %s", name)
+
+	return cleanup, app.Credentials[0].Key, nil
+}
+
+// randomSuffix returns a short random hex string for resource names
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type apiProduct struct {
+	Name          string      `json:"name,omitempty"`
+	DisplayName   string      `json:"displayName,omitempty"`
+	ApprovalType  string      `json:"approvalType,omitempty"`
+	Attributes    []attribute `json:"attributes,omitempty"`
+	Description   string      `json:"description,omitempty"`
+	APIResources  []string    `json:"apiResources,omitempty"`
+	Environments  []string    `json:"environments,omitempty"`
+	Quota         string      `json:"quota,omitempty"`
+	QuotaInterval string      `json:"quotaInterval,omitempty"`
+	QuotaTimeUnit string      `json:"quotaTimeUnit,omitempty"`
+}
+
+type attribute struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+type developer struct {
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	UserName  string `json:"userName,omitempty"`
+}
+
+type application struct {
+	Name        string          `json:"name,omitempty"`
+	APIProducts []string        `json:"apiProducts,omitempty"`
+	Credentials []appCredential `json:"credentials,omitempty"`
+}
+
+type appCredential struct {
+	Key    string `json:"consumerKey,omitempty"`
+	Secret string `json:"consumerSecret,omitempty"`
+}
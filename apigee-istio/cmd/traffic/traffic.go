@@ -0,0 +1,271 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const (
+	quotasURLFormat    = "%s/quotas"                                   // CustomerProxyURL
+	analyticsURLFormat = "%s/analytics/organization/%s/environment/%s" // InternalProxyURL, org, env
+)
+
+type traffic struct {
+	*shared.RootArgs
+	targetURL       string
+	apiKey          string
+	headers         []string
+	requests        int
+	concurrency     int
+	timeout         time.Duration
+	provisionKey    string
+	provisionSecret string
+	quotaIdentifier string
+}
+
+// results aggregates per-status counts across workers
+type results struct {
+	lock     sync.Mutex
+	statuses map[int]int
+	errors   int
+}
+
+func (r *results) status(code int) {
+	r.lock.Lock()
+	r.statuses[code]++
+	r.lock.Unlock()
+}
+
+func (r *results) error() {
+	r.lock.Lock()
+	r.errors++
+	r.lock.Unlock()
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	t := &traffic{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "traffic",
+		Short: "Generate validation traffic against a service routed through the adapter",
+		Long: `Sends a burst of requests to the given URL, reports the response code
+distribution and - when gateway credentials are provided - checks the quota
+counters and analytics endpoint acceptance afterwards, to validate that the
+install is behaving as expected after binding a service.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// backend checks need the resolved org/env URLs
+			if t.provisionKey != "" || t.provisionSecret != "" {
+				return rootArgs.Resolve(true)
+			}
+			return nil
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			if t.targetURL == "" {
+				fatalf("--url is required")
+			}
+			if t.requests < 1 || t.concurrency < 1 {
+				fatalf("--requests and --connections must be > 0")
+			}
+			if t.concurrency > t.requests {
+				t.concurrency = t.requests
+			}
+			t.run(printf, fatalf)
+		},
+	}
+
+	c.Flags().StringVarP(&t.targetURL, "url", "", "", "target URL (required)")
+	c.Flags().StringVarP(&t.apiKey, "api-key", "", "", "API key to send in the x-api-key header")
+	c.Flags().StringArrayVarP(&t.headers, "header", "", nil, "additional header as name=value (repeatable)")
+	c.Flags().IntVarP(&t.requests, "requests", "", 100, "total number of requests to send")
+	c.Flags().IntVarP(&t.concurrency, "connections", "", 4, "number of concurrent connections")
+	c.Flags().DurationVarP(&t.timeout, "timeout", "", 30*time.Second, "per-request timeout")
+
+	c.Flags().StringVarP(&t.provisionKey, "key", "k", "", "gateway key, enables quota and analytics checks after the run")
+	c.Flags().StringVarP(&t.provisionSecret, "secret", "s", "", "gateway secret, enables quota and analytics checks after the run")
+	c.Flags().StringVarP(&t.quotaIdentifier, "quota-identifier", "", "", "quota identifier (<app>-<product>) to read the counter for")
+
+	return c
+}
+
+func (t *traffic) run(printf, fatalf shared.FormatFn) {
+	headers := http.Header{}
+	if t.apiKey != "" {
+		headers.Set("x-api-key", t.apiKey)
+	}
+	for _, h := range t.headers {
+		eq := strings.Index(h, "=")
+		if eq <= 0 {
+			fatalf("header must be name=value: %s", h)
+		}
+		headers.Set(h[:eq], h[eq+1:])
+	}
+
+	client := &http.Client{Timeout: t.timeout}
+	res := &results{statuses: map[int]int{}}
+	work := make(chan struct{}, t.concurrency)
+
+	printf("sending %d requests to %s using %d connections...", t.requests, t.targetURL, t.concurrency)
+	start := time.Now()
+
+	var wait sync.WaitGroup
+	for i := 0; i < t.concurrency; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for range work {
+				req, err := http.NewRequest(http.MethodGet, t.targetURL, nil)
+				if err != nil {
+					res.error()
+					continue
+				}
+				req.Header = headers
+				resp, err := client.Do(req)
+				if err != nil {
+					res.error()
+					continue
+				}
+				resp.Body.Close()
+				res.status(resp.StatusCode)
+			}
+		}()
+	}
+	for i := 0; i < t.requests; i++ {
+		work <- struct{}{}
+	}
+	close(work)
+	wait.Wait()
+
+	elapsed := time.Since(start)
+	printf("completed in %s (%.1f req/s)", elapsed, float64(t.requests)/elapsed.Seconds())
+
+	var codes []int
+	for code := range res.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		note := ""
+		switch code {
+		case http.StatusUnauthorized:
+			note = " (missing or invalid credentials)"
+		case http.StatusForbidden:
+			note = " (not authorized for product)"
+		case http.StatusTooManyRequests:
+			note = " (quota exceeded)"
+		}
+		printf("  %d: %d%s", code, res.statuses[code], note)
+	}
+	if res.errors > 0 {
+		printf("  transport errors: %d", res.errors)
+	}
+
+	// a run that correctly exhausts its quota legitimately sees only 429s
+	if res.statuses[http.StatusOK] == 0 {
+		if res.statuses[http.StatusTooManyRequests] > 0 {
+			printf("no 200s, but quota exceeded responses indicate enforcement is working")
+		} else {
+			printf("WARNING: no successful responses: check bindings, credentials and handler configuration")
+		}
+	}
+
+	if t.provisionKey != "" && t.provisionSecret != "" {
+		t.validateBackend(printf, fatalf)
+	}
+}
+
+// validateBackend checks the quota counters and analytics acceptance after
+// the traffic run
+func (t *traffic) validateBackend(printf, fatalf shared.FormatFn) {
+	client := &http.Client{Timeout: t.timeout}
+
+	// a zero-weight quota request reads the current window without counting
+	if t.quotaIdentifier != "" {
+		quotaReq := map[string]interface{}{
+			"identifier": t.quotaIdentifier,
+			"weight":     0,
+			"allow":      1,
+			"interval":   1,
+			"timeUnit":   "minute",
+		}
+		body := new(bytes.Buffer)
+		json.NewEncoder(body).Encode(quotaReq)
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(quotasURLFormat, t.CustomerProxyURL), body)
+		if err != nil {
+			fatalf("unable to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(t.provisionKey, t.provisionSecret)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			fatalf("quota check failed: %v", err)
+		}
+		var quotaResult struct {
+			Allowed  int64 `json:"allowed"`
+			Used     int64 `json:"used"`
+			Exceeded int64 `json:"exceeded"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&quotaResult)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			fatalf("quota check for %s returned %s: %v", t.quotaIdentifier, resp.Status, err)
+		}
+		printf("quota %s: used %d of %d (exceeded %d)",
+			t.quotaIdentifier, quotaResult.Used+quotaResult.Exceeded, quotaResult.Allowed, quotaResult.Exceeded)
+	}
+
+	// analytics acceptance: the signed URL endpoint must accept our tenant
+	// (hybrid uploads via UDCA, nothing to probe from outside)
+	if t.IsHybrid {
+		printf("analytics: hybrid uploads via UDCA, skipping endpoint check")
+		return
+	}
+	analyticsURL := fmt.Sprintf(analyticsURLFormat, t.InternalProxyURL, t.Org, t.Env)
+	req, err := http.NewRequest(http.MethodGet, analyticsURL, nil)
+	if err != nil {
+		fatalf("unable to create request: %v", err)
+	}
+	q := req.URL.Query()
+	q.Add("tenant", fmt.Sprintf("%s~%s", t.Org, t.Env))
+	q.Add("relative_file_path", "traffic-validation")
+	q.Add("file_content_type", "application/x-gzip")
+	q.Add("encrypt", "true")
+	req.URL.RawQuery = q.Encode()
+	req.SetBasicAuth(t.provisionKey, t.provisionSecret)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fatalf("analytics check failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fatalf("analytics endpoint rejected the tenant: %s", resp.Status)
+	}
+	printf("analytics: endpoint accepts tenant %s~%s", t.Org, t.Env)
+}
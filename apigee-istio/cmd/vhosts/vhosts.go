@@ -0,0 +1,180 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vhosts
+
+import (
+	"strings"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/provision"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+type vhosts struct {
+	*shared.RootArgs
+	check        string
+	virtualHosts string
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	v := &vhosts{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "vhosts",
+		Short: "Manage virtual hosts and TLS for the istio-auth proxy",
+		Long: `Inspect the environment virtual hosts the istio-auth proxy can be bound to,
+including their TLS configuration, and validate a virtual host list before
+passing it to 'provision --virtualHosts'.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+	}
+
+	c.AddCommand(cmdVhostsList(v, printf, fatalf))
+	c.AddCommand(cmdVhostsDescribe(v, printf, fatalf))
+	c.AddCommand(cmdVhostsSet(v, printf, fatalf))
+
+	return c
+}
+
+func cmdVhostsList(v *vhosts, printf, fatalf shared.FormatFn) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List virtual hosts in the environment",
+		Args:  cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			names, _, err := v.Client.VirtualHosts.List()
+			if err != nil {
+				fatalf("error listing virtual hosts: %v", err)
+			}
+			for _, name := range names {
+				vh, _, err := v.Client.VirtualHosts.Get(name)
+				if err != nil {
+					fatalf("error retrieving virtual host %s: %v", name, err)
+				}
+				tls := "no TLS"
+				if vh.IsTLS() {
+					tls = "TLS"
+				}
+				printf("%s (port %s, %s)", vh.Name, vh.Port, tls)
+			}
+
+			if v.check != "" {
+				var missing []string
+				for _, want := range strings.Split(v.check, ",") {
+					want = strings.TrimSpace(want)
+					found := false
+					for _, name := range names {
+						if name == want {
+							found = true
+							break
+						}
+					}
+					if want != "" && !found {
+						missing = append(missing, want)
+					}
+				}
+				if len(missing) > 0 {
+					fatalf("virtual host(s) not in environment: %s", strings.Join(missing, ", "))
+				}
+				printf("all requested virtual hosts exist")
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&v.check, "check", "", "",
+		"comma-separated virtual hosts to validate (as for 'provision --virtualHosts')")
+
+	return c
+}
+
+func cmdVhostsSet(v *vhosts, printf, fatalf shared.FormatFn) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "set",
+		Short: "Bind the istio-auth proxy to a new set of virtual hosts (redeploys the proxy)",
+		Long: `Validates the given virtual hosts against the environment and redeploys the
+istio-auth proxy bound to them, without re-running the rest of provisioning.
+Not applicable to hybrid, where routing is configured via environment groups.`,
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			names, _, err := v.Client.VirtualHosts.List()
+			if err != nil {
+				fatalf("error listing virtual hosts: %v", err)
+			}
+			var missing []string
+			for _, want := range strings.Split(v.virtualHosts, ",") {
+				want = strings.TrimSpace(want)
+				found := false
+				for _, name := range names {
+					if name == want {
+						found = true
+						break
+					}
+				}
+				if want != "" && !found {
+					missing = append(missing, want)
+				}
+			}
+			if len(missing) > 0 {
+				fatalf("virtual host(s) not in environment: %s", strings.Join(missing, ", "))
+			}
+
+			if err := provision.RedeployAuthProxy(v.RootArgs, v.virtualHosts, printf); err != nil {
+				fatalf("error redeploying proxy: %v", err)
+			}
+			printf("istio-auth proxy bound to: %s", v.virtualHosts)
+		},
+	}
+
+	c.Flags().StringVarP(&v.virtualHosts, "virtualHosts", "", "default,secure",
+		"comma-separated virtual hosts to bind the proxy to")
+
+	return c
+}
+
+func cmdVhostsDescribe(v *vhosts, printf, fatalf shared.FormatFn) *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe [name]",
+		Short: "Describe a virtual host including its TLS configuration",
+		Args:  cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			vh, _, err := v.Client.VirtualHosts.Get(args[0])
+			if err != nil {
+				fatalf("error retrieving virtual host %s: %v", args[0], err)
+			}
+			printf("name: %s", vh.Name)
+			printf("port: %s", vh.Port)
+			for _, alias := range vh.HostAliases {
+				printf("host alias: %s", alias)
+			}
+			if !vh.IsTLS() {
+				printf("tls: disabled")
+				return
+			}
+			printf("tls: enabled")
+			printf("  keystore: %s (alias %s)", vh.SSLInfo.KeyStore, vh.SSLInfo.KeyAlias)
+			if vh.SSLInfo.ClientAuthEnabled == "true" {
+				printf("  client auth: enabled (truststore %s)", vh.SSLInfo.TrustStore)
+			}
+			if len(vh.SSLInfo.Protocols) > 0 {
+				printf("  protocols: %s", strings.Join(vh.SSLInfo.Protocols, ", "))
+			}
+		},
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/cmd/provision"
+	"github.com/apigee/istio-mixer-adapter/apigee-istio/shared"
+	"github.com/spf13/cobra"
+)
+
+const authProxyName = "istio-auth"
+
+type watch struct {
+	*shared.RootArgs
+	provisionKey    string
+	provisionSecret string
+	interval        time.Duration
+}
+
+// Cmd returns base command
+func Cmd(rootArgs *shared.RootArgs, printf, fatalf shared.FormatFn) *cobra.Command {
+	w := &watch{RootArgs: rootArgs}
+
+	c := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch org state and re-print handler config on changes",
+		Long: `Polls the organization (products and istio-auth deployment) and re-prints the
+Istio handler configuration whenever the state changes, so configuration can
+be kept in sync with the org.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return rootArgs.Resolve(false)
+		},
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			if w.provisionKey == "" || w.provisionSecret == "" {
+				fatalf("watch requires values for --key and --secret")
+			}
+			w.run(printf, fatalf)
+		},
+	}
+
+	c.Flags().StringVarP(&w.provisionKey, "key", "k", "", "gateway key")
+	c.Flags().StringVarP(&w.provisionSecret, "secret", "s", "", "gateway secret")
+	c.Flags().DurationVarP(&w.interval, "interval", "", time.Minute, "poll interval")
+
+	return c
+}
+
+func (w *watch) run(printf, fatalf shared.FormatFn) {
+	printf("watching org %s env %s every %s...", w.Org, w.Env, w.interval)
+
+	var lastState string
+	for {
+		state, err := w.orgState()
+		if err != nil {
+			printf("# error polling org state: %v", err)
+		} else if state != lastState {
+			lastState = state
+			handler, err := provision.HandlerYAML(w.RootArgs, w.provisionKey, w.provisionSecret)
+			if err != nil {
+				fatalf("error generating handler: %v", err)
+			}
+			printf("# org state changed at %s", time.Now().Format("2006-01-02 15:04:05"))
+			printf(handler)
+		}
+		time.Sleep(w.interval)
+	}
+}
+
+// orgState fingerprints the products list and the deployed istio-auth revision
+func (w *watch) orgState() (string, error) {
+	products, err := w.Client.Products.List()
+	if err != nil {
+		return "", err
+	}
+	productsJSON, err := json.Marshal(products)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(productsJSON)
+
+	var rev interface{}
+	if w.IsHybrid {
+		rev, err = w.Client.Proxies.GetHybridDeployedRevision(authProxyName)
+	} else {
+		rev, err = w.Client.Proxies.GetDeployedRevision(authProxyName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x/%v", sum, rev), nil
+}
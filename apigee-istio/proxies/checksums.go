@@ -0,0 +1,25 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxies
+
+// ProxyVersion is the release version of the embedded proxy bundles.
+// build_proxy_resources.sh populates it (via checksums_gen.go); "dev" means
+// an unversioned local build.
+var ProxyVersion = "dev"
+
+// AssetChecksums maps embedded asset names to their sha256 hex digest.
+// build_proxy_resources.sh populates it (via checksums_gen.go); when empty,
+// restored assets are not self-checked.
+var AssetChecksums = map[string]string{}
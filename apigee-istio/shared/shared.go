@@ -20,6 +20,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/apigee/istio-mixer-adapter/apigee-istio/apigee"
 )
@@ -40,6 +41,9 @@ const (
 	internalProxyURLFormat     = "%s://istioservices.%s/edgemicro" // routerBase scheme, routerBase domain
 	internalProxyURLFormatOPDK = "%s/edgemicro"                    // routerBase
 	customerProxyURLFormat     = "%s/istio-auth"                   // routerBase
+
+	// briefly cache repeated management GETs (proxy status, deployments) during CLI runs
+	getCacheTTL = 10 * time.Second
 )
 
 // BuildInfoType holds version information
@@ -65,6 +69,7 @@ type RootArgs struct {
 	NetrcPath      string
 	IsOPDK         bool
 	IsHybrid       bool
+	IsApigeeX      bool
 
 	// the following is derived in Resolve()
 	InternalProxyURL string
@@ -75,17 +80,31 @@ type RootArgs struct {
 
 // Resolve is used to populate shared args, it's automatically called prior when creating the root command
 func (r *RootArgs) Resolve(skipAuth bool) error {
+	if r.IsApigeeX {
+		// X shares hybrid's management API and provisioning paths, but its
+		// management auth is OAuth-only
+		r.IsHybrid = true
+		if !skipAuth && r.Token == "" {
+			return errors.New("Apigee X requires --token (OAuth bearer) authentication")
+		}
+	}
 	if r.IsHybrid || r.ManagementBase == HybridManagementBase {
 		r.IsHybrid = true
 		r.ManagementBase = HybridManagementBase
 	}
 	r.IsOPDK = !r.IsHybrid && r.ManagementBase != DefaultManagementBase
 
+	discoverRouterBase := false
 	if r.RouterBase == DefaultRouterBase {
-		if r.IsOPDK || r.IsHybrid {
-			return errors.New("you must specify a router base with Hybrid or OPDK")
+		if r.IsOPDK {
+			return errors.New("you must specify a router base with OPDK")
+		}
+		if r.IsHybrid {
+			// hybrid router base can be discovered from env group hostnames
+			discoverRouterBase = true
+		} else {
+			r.RouterBase = fmt.Sprintf(RouterBaseFormat, r.Org, r.Env)
 		}
-		r.RouterBase = fmt.Sprintf(RouterBaseFormat, r.Org, r.Env)
 	}
 
 	// calculate internal proxy URL from router URL (reuse the scheme and domain) - not valid for Hybrid!
@@ -114,7 +133,13 @@ func (r *RootArgs) Resolve(skipAuth bool) error {
 			BearerToken: r.Token,
 			SkipAuth:    skipAuth,
 		},
-		Debug: r.Verbose,
+		Debug:       r.Verbose,
+		GetCacheTTL: getCacheTTL,
+	}
+	if r.Verbose {
+		r.ClientOpts.Progress = func(e apigee.ProgressEvent) {
+			Printf("[%s] %s (%d%%)", e.Operation, e.Step, e.Percent)
+		}
 	}
 	var err error
 	r.Client, err = apigee.NewEdgeClient(r.ClientOpts)
@@ -129,6 +154,15 @@ func (r *RootArgs) Resolve(skipAuth bool) error {
 		return fmt.Errorf("error initializing Edge client: %v", err)
 	}
 
+	if discoverRouterBase {
+		hostnames, err := r.Client.EnvGroups.DiscoverHostnames(r.Env)
+		if err != nil {
+			return fmt.Errorf("unable to discover router base (specify one with --routerBase): %v", err)
+		}
+		r.RouterBase = fmt.Sprintf("https://%s", hostnames[0])
+		r.CustomerProxyURL = fmt.Sprintf(customerProxyURLFormat, r.RouterBase)
+	}
+
 	return nil
 }
 
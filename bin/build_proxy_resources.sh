@@ -61,4 +61,25 @@ echo "building ${RESOURCE_FILE}"
 cd "${DIST_DIR}"
 go-bindata -nomemcopy -pkg "proxies" -prefix "proxies" -o "${RESOURCE_FILE}" proxies
 
+# record the bundle version and checksums so provision can self-check the
+# restored assets at runtime (see checksums.go)
+CHECKSUM_FILE="${ADAPTER_DIR}/apigee-istio/proxies/checksums_gen.go"
+echo "building ${CHECKSUM_FILE}"
+PROXY_VERSION="${PROXY_VERSION:-dev}"
+{
+  echo "// Code generated by build_proxy_resources.sh. DO NOT EDIT."
+  echo "package proxies"
+  echo ""
+  echo "func init() {"
+  printf '\tProxyVersion = "%s"\n' "${PROXY_VERSION}"
+  printf '\tAssetChecksums = map[string]string{\n'
+  cd "${PROXIES_ZIP_DIR}"
+  for f in *.zip; do
+    SUM=$(shasum -a 256 "${f}" | cut -d' ' -f1)
+    printf '\t\t"%s": "%s",\n' "${f}" "${SUM}"
+  done
+  printf '\t}\n'
+  echo "}"
+} > "${CHECKSUM_FILE}"
+
 echo "done"
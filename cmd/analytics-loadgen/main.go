@@ -0,0 +1,234 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// analytics-loadgen drives synthetic records through the analytics pipeline
+// at a configurable rate and reports staging latency, buffer file counts and
+// memory usage, for load testing the buffering and upload path.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tps            int
+	duration       time.Duration
+	batchSize      int
+	reportInterval time.Duration
+	bufferPath     string
+	baseURL        string
+	org            string
+	environment    string
+	key            string
+	secret         string
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "analytics-loadgen",
+		Short: "Generate analytics load and report pipeline behavior",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := run(); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	rootCmd.Flags().IntVarP(&tps, "tps", "", 100, "records to generate per second")
+	rootCmd.Flags().DurationVarP(&duration, "duration", "", time.Minute, "how long to generate load")
+	rootCmd.Flags().IntVarP(&batchSize, "batch", "", 10, "records per SendRecords call")
+	rootCmd.Flags().DurationVarP(&reportInterval, "report-interval", "", 5*time.Second, "how often to report measurements")
+	rootCmd.Flags().StringVarP(&bufferPath, "buffer-path", "", "", "analytics buffer directory (default: a temp dir)")
+	rootCmd.Flags().StringVarP(&baseURL, "base-url", "", "", "Apigee base URL for uploads (default: a local stub that accepts everything)")
+	rootCmd.Flags().StringVarP(&org, "org", "o", "loadgen", "organization to report")
+	rootCmd.Flags().StringVarP(&environment, "env", "e", "test", "environment to report")
+	rootCmd.Flags().StringVarP(&key, "key", "k", "key", "gateway key (for --base-url)")
+	rootCmd.Flags().StringVarP(&secret, "secret", "s", "secret", "gateway secret (for --base-url)")
+
+	rootCmd.SetArgs(os.Args[1:])
+	rootCmd.Execute()
+}
+
+func run() error {
+	if bufferPath == "" {
+		dir, err := ioutil.TempDir("", "analytics-loadgen")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		bufferPath = dir
+	}
+
+	// without a real base URL, uploads go to a local stub so the full
+	// pipeline (staging, signed URL, upload, removal) still runs
+	if baseURL == "" {
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"url":"%s/put"}`, "http://"+r.Host)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer stub.Close()
+		baseURL = stub.URL
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("base url: %v", err)
+	}
+
+	man, err := analytics.NewStandaloneManager(analytics.Options{
+		BufferPath:         bufferPath,
+		StagingFileLimit:   1024,
+		BaseURL:            base,
+		Key:                key,
+		Secret:             secret,
+		Client:             http.DefaultClient,
+		CollectionInterval: 10 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("analytics manager: %v", err)
+	}
+	defer man.Close()
+
+	tenant := analytics.Tenant{
+		Org:     org,
+		Env:     environment,
+		Key:     key,
+		Secret:  secret,
+		BaseURL: base,
+	}
+
+	fmt.Printf("generating %d records/s for %s (buffer: %s)\n", tps, duration, bufferPath)
+
+	var stats reportStats
+	deadline := time.Now().Add(duration)
+	report := time.NewTicker(reportInterval)
+	defer report.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-tick.C:
+			// spread this second's records over batches
+			for sent := 0; sent < tps; sent += batchSize {
+				n := batchSize
+				if remaining := tps - sent; remaining < n {
+					n = remaining
+				}
+				start := time.Now()
+				if err := man.SendTenantRecords(tenant, makeRecords(n)); err != nil {
+					return fmt.Errorf("SendRecords: %v", err)
+				}
+				stats.observe(n, time.Since(start))
+			}
+		case <-report.C:
+			stats.report(bufferPath)
+		}
+	}
+
+	stats.report(bufferPath)
+	fmt.Printf("done: %d records sent\n", stats.totalRecords)
+	return nil
+}
+
+// reportStats accumulates SendRecords latency between reports
+type reportStats struct {
+	totalRecords int
+	calls        int
+	latencySum   time.Duration
+	latencyMax   time.Duration
+	lastReport   time.Time
+}
+
+func (s *reportStats) observe(records int, latency time.Duration) {
+	s.totalRecords += records
+	s.calls++
+	s.latencySum += latency
+	if latency > s.latencyMax {
+		s.latencyMax = latency
+	}
+}
+
+func (s *reportStats) report(bufferPath string) {
+	var avg time.Duration
+	if s.calls > 0 {
+		avg = s.latencySum / time.Duration(s.calls)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Printf("records=%d staging latency avg=%s max=%s temp files=%d staged files=%d heap=%dKB gc=%d\n",
+		s.totalRecords, avg, s.latencyMax,
+		countFiles(filepath.Join(bufferPath, "temp")),
+		countFiles(filepath.Join(bufferPath, "staging")),
+		mem.HeapAlloc/1024, mem.NumGC)
+
+	s.calls = 0
+	s.latencySum = 0
+	s.latencyMax = 0
+	s.lastReport = time.Now()
+}
+
+// countFiles counts the files under the tenant subdirs of dir
+func countFiles(dir string) int {
+	tenantDirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	var count int
+	for _, d := range tenantDirs {
+		files, err := ioutil.ReadDir(filepath.Join(dir, d.Name()))
+		if err != nil {
+			continue
+		}
+		count += len(files)
+	}
+	return count
+}
+
+func makeRecords(n int) []analytics.Record {
+	ts := time.Now().UnixNano() / int64(time.Millisecond)
+	records := make([]analytics.Record, 0, n)
+	for i := 0; i < n; i++ {
+		records = append(records, analytics.Record{
+			Organization:                 org,
+			Environment:                  environment,
+			APIProxy:                     "loadgen",
+			RequestURI:                   fmt.Sprintf("/loadgen/%d", i),
+			RequestPath:                  fmt.Sprintf("/loadgen/%d", i),
+			RequestVerb:                  "GET",
+			ResponseStatusCode:           200,
+			GatewaySource:                "loadgen",
+			ClientReceivedStartTimestamp: ts,
+			ClientReceivedEndTimestamp:   ts,
+		})
+	}
+	return records
+}
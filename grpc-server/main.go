@@ -2,32 +2,213 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	coreLog "log"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/apigee/istio-mixer-adapter/adapter"
+	"github.com/apigee/istio-mixer-adapter/adapter/analytics"
+	"github.com/apigee/istio-mixer-adapter/adapter/config"
+	"github.com/apigee/istio-mixer-adapter/adapter/util"
 	"github.com/spf13/cobra"
 	"istio.io/istio/pkg/log"
 )
 
+// envVarPrefix is prepended to upper-cased flag names for environment defaults
+const envVarPrefix = "APIGEE_ADAPTER_"
+
+// populated via ldflags
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+func init() {
+	adapter.BuildInfo = adapter.BuildInfoType{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+	}
+}
+
 var address string
+var clientSecretFile string
+var allowedSPIFFEIDs string
+var tlsCertFile string
+var tlsKeyFile string
+var caCertFile string
+var configFile string
+var validationAddr string
+var adminAddr string
+var logSampleRate float64
+var enableRLS bool
+var expectTemplates string
+
+// standalone (ext_authz style) operation outside Mixer's config pipeline
+var orgName string
+var envName string
+var apigeeBase string
+var customerBase string
+var hybridConfig string
+var keyFile string
+var secretFile string
+
+// the standalone tenant handler, when configured
+var standaloneTenant *adapter.ApigeeHandler
+
+// readSecretFile reads and trims a credential file
+func readSecretFile(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// standaloneConfig builds the tenant config from the standalone flags
+func standaloneConfig() (*config.Params, error) {
+	cfg := &config.Params{
+		OrgName:      orgName,
+		EnvName:      envName,
+		ApigeeBase:   apigeeBase,
+		CustomerBase: customerBase,
+		HybridConfig: hybridConfig,
+	}
+	var err error
+	if cfg.Key, err = readSecretFile(keyFile); err != nil {
+		return nil, fmt.Errorf("key file: %v", err)
+	}
+	if cfg.Secret, err = readSecretFile(secretFile); err != nil {
+		return nil, fmt.Errorf("secret file: %v", err)
+	}
+	return cfg, nil
+}
 
 func main() {
 	options := log.DefaultOptions()
 
 	rootCmd := &cobra.Command{
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return util.ApplyFlagDefaults(cmd, envVarPrefix, configFile)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if err := log.Configure(options); err != nil {
 				coreLog.Fatal(err)
 			}
 
-			s, err := adapter.NewGRPCAdapter(address)
+			authOpts := &adapter.GRPCAuthOptions{
+				TLSCertFile: tlsCertFile,
+				TLSKeyFile:  tlsKeyFile,
+				CACertFile:  caCertFile,
+			}
+			if clientSecretFile != "" {
+				secret, err := ioutil.ReadFile(clientSecretFile)
+				if err != nil {
+					fmt.Printf("unable to read client secret file: %v", err)
+					os.Exit(-1)
+				}
+				authOpts.SharedSecret = strings.TrimSpace(string(secret))
+			}
+			for _, id := range strings.Split(allowedSPIFFEIDs, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					authOpts.AllowedSPIFFEIDs = append(authOpts.AllowedSPIFFEIDs, id)
+				}
+			}
+
+			s, err := adapter.NewGRPCAdapterWithLogging(address, authOpts, logSampleRate)
 			if err != nil {
 				fmt.Printf("unable to start server: %v", err)
 				os.Exit(-1)
 			}
 
+			// fail fast if the deployment expects capabilities this build lacks
+			if expectTemplates != "" {
+				var expected []string
+				for _, t := range strings.Split(expectTemplates, ",") {
+					if t = strings.TrimSpace(t); t != "" {
+						expected = append(expected, t)
+					}
+				}
+				if err := s.VerifyCapabilities(expected); err != nil {
+					fmt.Printf("%v\n", err)
+					os.Exit(-1)
+				}
+			}
+
+			// standalone mode: build the tenant at startup so the server can
+			// run (and fail fast) without Mixer's config pipeline
+			if orgName != "" {
+				if keyFile == "" || secretFile == "" {
+					fmt.Printf("standalone mode requires --key-file and --secret-file\n")
+					os.Exit(-1)
+				}
+				cfg, err := standaloneConfig()
+				if err != nil {
+					fmt.Printf("standalone config: %v\n", err)
+					os.Exit(-1)
+				}
+				if standaloneTenant, err = s.PrewarmTenant(cfg); err != nil {
+					fmt.Printf("standalone tenant: %v\n", err)
+					os.Exit(-1)
+				}
+				fmt.Printf("standalone tenant ready: %s/%s\n", orgName, envName)
+
+				// surface pipeline trouble in the server log
+				err = adapter.RegisterAnalyticsAlerts(standaloneTenant, func(a analytics.Alert) {
+					log.Warnf("analytics %s alert: %s", a.Kind, a.Message)
+				})
+				if err != nil {
+					fmt.Printf("analytics alerts: %v\n", err)
+					os.Exit(-1)
+				}
+
+				if enableRLS {
+					if err := s.RegisterRLS(standaloneTenant); err != nil {
+						fmt.Printf("rls: %v\n", err)
+						os.Exit(-1)
+					}
+					fmt.Printf("Envoy RateLimitService enabled\n")
+				}
+
+				if adminAddr != "" {
+					admin, err := adapter.AdminHandler(standaloneTenant)
+					if err != nil {
+						fmt.Printf("admin endpoint: %v\n", err)
+						os.Exit(-1)
+					}
+					go func() {
+						fmt.Printf("admin endpoint on %s\n", adminAddr)
+						if err := http.ListenAndServe(adminAddr, admin); err != nil {
+							fmt.Printf("admin endpoint: %v", err)
+						}
+					}()
+				}
+			}
+
+			if standaloneTenant == nil {
+				if enableRLS {
+					fmt.Printf("--enable-rls requires standalone mode (--org)\n")
+					os.Exit(-1)
+				}
+				if adminAddr != "" {
+					fmt.Printf("--admin-address requires standalone mode (--org)\n")
+					os.Exit(-1)
+				}
+			}
+
+			if validationAddr != "" {
+				go func() {
+					fmt.Printf("analytics validation endpoint on %s\n", validationAddr)
+					if err := http.ListenAndServe(validationAddr, adapter.AnalyticsValidationHandler()); err != nil {
+						fmt.Printf("validation endpoint: %v", err)
+					}
+				}()
+			}
+
 			shutdown := make(chan error, 1)
 			go func() {
 				s.Run(shutdown)
@@ -36,6 +217,26 @@ func main() {
 		},
 	}
 	rootCmd.Flags().StringVarP(&address, "address", "a", ":5000", `Address to use for Adapter's gRPC API`)
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", `Config file of flag=value lines (flags may also be set via APIGEE_ADAPTER_* env vars)`)
+	rootCmd.Flags().StringVarP(&validationAddr, "validation-address", "", "", `Address to serve the analytics record validation endpoint on (disabled if empty)`)
+	rootCmd.Flags().StringVarP(&adminAddr, "admin-address", "", "", `Address to serve the standalone admin endpoint on (disabled if empty)`)
+	rootCmd.Flags().Float64VarP(&logSampleRate, "log-sample-rate", "", 0, `Fraction of requests to log (0 disables, 1 logs everything; errors are always logged)`)
+	rootCmd.Flags().StringVarP(&clientSecretFile, "client-secret", "", "", `File containing a shared secret Mixer must send in `+adapter.SharedSecretHeader)
+	rootCmd.Flags().StringVarP(&allowedSPIFFEIDs, "allowed-spiffe-ids", "", "", `Comma-separated SPIFFE IDs allowed to call the adapter (requires --ca-cert)`)
+	rootCmd.Flags().StringVarP(&tlsCertFile, "tls-cert", "", "", `TLS certificate file for the gRPC listener`)
+	rootCmd.Flags().StringVarP(&tlsKeyFile, "tls-key", "", "", `TLS key file for the gRPC listener`)
+	rootCmd.Flags().StringVarP(&caCertFile, "ca-cert", "", "", `CA certificate file to require and verify client certificates (mTLS)`)
+
+	// standalone operation outside Mixer's config pipeline
+	rootCmd.Flags().StringVarP(&orgName, "org", "o", "", `Apigee organization, enables standalone mode`)
+	rootCmd.Flags().StringVarP(&envName, "env", "e", "", `Apigee environment (standalone mode)`)
+	rootCmd.Flags().StringVarP(&apigeeBase, "apigee-base", "", "", `Apigee internal proxy base URL (standalone mode)`)
+	rootCmd.Flags().StringVarP(&customerBase, "customer-base", "", "", `Apigee customer proxy base URL (standalone mode)`)
+	rootCmd.Flags().StringVarP(&hybridConfig, "hybrid-config", "", "", `Hybrid config file path (standalone mode)`)
+	rootCmd.Flags().StringVarP(&keyFile, "key-file", "", "", `File containing the gateway key (standalone mode)`)
+	rootCmd.Flags().StringVarP(&secretFile, "secret-file", "", "", `File containing the gateway secret (standalone mode)`)
+	rootCmd.Flags().BoolVarP(&enableRLS, "enable-rls", "", false, `Serve the Envoy RateLimitService backed by the standalone tenant's quotas`)
+	rootCmd.Flags().StringVarP(&expectTemplates, "expect-templates", "", "", `Comma-separated template names the deployment requires; startup fails if any is unsupported`)
 
 	options.AttachCobraFlags(rootCmd)
 	rootCmd.SetArgs(os.Args[1:])
@@ -114,6 +114,25 @@ type Instance struct {
 	// Timestamp of when the api_proxy finished receiving response from target.
 	TargetReceivedEndTimestamp time.Time
 
+	// Name of the source workload (eg. source.workload.name).
+	SourceWorkload string
+
+	// Namespace of the source workload (eg. source.workload.namespace).
+	SourceWorkloadNamespace string
+
+	// Name of the destination workload (eg. destination.workload.name).
+	DestinationWorkload string
+
+	// Namespace of the destination workload (eg. destination.workload.namespace).
+	DestinationWorkloadNamespace string
+
+	// Mesh identifier for multi-mesh reporting.
+	MeshId string
+
+	// The request id (eg. request.headers["x-request-id"]), shared by every
+	// gateway a request traverses, enabling cross-cluster deduplication.
+	RequestId string
+
 	// The JWT claims that were used for authenticating the request (if any)
 	// Use subkey "json_claims" for passing all claims in as a single JSON field.
 	ApiClaims map[string]string